@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"update-server/internal/config"
+)
+
+const usage = `用法:
+  orange-service                启动服务
+  orange-service config print   打印生效配置及其来源 (密钥已脱敏)
+`
+
+// runCommand 处理子命令，没有子命令时返回 false 继续启动服务
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		printConfig()
+	case args[0] == "-h" || args[0] == "--help" || args[0] == "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	return true
+}
+
+func printConfig() {
+	cfg, err := config.Parse(config.Path())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("# %s\n", config.Path())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE\tENV")
+	for _, e := range cfg.Entries() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Key, e.Value, e.Source, e.Env)
+	}
+	w.Flush()
+}
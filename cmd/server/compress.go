@@ -0,0 +1,212 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize 小于该大小 (已知 Content-Length 时) 的响应不压缩
+const minCompressSize = 512
+
+var (
+	gzipPool = sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}}
+	brotliPool = sync.Pool{New: func() any {
+		return brotli.NewWriterLevel(io.Discard, 5)
+	}}
+)
+
+// compress 中间件：按 Accept-Encoding 对 JSON/HTML/文本响应进行 brotli 或 gzip 压缩
+//
+// 只压缩完整的 200 响应：带 Range 的请求、206 响应、已有 Content-Encoding 的响应以及
+// 压缩包等二进制下载 (Content-Type 不是文本类) 原样输出，断点续传不受影响。
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: negotiateEncoding(r.Header.Get("Accept-Encoding"))}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding 选择客户端接受的编码，同等权重时优先 brotli
+func negotiateEncoding(header string) string {
+	var brQ, gzipQ float64 = -1, -1
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "br":
+			brQ = q
+		case "gzip":
+			gzipQ = q
+		case "*":
+			if gzipQ < 0 {
+				gzipQ = q
+			}
+		}
+	}
+
+	switch {
+	case brQ > 0 && brQ >= gzipQ:
+		return "br"
+	case gzipQ > 0:
+		return "gzip"
+	default:
+		return ""
+	}
+}
+
+// compressible 是否为值得压缩的文本类型
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/javascript",
+		mediaType == "application/xml", mediaType == "image/svg+xml":
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// compressWriter 在写出响应头时决定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string // 协商出的编码，为空表示客户端不接受压缩
+
+	decided bool
+	enc     io.WriteCloser
+	release func()
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if !c.decided {
+		c.decide(code)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if !c.decided {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.enc != nil {
+		return c.enc.Write(p)
+	}
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *compressWriter) decide(code int) {
+	c.decided = true
+
+	h := c.Header()
+	if code == http.StatusNotModified {
+		// 304 与 200 使用相同的 ETag；http.ServeContent 返回 304 时会删除 Content-Type
+		if c.encoding != "" && (h.Get("Content-Type") == "" || compressible(h.Get("Content-Type"))) {
+			weakenETag(h)
+		}
+		return
+	}
+	if h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	// 同一 URL 的响应随 Accept-Encoding 不同，CDN 需要分别缓存
+	h.Add("Vary", "Accept-Encoding")
+
+	if c.encoding == "" || code != http.StatusOK || h.Get("Content-Range") != "" {
+		return
+	}
+	// 小响应不压缩也使用弱 ETag，与 304 保持一致
+	weakenETag(h)
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < minCompressSize {
+			return
+		}
+	}
+
+	h.Del("Content-Length")
+	// 压缩后的内容不支持按字节范围请求
+	h.Del("Accept-Ranges")
+	h.Set("Content-Encoding", c.encoding)
+
+	switch c.encoding {
+	case "br":
+		bw := brotliPool.Get().(*brotli.Writer)
+		bw.Reset(c.ResponseWriter)
+		c.enc, c.release = bw, func() { brotliPool.Put(bw) }
+	case "gzip":
+		gw := gzipPool.Get().(*gzip.Writer)
+		gw.Reset(c.ResponseWriter)
+		c.enc, c.release = gw, func() { gzipPool.Put(gw) }
+	}
+}
+
+// weakenETag 压缩后字节不同，强 ETag 改为弱 ETag (条件请求仍按弱比较匹配)
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+// ReadFrom 未压缩时交给底层 ResponseWriter，保留 sendfile 优化
+func (c *compressWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !c.decided {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.enc != nil {
+		return io.Copy(c.enc, src)
+	}
+	if rf, ok := c.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(c.ResponseWriter, src)
+}
+
+// Flush 刷出已压缩的数据
+func (c *compressWriter) Flush() {
+	if f, ok := c.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Close 写出压缩流的结尾
+func (c *compressWriter) Close() {
+	if c.enc == nil {
+		return
+	}
+	c.enc.Close()
+	c.release()
+	c.enc = nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+var largeJSON = `{"data":"` + strings.Repeat("a", 2048) + `"}`
+
+func jsonHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"v1.0.0-abcd"`)
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(largeJSON))
+}
+
+func zipHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/zip")
+	http.ServeContent(w, r, "app.zip", time.Time{}, strings.NewReader(largeJSON))
+}
+
+func serveCompressed(h http.HandlerFunc, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header = header
+	w := httptest.NewRecorder()
+	compress(h).ServeHTTP(w, req)
+	return w
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "gzip"},
+		{"identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, 期望 %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompress_JSON(t *testing.T) {
+	for _, enc := range []string{"gzip", "br"} {
+		w := serveCompressed(jsonHandler, http.Header{"Accept-Encoding": {enc}})
+		if got := w.Header().Get("Content-Encoding"); got != enc {
+			t.Fatalf("期望 Content-Encoding %s, 得到 %q", enc, got)
+		}
+		if w.Header().Get("Content-Length") != "" {
+			t.Error("压缩后不应保留原始 Content-Length")
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("期望 Vary: Accept-Encoding, 得到 %q", got)
+		}
+		if got := w.Header().Get("ETag"); got != `W/"v1.0.0-abcd"` {
+			t.Errorf("期望弱 ETag, 得到 %q", got)
+		}
+
+		var r io.Reader
+		if enc == "gzip" {
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = gr
+		} else {
+			r = brotli.NewReader(w.Body)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != largeJSON {
+			t.Errorf("%s 解压后内容不一致", enc)
+		}
+	}
+}
+
+func TestCompress_NotAccepted(t *testing.T) {
+	w := serveCompressed(jsonHandler, http.Header{})
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != largeJSON {
+		t.Error("客户端不接受压缩时应原样返回")
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Error("未压缩的响应也应返回 Vary")
+	}
+}
+
+func TestCompress_SkipDownloadsAndRanges(t *testing.T) {
+	w := serveCompressed(zipHandler, http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != largeJSON {
+		t.Error("压缩包下载不应再压缩")
+	}
+
+	w = serveCompressed(zipHandler, http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("期望 206, 得到 %d", w.Code)
+	}
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != largeJSON[:10] {
+		t.Error("Range 请求应原样返回字节范围")
+	}
+}
+
+func TestCompress_SmallResponse(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(`{"ok":true}`))
+	}
+	w := serveCompressed(h, http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != `{"ok":true}` {
+		t.Error("小响应不应压缩")
+	}
+}
+
+func TestCompress_ConditionalRequest(t *testing.T) {
+	w := serveCompressed(jsonHandler, http.Header{"Accept-Encoding": {"gzip"}})
+	etag := w.Header().Get("ETag")
+
+	// 客户端回传弱 ETag，仍应命中 304，且 304 返回相同的弱 ETag
+	w = serveCompressed(jsonHandler, http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("期望 304, 得到 %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("304 的 ETag 应与 200 一致: %q != %q", got, etag)
+	}
+	if w.Body.Len() != 0 {
+		t.Error("304 响应不应有内容")
+	}
+}
+
+// readFromRecorder 记录是否通过 ReadFrom 写出 (sendfile 路径)
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (r *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom = true
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestCompress_ReadFromPassthrough(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	compress(http.HandlerFunc(zipHandler)).ServeHTTP(w, req)
+
+	if !w.readFrom {
+		t.Error("未压缩的下载应通过底层 ReadFrom 写出")
+	}
+	if w.Body.String() != largeJSON {
+		t.Error("下载内容错误")
+	}
+}
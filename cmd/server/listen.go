@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"update-server/internal/config"
+)
+
+// listenFDsStart systemd 传入的第一个 fd (SD_LISTEN_FDS_START)
+const listenFDsStart = 3
+
+// listen 返回 HTTP 和 HTTPS (未启用时为 nil) 监听器
+//
+// 由 systemd socket 激活启动时使用传入的 socket (重启期间连接由 systemd 保留，不会被拒绝)，
+// 否则按 server.host 监听 TCP 端口或 unix socket。
+func listen(cfg *config.Config) (httpLn, httpsLn net.Listener, err error) {
+	activated, err := activatedListeners(listenFDsStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(activated) > 0 {
+		httpLn, httpsLn = activated["http"], activated["https"]
+		if httpLn == nil {
+			closeAll(activated)
+			return nil, nil, errors.New("systemd 传入的 socket 中没有 http (FileDescriptorName=http)")
+		}
+		if cfg.Server.TLS.Enabled() && httpsLn == nil {
+			if httpsLn, err = net.Listen("tcp", tlsAddr(cfg)); err != nil {
+				closeAll(activated)
+				return nil, nil, err
+			}
+		}
+		return httpLn, httpsLn, nil
+	}
+
+	if path, ok := cfg.UnixSocket(); ok {
+		httpLn, err = listenUnix(path, cfg.SocketMode())
+	} else {
+		httpLn, err = net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Server.TLS.Enabled() {
+		if httpsLn, err = net.Listen("tcp", tlsAddr(cfg)); err != nil {
+			httpLn.Close()
+			return nil, nil, err
+		}
+	}
+	return httpLn, httpsLn, nil
+}
+
+func tlsAddr(cfg *config.Config) string {
+	return fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.TLS.Port)
+}
+
+// listenUnix 监听 unix socket 并设置文件权限，清理上次异常退出残留的 socket 文件
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是 socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("设置 socket 权限失败: %w", err)
+	}
+	return ln, nil
+}
+
+// activatedListeners 读取 systemd socket 激活传入的监听器 (LISTEN_PID/LISTEN_FDS/LISTEN_FDNAMES)
+//
+// 按 FileDescriptorName 区分 http 和 https，未命名时按顺序依次为 http、https。
+func activatedListeners(first int) (map[string]net.Listener, error) {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// 不传给子进程
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	defaults := []string{"http", "https"}
+	out := make(map[string]net.Listener, n)
+	for i := 0; i < n; i++ {
+		fd := first + i
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		if name != "http" && name != "https" {
+			if i >= len(defaults) {
+				closeAll(out)
+				return nil, fmt.Errorf("systemd 传入了多余的 socket (fd %d, %s)", fd, name)
+			}
+			name = defaults[i]
+		}
+
+		// FileListener 复制 fd (设置 close-on-exec)，原 fd 随后关闭
+		f := os.NewFile(uintptr(fd), name)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			closeAll(out)
+			return nil, fmt.Errorf("systemd 传入的 fd %d 不是监听 socket: %w", fd, err)
+		}
+		out[name] = ln
+	}
+	return out, nil
+}
+
+func closeAll(listeners map[string]net.Listener) {
+	for _, ln := range listeners {
+		ln.Close()
+	}
+}
@@ -0,0 +1,144 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"update-server/internal/config"
+)
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orange.sock")
+	// 上次异常退出残留的 socket 文件
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	cfg := config.Default()
+	cfg.Server.Host = "unix:" + path
+	cfg.Server.SocketMode = "0600"
+
+	ln, httpsLn, err := listen(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if httpsLn != nil {
+		t.Error("未启用 TLS 时不应监听 HTTPS")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("期望权限 0600, 得到 %o", info.Mode().Perm())
+	}
+}
+
+func TestListen_RefuseRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("keep"), 0644)
+
+	if _, err := listenUnix(path, 0660); err == nil {
+		t.Error("路径是普通文件时不应删除并监听")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep" {
+		t.Error("普通文件不应被删除")
+	}
+}
+
+func TestActivatedListeners(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	// 复制出一个 fd 模拟 systemd 传入的 socket (由 activatedListeners 关闭)
+	f, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "orange-service.socket")
+
+	listeners, err := activatedListeners(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := listeners["http"]
+	if ln == nil || len(listeners) != 1 {
+		t.Fatalf("期望得到 http 监听器, 得到 %v", listeners)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != tcp.Addr().String() {
+		t.Errorf("期望地址 %s, 得到 %s", tcp.Addr(), ln.Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("应清除 LISTEN_FDS，避免子进程继承")
+	}
+}
+
+func TestActivatedListeners_OtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := activatedListeners(listenFDsStart)
+	if err != nil || listeners != nil {
+		t.Errorf("LISTEN_PID 不是当前进程时应忽略, 得到 %v %v", listeners, err)
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("期望 READY=1, 得到 %q", buf[:n])
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if got := watchdogInterval(); got != 15*time.Second {
+		t.Errorf("期望 15s, 得到 %s", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", "1")
+	if got := watchdogInterval(); got != 0 {
+		t.Errorf("WATCHDOG_PID 不是当前进程时应关闭心跳, 得到 %s", got)
+	}
+}
@@ -1,75 +1,224 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
-	"update-server/internal/cache"
 	"update-server/internal/config"
 	"update-server/internal/handler"
+	"update-server/internal/logging"
+	"update-server/internal/tracing"
 	"update-server/internal/version"
 )
 
-// 中间件：日志 + CORS
-func middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// @title Update Server API
+// @version 1.0
+// @description GitHub Release 缓存和更新检查服务
+// @host localhost:8001
+// @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description 管理接口和统计接口的 token，格式为 "Bearer <token>"
+func main() {
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	cfgStore, err := config.NewStore(config.Path())
+	if err != nil {
+		fatal("加载配置失败", err)
+	}
+	cfg := cfgStore.Get()
+
+	// 日志格式修改后需要重启，级别随配置热重载
+	logging.Setup(os.Stderr, cfg.Log)
+	cfgStore.OnReload(func(_, c *config.Config) { logging.SetLevel(c.Log) })
+
+	// 链路追踪 (修改后需要重启)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, version.AppVersion)
+	if err != nil {
+		fatal("初始化链路追踪失败", err)
+	}
 
-		// CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	app, err := handler.New(cfgStore)
+	if err != nil {
+		fatal("初始化服务失败", err)
+	}
+	app.Start()
 
-		// 处理预检请求
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+	// 配置热重载 (SIGHUP，文件变化由 app.Start 监听)
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			slog.Info("收到 SIGHUP，重载配置")
+			cfgStore.Reload()
 		}
+	}()
 
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
-	})
-}
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 
-func main() {
-	cfg := config.Load()
+	// Swagger UI (仅开发模式)
+	registerSwagger(app.Mux(), addr)
 
-	// 初始化缓存目录
-	if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
-		log.Fatalf("创建缓存目录失败: %v", err)
+	httpLn, httpsLn, err := listen(cfg)
+	if err != nil {
+		fatal("监听失败", err)
 	}
 
-	// 启动时获取版本信息
-	if err := version.Refresh(); err != nil {
-		log.Printf("警告: 初始化版本信息失败: %v", err)
+	var requests inflight
+	root := requests.track(middleware(cfgStore, compress(app)))
+	servers := []*http.Server{{
+		Handler:           root,
+		ReadHeaderTimeout: 10 * time.Second,
+	}}
+	listeners := []net.Listener{httpLn}
+
+	// HTTPS: HTTP 端口继续提供服务 (或跳转)，并响应 ACME HTTP-01 验证
+	if httpsLn != nil {
+		t, err := newTLS(cfg)
+		if err != nil {
+			fatal("初始化 TLS 失败", err)
+		}
+		servers[0].Handler = t.httpHandler(root)
+		servers = append(servers, &http.Server{
+			Handler:           root,
+			TLSConfig:         t.config,
+			ReadHeaderTimeout: 10 * time.Second,
+		})
+		listeners = append(listeners, httpsLn)
 	}
 
-	// 启动时同步缓存
-	go func() {
-		if err := cache.Sync(); err != nil {
-			log.Printf("警告: 同步缓存失败: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	for i, srv := range servers {
+		go serve(srv, listeners[i])
+	}
+
+	// systemd Type=notify: 开始接受请求后通知就绪，并按 WatchdogSec 发送心跳
+	if err := sdNotify("READY=1"); err != nil {
+		slog.Warn("通知 systemd 失败", "err", err)
+	}
+	go runWatchdog(ctx)
+
+	<-ctx.Done()
+	stop()
+	sdNotify("STOPPING=1")
+	shutdown(servers, &requests, app, cfgStore.Get().Server.ShutdownTimeout)
+
+	// 导出剩余的 span
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("导出链路追踪数据失败", "err", err)
+	}
+}
+
+// serve 在 ln 上提供服务，配置了 TLSConfig 时提供 HTTPS (同时启用 HTTP/2)
+func serve(srv *http.Server, ln net.Listener) {
+	var err error
+	if srv.TLSConfig != nil {
+		slog.Info("服务器启动: https://" + ln.Addr().String())
+		err = srv.ServeTLS(ln, "", "")
+	} else {
+		if ln.Addr().Network() == "unix" {
+			slog.Info("服务器启动: unix:" + ln.Addr().String())
+		} else {
+			slog.Info("服务器启动: http://" + ln.Addr().String())
 		}
+		err = srv.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("服务异常退出", err)
+	}
+}
+
+// fatal 记录错误并退出
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// closeGrace 强制断开连接后等待处理函数返回的时间
+const closeGrace = 5 * time.Second
+
+// inflight 进行中的请求
+//
+// srv.Close 只断开连接，不等待处理函数返回，关闭数据库前需要单独等待。
+type inflight struct {
+	wg sync.WaitGroup
+}
+
+func (f *inflight) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.wg.Add(1)
+		defer f.wg.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wait 等待进行中的请求结束，超时返回 false
+func (f *inflight) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
 	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
 
-	// 路由
-	http.HandleFunc("/", handler.Root)
-	http.HandleFunc("/api/v1/check-update", handler.CheckUpdate)
-	http.HandleFunc("/api/v1/version", handler.Version)
-	http.HandleFunc("/api/v1/resources", handler.Resources)
-	http.HandleFunc("/api/v1/resources/", handler.Resources) // 兼容 /api/v1/resources/{brand}/{inviteCode}
-	http.HandleFunc("/api/v1/download/", handler.Download)
-	http.HandleFunc("/api/v1/webhook", handler.Webhook)
-	http.HandleFunc("/api/v1/redirect/domains", handler.Domains)
-	http.HandleFunc("/api/v1/redirect/", handler.RedirectBrand)
+// shutdown 优雅关闭：停止接受新连接，在 server.shutdown_timeout 内等待进行中的下载完成，超时后强制断开；
+// 请求全部结束后再取消后台同步/刷新任务并关闭数据库。
+func shutdown(servers []*http.Server, requests *inflight, app *handler.Server, timeout time.Duration) {
+	slog.Info("正在关闭服务，等待进行中的请求完成", "timeout", timeout)
 
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	// Swagger UI (仅开发模式)
-	registerSwagger(addr)
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				slog.Warn("等待请求完成超时，强制关闭", "err", err)
+				srv.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 强制断开后处理函数可能仍在运行 (请求 context 已取消)，不能在它们退出前关闭数据库
+	if !requests.wait(closeGrace) {
+		slog.Warn("请求处理未在强制断开后退出，跳过关闭数据库", "grace", closeGrace)
+		return
+	}
 
-	log.Printf("服务器启动: http://%s", addr)
-	log.Fatal(http.ListenAndServe(addr, middleware(http.DefaultServeMux)))
+	taskCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := app.Shutdown(taskCtx); err != nil {
+		slog.Warn("等待后台任务退出超时，跳过关闭数据库", "err", err)
+		return
+	}
+
+	if err := app.Close(); err != nil {
+		slog.Warn("关闭数据库失败", "err", err)
+	}
+	slog.Info("服务已关闭")
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"update-server/internal/config"
+	"update-server/internal/handler"
+)
+
+func TestShutdown_WaitsForSlowHandler(t *testing.T) {
+	cfg := config.Default()
+	cfg.Cache.Dir = t.TempDir()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "orange.db")
+	app, err := handler.New(config.NewStatic(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	var requests inflight
+	srv := &http.Server{Handler: requests.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// 超过关闭超时仍在运行，强制断开后还需要一段时间收尾 (如写入下载记录)
+		<-r.Context().Done()
+		time.Sleep(100 * time.Millisecond)
+		finished.Store(true)
+	}))}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	shutdown([]*http.Server{srv}, &requests, app, 50*time.Millisecond)
+	if !finished.Load() {
+		t.Error("期望等待处理函数返回后再关闭服务")
+	}
+}
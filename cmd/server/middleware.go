@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"update-server/internal/config"
+	"update-server/internal/handler"
+	"update-server/internal/logging"
+)
+
+// 中间件：请求 ID + 访问日志 + CORS
+//
+// 请求 ID 优先使用客户端或反向代理传入的 X-Request-ID (格式不合法时重新生成)，
+// 通过响应头返回，并放入请求 context 供后续日志使用。
+func middleware(cfgStore *config.Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		trusted, _ := cfgStore.Get().TrustedProxies()
+		req := &logging.Request{
+			ID:       r.Header.Get("X-Request-ID"),
+			ClientIP: logging.ClientIP(r, trusted),
+		}
+		if !validRequestID(req.ID) {
+			req.ID = logging.NewID()
+		}
+		ctx := logging.NewContext(r.Context(), req)
+		r = r.WithContext(ctx)
+		w.Header().Set("X-Request-ID", req.ID)
+
+		// CORS headers
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+
+		// 处理预检请求
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		rec := handler.NewResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		status := rec.StatusCode()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelWarn
+		}
+		slog.LogAttrs(ctx, level, "请求",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", req.Route),
+			slog.Int("status", status),
+			slog.Int64("bytes", rec.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", req.ClientIP),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// validRequestID 只接受长度不超过 128 的可打印 ASCII (不含空格)，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
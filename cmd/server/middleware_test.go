@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"update-server/internal/config"
+	"update-server/internal/logging"
+)
+
+func TestMiddleware_RequestLog(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+	var buf bytes.Buffer
+	logging.Setup(&buf, config.Log{Level: "info", Format: "json"})
+
+	cfg := config.Default()
+	cfg.Server.TrustedProxies = []string{"127.0.0.1"}
+	var handlerID string
+	h := middleware(config.NewStatic(cfg), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := logging.FromContext(r.Context())
+		req.Route = "/api/v1/version"
+		handlerID = req.ID
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v1/version", nil)
+	req.RemoteAddr = "127.0.0.1:5000"
+	req.Header.Set("X-Request-ID", "trace-42")
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	req.Header.Set("User-Agent", "orange/1.0")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "trace-42" || handlerID != "trace-42" {
+		t.Errorf("应沿用传入的 X-Request-ID: 响应 %q, handler %q", got, handlerID)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("解析日志失败: %v\n%s", err, buf.String())
+	}
+	want := map[string]any{
+		"level":      "WARN",
+		"request_id": "trace-42",
+		"method":     "GET",
+		"route":      "/api/v1/version",
+		"status":     float64(http.StatusBadGateway),
+		"bytes":      float64(len("boom\n")),
+		"client_ip":  "198.51.100.7",
+		"user_agent": "orange/1.0",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s: 期望 %v, 得到 %v", k, v, entry[k])
+		}
+	}
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	h := middleware(config.NewStatic(config.Default()), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, incoming := range []string{"", "bad id\nwith newline"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if incoming != "" {
+			req.Header.Set("X-Request-ID", incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got := w.Header().Get("X-Request-ID"); len(got) != 32 {
+			t.Errorf("传入 %q 时应生成新的 ID, 得到 %q", incoming, got)
+		}
+	}
+}
+
+func TestMiddleware_CORSPreflight(t *testing.T) {
+	h := middleware(config.NewStatic(config.Default()), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("预检请求不应转发给处理函数")
+	}))
+
+	// 管理页面从浏览器调用 DELETE 接口 (取消撤回、取消固定)
+	req := httptest.NewRequest("OPTIONS", "/api/v1/admin/versions/yank", nil)
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), "DELETE") {
+		t.Errorf("期望允许 DELETE, 得到 %q", w.Header().Get("Access-Control-Allow-Methods"))
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("期望允许 Authorization 头, 得到 %q", w.Header().Get("Access-Control-Allow-Headers"))
+	}
+}
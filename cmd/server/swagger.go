@@ -3,7 +3,7 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	_ "update-server/docs"
@@ -11,9 +11,9 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
-func registerSwagger(addr string) {
-	http.Handle("/swagger/", httpSwagger.Handler(
+func registerSwagger(mux *http.ServeMux, addr string) {
+	mux.Handle("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	))
-	log.Printf("Swagger UI: http://%s/swagger/index.html", addr)
+	slog.Info("Swagger UI: http://" + addr + "/swagger/index.html")
 }
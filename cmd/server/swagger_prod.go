@@ -2,6 +2,8 @@
 
 package main
 
-func registerSwagger(_ string) {
+import "net/http"
+
+func registerSwagger(_ *http.ServeMux, _ string) {
 	// 生产模式不启用 Swagger
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"update-server/internal/config"
+	"update-server/internal/httpclient"
+)
+
+// tlsSetup HTTPS 证书来源及 HTTP 端口的处理方式
+type tlsSetup struct {
+	config   *tls.Config
+	acme     *autocert.Manager // 使用 ACME 时非空
+	port     int
+	redirect bool
+}
+
+// newTLS 按 server.tls 配置静态证书或 ACME 自动证书
+func newTLS(cfg *config.Config) (*tlsSetup, error) {
+	t := &tlsSetup{port: cfg.Server.TLS.Port, redirect: cfg.Server.TLS.RedirectHTTP}
+
+	if cfg.Server.TLS.CertFile != "" {
+		loader, err := newCertLoader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		t.config = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: loader.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+		}
+		return t, nil
+	}
+
+	acmeCfg := cfg.Server.TLS.ACME
+	client, err := acmeHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	t.acme = &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(acmeCfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(acmeCfg.Domains...),
+		Email:      acmeCfg.Email,
+		Client:     &acme.Client{DirectoryURL: acmeCfg.DirectoryURL, HTTPClient: client},
+	}
+	// 包含 h2、http/1.1 和 TLS-ALPN-01 验证所需的 acme-tls/1
+	t.config = t.acme.TLSConfig()
+	t.config.MinVersion = tls.VersionTLS12
+	return t, nil
+}
+
+// acmeHTTPClient 访问 ACME 服务器的客户端，沿用 http 段的代理和 DNS 配置，
+// 并额外信任 acme.ca_file (Pebble 等测试服务器使用自签名证书)
+func acmeHTTPClient(cfg *config.Config) (*http.Client, error) {
+	transport, err := httpclient.NewTransport(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	if caFile := cfg.Server.TLS.ACME.CAFile; caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 server.tls.acme.ca_file 失败: %w", err)
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		pool := transport.TLSClientConfig.RootCAs
+		if pool == nil {
+			if pool, err = x509.SystemCertPool(); err != nil || pool == nil {
+				pool = x509.NewCertPool()
+			}
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("server.tls.acme.ca_file 中没有有效的证书: %s", caFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return &http.Client{Transport: transport, Timeout: cfg.HTTP.APITimeout}, nil
+}
+
+// httpHandler HTTP 端口的处理器：响应 ACME HTTP-01 验证，其余请求按配置跳转到 HTTPS 或直接提供服务
+func (t *tlsSetup) httpHandler(app http.Handler) http.Handler {
+	h := app
+	if t.redirect {
+		h = redirectHTTPS(t.port)
+	}
+	if t.acme != nil {
+		h = t.acme.HTTPHandler(h)
+	}
+	return h
+}
+
+// redirectHTTPS 308 跳转到同一主机的 HTTPS 端口 (308 保留 Webhook 等 POST 请求的方法和内容)
+func redirectHTTPS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// certLoader 加载静态证书，文件修改后在下次握手时重新加载 (配合 certbot 等外部续期工具)
+type certLoader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertLoader(certFile, keyFile string) (*certLoader, error) {
+	l := &certLoader{certFile: certFile, keyFile: keyFile}
+	modTime, err := l.latestModTime()
+	if err != nil {
+		return nil, fmt.Errorf("读取证书失败: %w", err)
+	}
+	if err := l.load(modTime); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// latestModTime 证书和私钥中较新的修改时间
+func (l *certLoader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{l.certFile, l.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (l *certLoader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %w", err)
+	}
+	l.cert, l.modTime = &cert, modTime
+	return nil
+}
+
+// GetCertificate 返回当前证书，重新加载失败时继续使用旧证书
+func (l *certLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if modTime, err := l.latestModTime(); err == nil && !modTime.Equal(l.modTime) {
+		if err := l.load(modTime); err != nil {
+			slog.Error("重新加载证书失败，继续使用旧证书", "err", err)
+			l.modTime = modTime
+		} else {
+			slog.Info("证书已重新加载", "path", l.certFile)
+		}
+	}
+	return l.cert, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"update-server/internal/config"
+)
+
+// writeCert 生成 localhost 自签名证书并写入 dir，返回证书和私钥路径
+func writeCert(t *testing.T, dir string, serial int64) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestStaticCert_HTTP2(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile = writeCert(t, t.TempDir(), 1)
+
+	setup, err := newTLS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}),
+		TLSConfig: setup.config,
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	certPEM, _ := os.ReadFile(cfg.Server.TLS.CertFile)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, ServerName: "localhost"},
+		ForceAttemptHTTP2: true,
+	}}
+
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("期望 HTTP/2.0, 得到 %q", body)
+	}
+}
+
+func TestCertLoader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, 1)
+	loader, err := newCertLoader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial := func() int64 {
+		cert, err := loader.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+	if got := serial(); got != 1 {
+		t.Fatalf("期望序列号 1, 得到 %d", got)
+	}
+
+	// 续期后的证书
+	writeCert(t, dir, 2)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if got := serial(); got != 2 {
+		t.Errorf("证书更新后应重新加载, 得到序列号 %d", got)
+	}
+
+	// 写入损坏的证书时继续使用旧证书
+	os.WriteFile(certFile, []byte("broken"), 0644)
+	future = future.Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	if got := serial(); got != 2 {
+		t.Errorf("加载失败时应继续使用旧证书, 得到序列号 %d", got)
+	}
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		port   int
+		host   string
+		target string
+	}{
+		{8443, "example.com:8080", "/api/v1/version?a=1"},
+		{443, "example.com", "/api/v1/version?a=1"},
+		{443, "[::1]:80", "/"},
+	}
+	want := []string{
+		"https://example.com:8443/api/v1/version?a=1",
+		"https://example.com/api/v1/version?a=1",
+		"https://[::1]/",
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest("POST", tt.target, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectHTTPS(tt.port).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("期望 308, 得到 %d", w.Code)
+		}
+		if got := w.Header().Get("Location"); got != want[i] {
+			t.Errorf("期望跳转到 %s, 得到 %s", want[i], got)
+		}
+	}
+}
+
+// TestACME_Pebble 使用本地 Pebble 测试 ACME 证书申请，需要先启动 Pebble:
+//
+//	PEBBLE_VA_ALWAYS_VALID=1 pebble -config test/config/pebble-config.json
+//	ORANGE_TEST_ACME_DIRECTORY=https://localhost:14000/dir \
+//	ORANGE_TEST_ACME_CA_FILE=test/certs/pebble.minica.pem go test ./cmd/server -run ACME
+func TestACME_Pebble(t *testing.T) {
+	directory := os.Getenv("ORANGE_TEST_ACME_DIRECTORY")
+	if directory == "" {
+		t.Skip("未设置 ORANGE_TEST_ACME_DIRECTORY")
+	}
+
+	cfg := config.Default()
+	cfg.Server.TLS.ACME.Domains = []string{"orange.test"}
+	cfg.Server.TLS.ACME.DirectoryURL = directory
+	cfg.Server.TLS.ACME.CAFile = os.Getenv("ORANGE_TEST_ACME_CA_FILE")
+	cfg.Server.TLS.ACME.CacheDir = t.TempDir()
+
+	setup, err := newTLS(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hello := &tls.ClientHelloInfo{ServerName: "orange.test", SupportedProtos: []string{"h2"}}
+	cert, err := setup.config.GetCertificate(hello)
+	if err != nil {
+		t.Fatalf("申请证书失败: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("orange.test"); err != nil {
+		t.Error(err)
+	}
+
+	// 证书已写入 cache_dir，其他域名被拒绝
+	if entries, _ := os.ReadDir(cfg.Server.TLS.ACME.CacheDir); len(entries) == 0 {
+		t.Error("证书应保存到 cache_dir")
+	}
+	if _, err := setup.config.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.test"}); err == nil {
+		t.Error("不在 domains 中的域名不应申请证书")
+	}
+}
@@ -35,31 +35,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/check-update": {
+        "/api/v1/admin/audit": {
             "get": {
-                "description": "根据客户端版本号判断是否需要更新",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "最近的管理操作 (刷新、同步、清除缓存、固定/撤回版本)，新的在前",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "update"
+                    "admin"
                 ],
-                "summary": "检查客户端是否有新版本",
+                "summary": "管理操作审计日志",
                 "parameters": [
                     {
-                        "type": "string",
-                        "example": "\"v1.0.0\"",
-                        "description": "客户端当前版本号",
-                        "name": "version",
-                        "in": "query",
-                        "required": true
+                        "type": "integer",
+                        "description": "条数 (默认 100，最多 1000)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.UpdateCheckResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/admin.Entry"
+                            }
                         }
                     },
                     "400": {
@@ -68,8 +74,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -77,39 +95,42 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/download/{version}/{filename}": {
+        "/api/v1/admin/cache": {
             "get": {
-                "description": "从缓存或 GitHub 下载指定版本的文件",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "GET 列出缓存文件 (read 权限)；DELETE 删除某个版本的全部文件或指定文件 (cache 权限)",
                 "produces": [
-                    "application/octet-stream"
+                    "application/json"
                 ],
                 "tags": [
-                    "download"
+                    "admin"
                 ],
-                "summary": "下载指定版本的文件",
+                "summary": "查看或清除缓存文件",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"v1.0.0\"",
-                        "description": "版本号",
+                        "example": "v1.2.0",
+                        "description": "版本号 (DELETE 必填)",
                         "name": "version",
-                        "in": "path",
-                        "required": true
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "example": "\"app-linux-amd64.tar.gz\"",
-                        "description": "文件名",
-                        "name": "filename",
-                        "in": "path",
-                        "required": true
+                        "example": "app-windows-amd64.exe",
+                        "description": "文件名 (为空时删除该版本的全部文件)",
+                        "name": "file",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "file"
+                            "$ref": "#/definitions/handler.PurgeResponse"
                         }
                     },
                     "400": {
@@ -118,47 +139,83 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/redirect/domains": {
-            "get": {
-                "description": "从 GitHub 获取 domains.json 并返回",
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "GET 列出缓存文件 (read 权限)；DELETE 删除某个版本的全部文件或指定文件 (cache 权限)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "redirect"
+                    "admin"
+                ],
+                "summary": "查看或清除缓存文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "v1.2.0",
+                        "description": "版本号 (DELETE 必填)",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "app-windows-amd64.exe",
+                        "description": "文件名 (为空时删除该版本的全部文件)",
+                        "name": "file",
+                        "in": "query"
+                    }
                 ],
-                "summary": "获取域名列表",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/handler.PurgeResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "502": {
-                        "description": "Bad Gateway",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -166,25 +223,65 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/resources": {
-            "get": {
-                "description": "返回按平台分类的构建文件列表",
+        "/api/v1/admin/cache/verify": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "重新计算缓存文件的 SHA-256 并与来源的校验值比较，不一致的文件从缓存删除",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "resources"
+                    "admin"
+                ],
+                "summary": "重新校验缓存文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "v1.2.0",
+                        "description": "版本号",
+                        "name": "version",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "app-windows-amd64.exe",
+                        "description": "文件名 (为空时校验该版本已缓存的全部文件)",
+                        "name": "file",
+                        "in": "query"
+                    }
                 ],
-                "summary": "获取构建资源列表",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.ResourcesResponse"
+                            "$ref": "#/definitions/handler.VerifyResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -192,25 +289,86 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/version": {
+        "/api/v1/admin/installs/active": {
             "get": {
-                "description": "返回最新版本的完整信息，包括版本号、发布说明、资源列表等",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "根据带 install_id 的检查更新请求，按天 (DAU) 或按月 (MAU) 统计各版本的活跃安装数",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "update"
+                    "admin"
+                ],
+                "summary": "按版本统计日活/月活安装数",
+                "parameters": [
+                    {
+                        "enum": [
+                            "day",
+                            "month"
+                        ],
+                        "type": "string",
+                        "description": "day (默认) 或 month",
+                        "name": "period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "开始日期 (默认按天为 29 天前，按月为 11 个月前的月初)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-30",
+                        "description": "结束日期 (包含，默认今天)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "品牌",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "平台",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "更新渠道",
+                        "name": "channel",
+                        "in": "query"
+                    }
                 ],
-                "summary": "获取最新版本详情",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/version.Info"
+                            "$ref": "#/definitions/handler.ActiveInstallsResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -218,40 +376,66 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/webhook": {
-            "post": {
-                "description": "接收 GitHub release 事件，自动更新版本信息和缓存",
-                "consumes": [
-                    "application/json"
+        "/api/v1/admin/installs/adoption": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "从版本第一次出现在活跃安装中的那天 (或 from) 起，每天使用该版本的活跃安装数和占比",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "webhook"
+                    "admin"
                 ],
-                "summary": "GitHub Webhook 回调",
+                "summary": "版本发布后的采用曲线",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"release\"",
-                        "description": "GitHub 事件类型",
-                        "name": "X-GitHub-Event",
-                        "in": "header",
-                        "required": true
+                        "example": "v1.2.0",
+                        "description": "版本号 (默认最新版本)",
+                        "name": "version",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "GitHub 签名",
-                        "name": "X-Hub-Signature-256",
-                        "in": "header"
+                        "example": "2024-01-01",
+                        "description": "开始日期 (默认该版本第一次出现的日期)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "天数 (默认 30，最多 366，不超过今天)",
+                        "name": "days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "品牌",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "平台",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "更新渠道",
+                        "name": "channel",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/handler.WebhookResponse"
+                            "$ref": "#/definitions/handler.AdoptionResponse"
                         }
                     },
                     "400": {
@@ -266,54 +450,1533 @@ const docTemplate = `{
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     },
-                    "405": {
-                        "description": "Method Not Allowed",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.BuildInfo": {
-            "type": "object",
-            "properties": {
-                "architecture": {
-                    "type": "string"
-                },
-                "download_link": {
-                    "type": "string"
-                },
-                "file_name": {
-                    "type": "string"
-                },
-                "file_path": {
-                    "type": "string"
-                },
-                "file_size": {
-                    "type": "integer"
-                },
-                "file_type": {
-                    "type": "string"
-                },
-                "file_url": {
-                    "type": "string"
-                },
-                "upload_time": {
-                    "type": "string"
-                }
-            }
-        },
-        "handler.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "错误信息"
-                }
-            }
+        },
+        "/api/v1/admin/refresh": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "从来源重新获取最新版本；sync=true 时随后在后台同步缓存",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "立即刷新版本信息",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "刷新后同步缓存",
+                        "name": "sync",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdminActionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/sync": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "立即开始同步当前版本的文件，同步状态见 /api/v1/status",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "在后台同步缓存",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdminActionResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/versions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "查看固定和撤回的版本",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.VersionsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/versions/pin": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "POST 固定提供指定版本 (不再跟随最新版本)，DELETE 取消固定；随后刷新版本信息并在后台同步缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "固定或取消固定提供的版本",
+                "parameters": [
+                    {
+                        "description": "要固定的版本 (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.VersionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdminActionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "POST 固定提供指定版本 (不再跟随最新版本)，DELETE 取消固定；随后刷新版本信息并在后台同步缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "固定或取消固定提供的版本",
+                "parameters": [
+                    {
+                        "description": "要固定的版本 (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.VersionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdminActionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/versions/yank": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "POST 撤回版本：不再作为最新版本提供，回退到之前最近的未撤回正式版本 (撤回固定的版本时同时取消固定)；\nDELETE 取消撤回。随后刷新版本信息并在后台同步缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "撤回或取消撤回版本",
+                "parameters": [
+                    {
+                        "description": "要撤回的版本和原因 (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.VersionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "example": "v1.2.0",
+                        "description": "要取消撤回的版本 (DELETE)",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdminActionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "POST 撤回版本：不再作为最新版本提供，回退到之前最近的未撤回正式版本 (撤回固定的版本时同时取消固定)；\nDELETE 取消撤回。随后刷新版本信息并在后台同步缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "撤回或取消撤回版本",
+                "parameters": [
+                    {
+                        "description": "要撤回的版本和原因 (POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handler.VersionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "example": "v1.2.0",
+                        "description": "要取消撤回的版本 (DELETE)",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.AdminActionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "最近 50 次 webhook 请求 (只保存在内存中，重启后清空)，新的在前",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "最近的 webhook 请求",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handler.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/analytics/downloads": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "按天 (analytics.timezone)、品牌、邀请码汇总下载次数、完成情况和流量，用于合作方结算",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "按天统计下载",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "开始日期 (默认 29 天前)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-30",
+                        "description": "结束日期 (包含，默认今天)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "品牌",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "邀请码",
+                        "name": "invite_code",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "description": "json 或 csv",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.DownloadReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/analytics/downloads/events": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "导出日期范围内的每一次下载，时间使用 analytics.timezone",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "导出下载记录 (CSV)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "2024-01-01",
+                        "description": "开始日期 (默认 29 天前)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2024-01-30",
+                        "description": "结束日期 (包含，默认今天)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "品牌",
+                        "name": "brand",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "邀请码",
+                        "name": "invite_code",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/check-update": {
+            "get": {
+                "description": "根据客户端版本号判断是否需要更新；客户端版本已撤回时返回 yanked，开启 yank.force_downgrade 时建议降级",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "update"
+                ],
+                "summary": "检查客户端是否有新版本",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"v1.0.0\"",
+                        "description": "客户端当前版本号",
+                        "name": "version",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"windows\"",
+                        "description": "客户端平台 (仅用于统计)",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"stable\"",
+                        "description": "更新渠道 (仅用于统计)",
+                        "name": "channel",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "客户端生成的匿名安装 ID (仅用于统计活跃安装，只保存哈希)",
+                        "name": "install_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"amd64\"",
+                        "description": "客户端架构 (仅用于统计)",
+                        "name": "arch",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"10.0.22631\"",
+                        "description": "操作系统版本 (仅用于统计)",
+                        "name": "os_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"orange\"",
+                        "description": "品牌 (仅用于统计)",
+                        "name": "brand",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateCheckResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/download/{version}/{filename}": {
+            "get": {
+                "description": "从缓存或 GitHub 下载指定版本的文件",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "download"
+                ],
+                "summary": "下载指定版本的文件",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"v1.0.0\"",
+                        "description": "版本号",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"app-linux-amd64.tar.gz\"",
+                        "description": "文件名",
+                        "name": "filename",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/redirect/domains": {
+            "get": {
+                "description": "从 GitHub 获取 domains.json 并返回",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "redirect"
+                ],
+                "summary": "获取域名列表",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/redirect/{brand}": {
+            "get": {
+                "description": "根据品牌名称重定向到该品牌的第一个面板 URL",
+                "tags": [
+                    "redirect"
+                ],
+                "summary": "品牌重定向",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"v2x\"",
+                        "description": "品牌名称",
+                        "name": "brand",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "重定向到面板 URL"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/resources": {
+            "get": {
+                "description": "返回按平台分类的构建文件列表",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "获取构建资源列表",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ResourcesResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/status": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "返回当前版本、最近的刷新/同步结果、domains 获取状态、缓存内容和来源 API 速率限制",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "服务状态",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.StatusResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/version": {
+            "get": {
+                "description": "返回最新版本的完整信息，包括版本号、发布说明、资源列表等",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "update"
+                ],
+                "summary": "获取最新版本详情",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/version.Info"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhook": {
+            "post": {
+                "description": "接收 release 发布和编辑事件 (GitHub、GitLab、Gitea/Forgejo，按 release.provider)，自动更新版本信息和缓存",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Release Webhook 回调",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"release\"",
+                        "description": "GitHub 事件类型",
+                        "name": "X-GitHub-Event",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "GitHub 签名",
+                        "name": "X-Hub-Signature-256",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.WebhookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "405": {
+                        "description": "Method Not Allowed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "进程正常运行即返回 200",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "存活检查",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "版本信息已加载、缓存可写且 domains.json 最近获取成功时返回 200，否则返回 503 及未通过的检查项",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "system"
+                ],
+                "summary": "就绪检查",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.HealthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/handler.HealthResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "admin.Entry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "cache.purge"
+                },
+                "actor": {
+                    "description": "token 名称",
+                    "type": "string",
+                    "example": "ops"
+                },
+                "client_ip": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "操作失败的原因",
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "target": {
+                    "type": "string",
+                    "example": "v1.2.0/app.zip"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "admin.Yank": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "by": {
+                    "description": "操作者 (token 名称)，release 标记撤回时为 \"release\"",
+                    "type": "string",
+                    "example": "ops"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "启动崩溃"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.2.0"
+                }
+            }
+        },
+        "analytics.Active": {
+            "type": "object",
+            "properties": {
+                "period": {
+                    "description": "YYYY-MM-DD 或 YYYY-MM",
+                    "type": "string",
+                    "example": "2024-01-02"
+                },
+                "total": {
+                    "description": "活跃安装数",
+                    "type": "integer"
+                },
+                "versions": {
+                    "description": "各版本的活跃安装数",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "analytics.AdoptionPoint": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "type": "string",
+                    "example": "2024-01-02"
+                },
+                "installs": {
+                    "description": "使用该版本的活跃安装数",
+                    "type": "integer"
+                },
+                "share": {
+                    "description": "installs / total",
+                    "type": "number"
+                },
+                "total": {
+                    "description": "当天全部活跃安装数",
+                    "type": "integer"
+                }
+            }
+        },
+        "analytics.Daily": {
+            "type": "object",
+            "properties": {
+                "aborted": {
+                    "description": "客户端中途断开",
+                    "type": "integer"
+                },
+                "brand": {
+                    "type": "string",
+                    "example": "orange"
+                },
+                "bytes": {
+                    "description": "本服务实际发送的字节数",
+                    "type": "integer"
+                },
+                "completed": {
+                    "description": "完整下载",
+                    "type": "integer"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2024-01-02"
+                },
+                "failed": {
+                    "description": "服务端错误",
+                    "type": "integer"
+                },
+                "invite_code": {
+                    "type": "string",
+                    "example": "ABC123"
+                },
+                "partial": {
+                    "description": "分段下载的中间分段",
+                    "type": "integer"
+                },
+                "redirected": {
+                    "description": "重定向到对象存储",
+                    "type": "integer"
+                },
+                "requests": {
+                    "description": "下载请求数 (含未完成的)",
+                    "type": "integer"
+                }
+            }
+        },
+        "cache.SyncStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "description": "下载失败的文件数",
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "cache.UpstreamStatus": {
+            "type": "object",
+            "properties": {
+                "down_until": {
+                    "type": "string"
+                },
+                "failures": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "cache.VerifyResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "expected": {
+                    "description": "来源的校验值，未知时只比较大小",
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string",
+                    "example": "v1.2.0/app-windows-amd64.exe"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "removed": {
+                    "description": "校验失败，已从缓存删除 (下次请求时重新下载)",
+                    "type": "boolean"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ActiveInstallsResponse": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string",
+                    "example": "2024-01-01"
+                },
+                "period": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analytics.Active"
+                    }
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Shanghai"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2024-01-30"
+                }
+            }
+        },
+        "handler.AdminActionResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "刷新版本信息失败的原因",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                },
+                "syncing": {
+                    "description": "已在后台开始同步缓存",
+                    "type": "boolean"
+                },
+                "version": {
+                    "description": "操作后提供的版本",
+                    "type": "string",
+                    "example": "v1.2.0"
+                }
+            }
+        },
+        "handler.AdoptionResponse": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analytics.AdoptionPoint"
+                    }
+                },
+                "from": {
+                    "type": "string",
+                    "example": "2024-01-01"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2024-01-30"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.2.0"
+                }
+            }
+        },
+        "handler.BuildInfo": {
+            "type": "object",
+            "properties": {
+                "architecture": {
+                    "type": "string"
+                },
+                "download_link": {
+                    "type": "string"
+                },
+                "file_name": {
+                    "type": "string"
+                },
+                "file_path": {
+                    "type": "string"
+                },
+                "file_size": {
+                    "type": "integer"
+                },
+                "file_type": {
+                    "type": "string"
+                },
+                "file_url": {
+                    "type": "string"
+                },
+                "upload_time": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.CacheStatus": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.CachedFile"
+                    }
+                },
+                "objects": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.CachedFile": {
+            "type": "object",
+            "properties": {
+                "key": {
+                    "type": "string",
+                    "example": "v1.2.0/app-windows-amd64.exe"
+                },
+                "mod_time": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.DomainsStatus": {
+            "type": "object",
+            "properties": {
+                "last_attempt": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_success": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.DownloadReportResponse": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/analytics.Daily"
+                    }
+                },
+                "from": {
+                    "type": "string",
+                    "example": "2024-01-01"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "Asia/Shanghai"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "2024-01-30"
+                }
+            }
+        },
+        "handler.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "错误信息"
+                }
+            }
+        },
+        "handler.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "handler.PurgeResponse": {
+            "type": "object",
+            "properties": {
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handler.RateLimitStatus": {
+            "type": "object",
+            "properties": {
+                "host": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_status": {
+                    "type": "integer"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "reset": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.ReleaseStatus": {
+            "type": "object",
+            "properties": {
+                "assets": {
+                    "type": "integer"
+                },
+                "published_at": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "v1.2.0"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
         },
         "handler.ResourcesResponse": {
             "type": "object",
@@ -348,9 +2011,53 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.StatusResponse": {
+            "type": "object",
+            "properties": {
+                "app": {
+                    "type": "string",
+                    "example": "orange-service"
+                },
+                "app_version": {
+                    "type": "string",
+                    "example": "1.0.0"
+                },
+                "cache": {
+                    "$ref": "#/definitions/handler.CacheStatus"
+                },
+                "domains": {
+                    "$ref": "#/definitions/handler.DomainsStatus"
+                },
+                "rate_limits": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.RateLimitStatus"
+                    }
+                },
+                "refresh": {
+                    "$ref": "#/definitions/version.RefreshStatus"
+                },
+                "release": {
+                    "$ref": "#/definitions/handler.ReleaseStatus"
+                },
+                "sync": {
+                    "$ref": "#/definitions/cache.SyncStatus"
+                },
+                "upstreams": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cache.UpstreamStatus"
+                    }
+                }
+            }
+        },
         "handler.UpdateCheckResponse": {
             "type": "object",
             "properties": {
+                "downgrade": {
+                    "description": "建议降级到 latest_version (yank.force_downgrade)",
+                    "type": "boolean"
+                },
                 "download_url": {
                     "type": "string",
                     "example": "https://example.com"
@@ -366,6 +2073,107 @@ const docTemplate = `{
                 "update_available": {
                     "type": "boolean",
                     "example": true
+                },
+                "yank_reason": {
+                    "description": "撤回原因",
+                    "type": "string"
+                },
+                "yanked": {
+                    "description": "客户端当前版本已撤回",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handler.VerifyResponse": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "description": "校验失败或出错的文件数",
+                    "type": "integer"
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cache.VerifyResult"
+                    }
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.2.0"
+                }
+            }
+        },
+        "handler.VersionRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "撤回原因",
+                    "type": "string",
+                    "example": "启动崩溃"
+                },
+                "version": {
+                    "type": "string",
+                    "example": "v1.2.0"
+                }
+            }
+        },
+        "handler.VersionsResponse": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "description": "当前提供的版本",
+                    "type": "string",
+                    "example": "v1.2.0"
+                },
+                "pinned": {
+                    "type": "string",
+                    "example": "v1.1.0"
+                },
+                "yanked": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/admin.Yank"
+                    }
+                }
+            }
+        },
+        "handler.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "example": "published"
+                },
+                "error": {
+                    "description": "后台刷新或同步的错误",
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string",
+                    "example": "release"
+                },
+                "finished_at": {
+                    "description": "后台刷新和同步完成的时间 (仅 accepted)",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "X-GitHub-Delivery / X-Gitea-Delivery / X-Gitlab-Event-UUID",
+                    "type": "string"
+                },
+                "received_at": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "result": {
+                    "description": "accepted / ignored / duplicate / invalid_signature / bad_payload",
+                    "type": "string",
+                    "example": "accepted"
+                },
+                "tag": {
+                    "type": "string",
+                    "example": "v1.2.0"
                 }
             }
         },
@@ -419,6 +2227,28 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "version.RefreshStatus": {
+            "type": "object",
+            "properties": {
+                "last_attempt": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_success": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "管理接口和统计接口的 token，格式为 \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -24,24 +24,16 @@ if [ -f "$INSTALL_DIR/$BINARY_NAME" ]; then
     echo "📋 Existing installation detected"
 fi
 
-# 如果是更新，先停止服务并删除旧二进制
-if [ "$IS_UPDATE" = true ]; then
-    if systemctl is-active --quiet $SERVICE_NAME 2>/dev/null; then
-        echo "⏹️ Stopping service..."
-        sudo systemctl stop $SERVICE_NAME
-    fi
-    echo "🗑️ Removing old binary..."
-    sudo rm -f "$INSTALL_DIR/$BINARY_NAME"
-fi
-
 # 创建目录
 sudo mkdir -p $INSTALL_DIR
 cd $INSTALL_DIR
 
 # 下载新版本
 echo "⬇️ Downloading..."
-sudo curl -L -o $BINARY_NAME "https://github.com/${REPO}/releases/download/${LATEST}/orange-service-linux-amd64"
-sudo chmod +x $BINARY_NAME
+# 先下载到临时文件再替换，socket 激活时更新期间收到的请求不会启动到不完整的二进制
+sudo curl -fL -o $BINARY_NAME.new "https://github.com/${REPO}/releases/download/${LATEST}/orange-service-linux-amd64"
+sudo chmod +x $BINARY_NAME.new
+sudo mv -f $BINARY_NAME.new $BINARY_NAME
 
 # 创建配置文件（仅首次安装）
 if [ ! -f config.yaml ]; then
@@ -52,50 +44,76 @@ server:
   host: "127.0.0.1"
   base_url: "https://your-domain.com"
 
-github:
-  token: ""
+release:
   repo: "owner/repo"
+  token: ""
   webhook_secret: ""
 
+domains:
+  repo: "owner/domains-repo"
+  token: ""
+
 cache:
   dir: "github_cache"
 EOF
 fi
 
 # 创建/更新 systemd 服务
+# socket 由 systemd 持有 (socket 激活)，重启/更新期间新连接排队等待而不是被拒绝；
+# 使用 socket 时忽略 config.yaml 中的 server.host/server.port
 echo "🔧 Configuring systemd service..."
+sudo tee /etc/systemd/system/${SERVICE_NAME}.socket > /dev/null << EOF
+[Unit]
+Description=Orange Service Socket
+
+[Socket]
+ListenStream=127.0.0.1:8001
+FileDescriptorName=http
+# 反向代理在本机时可改用 unix socket:
+# ListenStream=/run/${SERVICE_NAME}/orange.sock
+# SocketMode=0660
+
+[Install]
+WantedBy=sockets.target
+EOF
+
 sudo tee /etc/systemd/system/${SERVICE_NAME}.service > /dev/null << EOF
 [Unit]
 Description=Orange Service
 After=network.target
+Requires=${SERVICE_NAME}.socket
 
 [Service]
-Type=simple
+Type=notify
 WorkingDirectory=$INSTALL_DIR
 ExecStart=$INSTALL_DIR/$BINARY_NAME
 Environment=GOMEMLIMIT=64MiB
 Environment=GOGC=50
 Restart=always
 RestartSec=5
+WatchdogSec=60
+KillSignal=SIGTERM
+TimeoutStopSec=330
 
 [Install]
 WantedBy=multi-user.target
 EOF
 
 sudo systemctl daemon-reload
-sudo systemctl enable $SERVICE_NAME
+sudo systemctl enable ${SERVICE_NAME}.socket $SERVICE_NAME
 
 # 完成提示
 if [ "$IS_UPDATE" = true ]; then
-    echo "🔄 Starting service..."
-    sudo systemctl start $SERVICE_NAME
+    # 先停止旧进程再启动 socket (旧版本自己监听端口)，同一事务中完成
+    echo "🔄 Restarting service..."
+    sudo systemctl restart $SERVICE_NAME
     echo "✅ Update to $LATEST complete!"
 else
     echo "✅ Installation complete!"
     echo ""
     echo "Next steps:"
     echo "  1. Edit config: sudo nano $INSTALL_DIR/config.yaml"
-    echo "  2. Start service: sudo systemctl start $SERVICE_NAME"
+    echo "  2. Start service: sudo systemctl start ${SERVICE_NAME}.socket $SERVICE_NAME"
 fi
 
 echo "  Check status: sudo systemctl status $SERVICE_NAME"
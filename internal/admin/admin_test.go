@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"update-server/internal/database"
+)
+
+func TestVersions_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orange.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVersions(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Pin("v1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v.Yank(Yank{Version: "v1.2.0", Reason: "崩溃", By: "ops", At: now})
+	v.Yank(Yank{Version: "v1.3.0", By: "ops", At: now.Add(time.Minute)})
+	if found, err := v.Unyank("v1.3.0"); err != nil || !found {
+		t.Fatalf("期望取消撤回成功, 得到 %v %v", found, err)
+	}
+	if found, _ := v.Unyank("v9.9.9"); found {
+		t.Error("未撤回的版本不应返回 found")
+	}
+	db.Close()
+
+	db, err = database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	v, err = NewVersions(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Pinned() != "v1.1.0" {
+		t.Errorf("期望重新打开后固定 v1.1.0, 得到 %q", v.Pinned())
+	}
+	if !v.Yanked("v1.2.0") || v.Yanked("v1.3.0") {
+		t.Errorf("撤回状态错误: %+v", v.Yanks())
+	}
+	if y := v.Yanks(); len(y) != 1 || y[0].Reason != "崩溃" {
+		t.Errorf("期望 1 个撤回记录, 得到 %+v", y)
+	}
+	v.Pin("")
+	if v.Pinned() != "" {
+		t.Error("期望取消固定")
+	}
+}
+
+func TestAudit_Recent(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "orange.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	a, err := NewAudit(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{"cache.purge", "versions.pin", "versions.yank"} {
+		if err := a.Record(Entry{Actor: "ops", Action: action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := a.Recent(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "versions.yank" || entries[1].Action != "versions.pin" {
+		t.Errorf("期望最近 2 条 (新的在前), 得到 %+v", entries)
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("期望自动填充时间")
+	}
+}
@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"update-server/internal/database"
+)
+
+var bucketAudit = []byte("audit")
+
+// Entry 一条审计记录
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor" example:"ops"` // token 名称
+	Action    string    `json:"action" example:"cache.purge"`
+	Target    string    `json:"target,omitempty" example:"v1.2.0/app.zip"`
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"` // 操作失败的原因
+	RequestID string    `json:"request_id,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+}
+
+// Audit 管理操作的审计日志，保存在本地数据库中，同时写入服务日志
+type Audit struct {
+	db *bolt.DB
+}
+
+// NewAudit 创建审计日志
+func NewAudit(db *bolt.DB) (*Audit, error) {
+	if err := database.CreateBuckets(db, string(bucketAudit)); err != nil {
+		return nil, err
+	}
+	return &Audit{db: db}, nil
+}
+
+// Record 同步写入一条审计记录 (管理操作很少，不需要排队)
+func (a *Audit) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	slog.Info("管理操作", "actor", e.Actor, "action", e.Action, "target", e.Target,
+		"detail", e.Detail, "error", e.Error, "request_id", e.RequestID, "client_ip", e.ClientIP)
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return a.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAudit)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return b.Put(key, data)
+	})
+}
+
+// Recent 最近的 limit 条审计记录，新的在前
+func (a *Audit) Recent(limit int) ([]Entry, error) {
+	out := []Entry{}
+	err := a.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAudit).Cursor()
+		for k, v := c.Last(); k != nil && len(out) < limit; k, v = c.Prev() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			out = append(out, e)
+		}
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,188 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"update-server/internal/database"
+)
+
+var (
+	bucketVersions = []byte("versions")
+	keyPinned      = []byte("pinned")
+	bucketYanked   = []byte("yanked")
+)
+
+// Yank 一个已撤回的版本
+type Yank struct {
+	Version string    `json:"version" example:"v1.2.0"`
+	Reason  string    `json:"reason,omitempty" example:"启动崩溃"`
+	By      string    `json:"by" example:"ops"` // 操作者 (token 名称)，release 标记撤回时为 "release"
+	At      time.Time `json:"at"`
+}
+
+// ByRelease release 标记撤回 (yank.label) 时 Yank.By 的值
+const ByRelease = "release"
+
+// Versions 运维固定或撤回的版本，保存在本地数据库中，读取时使用内存中的副本
+//
+// release 标记撤回的版本只保存在内存中，每次刷新版本信息时重新同步。
+type Versions struct {
+	db *bolt.DB
+
+	mu      sync.RWMutex
+	pinned  string
+	yanked  map[string]Yank
+	labeled map[string]Yank
+}
+
+// errNoDatabase 没有打开本地数据库时不能固定或撤回版本
+var errNoDatabase = errors.New("未打开本地数据库，不能固定或撤回版本")
+
+// NewVersions 从数据库加载固定和撤回的版本
+//
+// db 为 nil (没有启用使用数据库的功能) 时没有固定或撤回的版本，只记录 release 标记。
+func NewVersions(db *bolt.DB) (*Versions, error) {
+	if db == nil {
+		return &Versions{yanked: make(map[string]Yank), labeled: make(map[string]Yank)}, nil
+	}
+	if err := database.CreateBuckets(db, string(bucketVersions), string(bucketYanked)); err != nil {
+		return nil, err
+	}
+	v := &Versions{db: db, yanked: make(map[string]Yank), labeled: make(map[string]Yank)}
+	err := db.View(func(tx *bolt.Tx) error {
+		v.pinned = string(tx.Bucket(bucketVersions).Get(keyPinned))
+		return tx.Bucket(bucketYanked).ForEach(func(k, data []byte) error {
+			var y Yank
+			if err := json.Unmarshal(data, &y); err != nil {
+				return fmt.Errorf("读取撤回的版本 %s 失败: %w", k, err)
+			}
+			v.yanked[string(k)] = y
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Pinned 固定提供的版本，未固定时为空
+func (v *Versions) Pinned() string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.pinned
+}
+
+// Yanked 版本是否已撤回 (运维撤回或 release 标记)
+func (v *Versions) Yanked(tag string) bool {
+	_, ok := v.Get(tag)
+	return ok
+}
+
+// Get 撤回记录，运维撤回优先于 release 标记
+func (v *Versions) Get(tag string) (Yank, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if y, ok := v.yanked[tag]; ok {
+		return y, true
+	}
+	y, ok := v.labeled[tag]
+	return y, ok
+}
+
+// Labeled 记录 release 是否标记为撤回
+func (v *Versions) Labeled(tag string, yanked bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.labeled[tag]
+	switch {
+	case yanked && !ok:
+		v.labeled[tag] = Yank{Version: tag, Reason: "release 标记为撤回", By: ByRelease, At: time.Now()}
+	case !yanked && ok:
+		delete(v.labeled, tag)
+	}
+}
+
+// Yanks 已撤回的版本，按撤回时间排序
+func (v *Versions) Yanks() []Yank {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	out := make([]Yank, 0, len(v.yanked)+len(v.labeled))
+	for _, y := range v.yanked {
+		out = append(out, y)
+	}
+	for tag, y := range v.labeled {
+		if _, ok := v.yanked[tag]; !ok {
+			out = append(out, y)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].At.Before(out[j].At) })
+	return out
+}
+
+// Pin 固定提供 tag 版本，tag 为空时取消固定
+func (v *Versions) Pin(tag string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	err := v.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketVersions)
+		if tag == "" {
+			return b.Delete(keyPinned)
+		}
+		return b.Put(keyPinned, []byte(tag))
+	})
+	if err != nil {
+		return err
+	}
+	v.pinned = tag
+	return nil
+}
+
+// Yank 撤回版本，已撤回时更新原因
+func (v *Versions) Yank(y Yank) error {
+	data, err := json.Marshal(y)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	err = v.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketYanked).Put([]byte(y.Version), data)
+	})
+	if err != nil {
+		return err
+	}
+	v.yanked[y.Version] = y
+	return nil
+}
+
+// Unyank 取消运维撤回，返回该版本之前是否已撤回 (release 标记需要在来源修改)
+func (v *Versions) Unyank(tag string) (bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.yanked[tag]; !ok {
+		return false, nil
+	}
+	err := v.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketYanked).Delete([]byte(tag))
+	})
+	if err != nil {
+		return false, err
+	}
+	delete(v.yanked, tag)
+	return true, nil
+}
+
+func (v *Versions) update(fn func(tx *bolt.Tx) error) error {
+	if v.db == nil {
+		return errNoDatabase
+	}
+	return v.db.Update(fn)
+}
@@ -0,0 +1,123 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"update-server/internal/database"
+)
+
+var bucketDownloads = []byte("downloads")
+
+// 下载结果
+const (
+	OutcomeCompleted  = "completed"  // 文件 (或到文件末尾的 Range) 完整发送
+	OutcomePartial    = "partial"    // 完整发送了不含文件末尾的 Range (多线程下载的分段)
+	OutcomeAborted    = "aborted"    // 客户端在发送完成前断开
+	OutcomeRedirected = "redirected" // 302 到对象存储，无法得知是否完成
+	OutcomeFailed     = "failed"     // 服务端错误 (来源下载失败等)
+)
+
+// Download 一次下载请求
+type Download struct {
+	Time       time.Time `json:"time"`
+	Brand      string    `json:"brand,omitempty"`
+	InviteCode string    `json:"invite_code,omitempty"`
+	Version    string    `json:"version"`
+	File       string    `json:"file"`
+	Platform   string    `json:"platform,omitempty"`
+	Arch       string    `json:"arch,omitempty"`
+	Country    string    `json:"country,omitempty"` // ISO 3166-1 两位国家代码
+	Source     string    `json:"source,omitempty"`  // cache / upstream / redirect
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	Outcome    string    `json:"outcome"`
+}
+
+// Store 下载记录，保存在本地数据库中
+//
+// Record 只把记录放入队列，由后台 goroutine 批量写入，不阻塞下载请求。
+type Store struct {
+	db    *bolt.DB
+	queue *queue[Download]
+}
+
+// New 创建下载记录存储并启动写入 goroutine
+func New(db *bolt.DB) (*Store, error) {
+	if err := database.CreateBuckets(db, string(bucketDownloads)); err != nil {
+		return nil, err
+	}
+	s := &Store{db: db}
+	s.queue = newQueue("downloads", s.write)
+	return s, nil
+}
+
+// Record 记录一次下载，队列已满或已关闭时丢弃
+func (s *Store) Record(d Download) {
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	s.queue.add(d)
+}
+
+// Close 写入队列中剩余的记录并停止写入 goroutine (不关闭数据库)
+func (s *Store) Close() {
+	s.queue.close()
+}
+
+func (s *Store) write(batch []Download) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDownloads)
+		for _, d := range batch {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(eventKey(d.Time, seq), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// eventKey 按时间排序的 key：8 字节纳秒时间戳 + 8 字节序号
+func eventKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// Prune 删除 before 之前的下载记录，返回删除的条数
+func (s *Store) Prune(before time.Time) (int, error) {
+	end := eventKey(before, 0)
+	deleted := 0
+	// 分批删除，避免单个事务过大
+	for {
+		n := 0
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			// 删除后游标位置不确定，每次重新定位到第一条
+			c := tx.Bucket(bucketDownloads).Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0 && n < 10000; k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		deleted += n
+		if err != nil || n < 10000 {
+			return deleted, err
+		}
+	}
+}
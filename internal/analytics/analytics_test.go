@@ -0,0 +1,156 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"update-server/internal/database"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return s
+}
+
+func collect(t *testing.T, s *Store, q Query) []Download {
+	t.Helper()
+	var got []Download
+	if err := s.Events(q, func(d Download) error {
+		got = append(got, d)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.Record(Download{Time: base.Add(time.Minute), Brand: "b", File: "second.zip", Outcome: OutcomeCompleted})
+	s.Record(Download{Time: base, Brand: "a", InviteCode: "X1", File: "first.zip", Outcome: OutcomeAborted})
+	s.Close()
+	// 关闭后的记录被丢弃
+	s.Record(Download{Time: base, File: "dropped.zip"})
+
+	got := collect(t, s, Query{From: base.Add(-time.Hour), To: base.Add(time.Hour)})
+	if len(got) != 2 || got[0].File != "first.zip" || got[1].File != "second.zip" {
+		t.Fatalf("期望按时间排序的 2 条记录, 得到 %+v", got)
+	}
+	if got[0].InviteCode != "X1" || got[0].Outcome != OutcomeAborted || !got[0].Time.Equal(base) {
+		t.Errorf("记录内容不匹配: %+v", got[0])
+	}
+
+	got = collect(t, s, Query{From: base.Add(-time.Hour), To: base.Add(time.Hour), Brand: "b"})
+	if len(got) != 1 || got[0].File != "second.zip" {
+		t.Errorf("按品牌过滤: 得到 %+v", got)
+	}
+	got = collect(t, s, Query{From: base.Add(time.Second), To: base.Add(time.Hour)})
+	if len(got) != 1 || got[0].File != "second.zip" {
+		t.Errorf("按时间过滤: 得到 %+v", got)
+	}
+}
+
+func TestEvents_Chunks(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+
+	// 超过单个读事务的条数，分多次读取时不重复、不遗漏
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := make([]Download, 2*scanChunk+10)
+	for i := range batch {
+		batch[i] = Download{Time: base.Add(time.Duration(i) * time.Second), Bytes: int64(i)}
+	}
+	if err := s.write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, s, Query{From: base, To: base.Add(time.Hour)})
+	if len(got) != len(batch) {
+		t.Fatalf("期望 %d 条, 得到 %d", len(batch), len(got))
+	}
+	for i, d := range got {
+		if d.Bytes != int64(i) {
+			t.Fatalf("第 %d 条顺序错误: %+v", i, d)
+		}
+	}
+}
+
+func TestDaily(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+
+	// UTC 16:00 在 Asia/Shanghai 已是第二天
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := s.write([]Download{
+		{Time: day.Add(10 * time.Hour), Brand: "a", InviteCode: "X", Outcome: OutcomeCompleted, Bytes: 100},
+		{Time: day.Add(11 * time.Hour), Brand: "a", InviteCode: "X", Outcome: OutcomeAborted, Bytes: 10},
+		{Time: day.Add(12 * time.Hour), Brand: "a", InviteCode: "Y", Outcome: OutcomeRedirected},
+		{Time: day.Add(16 * time.Hour), Brand: "a", InviteCode: "X", Outcome: OutcomePartial, Bytes: 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	days, err := s.Daily(Query{From: day, To: day.AddDate(0, 0, 2)}, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Daily{
+		{Date: "2024-01-01", Brand: "a", InviteCode: "X", Requests: 2, Completed: 1, Aborted: 1, Bytes: 110},
+		{Date: "2024-01-01", Brand: "a", InviteCode: "Y", Requests: 1, Redirected: 1},
+		{Date: "2024-01-02", Brand: "a", InviteCode: "X", Requests: 1, Partial: 1, Bytes: 5},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("期望 %+v, 得到 %+v", want, days)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("第 %d 行: 期望 %+v, 得到 %+v", i, want[i], days[i])
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	s := newTestStore(t)
+
+	now := time.Now()
+	if err := s.write([]Download{
+		{Time: now.Add(-48 * time.Hour), File: "old1"},
+		{Time: now.Add(-25 * time.Hour), File: "old2"},
+		{Time: now.Add(-time.Hour), File: "new"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("期望删除 2 条, 得到 %d", n)
+	}
+	got := collect(t, s, Query{From: now.Add(-72 * time.Hour), To: now})
+	if len(got) != 1 || got[0].File != "new" {
+		t.Errorf("期望只剩 new, 得到 %+v", got)
+	}
+}
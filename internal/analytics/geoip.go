@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP 按 IP 查询国家代码 (MaxMind Country 数据库)
+//
+// 数据库整个读入内存而不是 mmap，配置变更替换时无需关闭旧的，正在进行的查询不受影响。
+type GeoIP struct {
+	reader *maxminddb.Reader
+}
+
+// OpenGeoIP 打开 mmdb 数据库
+func OpenGeoIP(path string) (*GeoIP, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 GeoIP 数据库失败: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析 GeoIP 数据库 %s 失败: %w", path, err)
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+// Country 返回 IP 所在国家的 ISO 3166-1 两位代码，查不到时返回空字符串
+func (g *GeoIP) Country(addr netip.Addr) string {
+	if g == nil || !addr.IsValid() {
+		return ""
+	}
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		// 没有国家信息时 (如部分 Anycast 地址) 使用注册国家
+		RegisteredCountry struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"registered_country"`
+	}
+	if err := g.reader.Lookup(addr.Unmap().AsSlice(), &record); err != nil {
+		return ""
+	}
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode
+	}
+	return record.RegisteredCountry.ISOCode
+}
@@ -0,0 +1,294 @@
+package analytics
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"update-server/internal/database"
+)
+
+var (
+	bucketInstalls = []byte("installs")
+	bucketMeta     = []byte("meta")
+	keyInstallSalt = []byte("install_salt")
+)
+
+// hashSize 安装 ID 哈希截取的字节数
+const hashSize = 16
+
+// Checkin 一次检查更新 (活跃安装的信号)
+type Checkin struct {
+	Day       string // 统计日期 YYYY-MM-DD (analytics.timezone)
+	InstallID string // 客户端生成的匿名安装 ID，只保存其哈希
+	Version   string
+	Platform  string
+	Arch      string
+	OSVersion string
+	Brand     string
+	Channel   string
+}
+
+// activeInstall 某个安装在某天的状态 (当天最后一次检查更新时的信息)
+type activeInstall struct {
+	Version   string `json:"version"`
+	Platform  string `json:"platform,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+	OSVersion string `json:"os_version,omitempty"`
+	Brand     string `json:"brand,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	Checks    int    `json:"checks"`
+}
+
+// Installs 活跃安装统计
+//
+// 每个安装每天一条记录 (key 为日期 + 安装 ID 的 HMAC)，不保存原始安装 ID 和 IP。
+// HMAC 密钥首次启动时随机生成并保存在数据库中，同一安装跨天的哈希相同，用于按月去重。
+type Installs struct {
+	db    *bolt.DB
+	salt  []byte
+	queue *queue[Checkin]
+}
+
+// NewInstalls 创建活跃安装统计并启动写入 goroutine
+func NewInstalls(db *bolt.DB) (*Installs, error) {
+	if err := database.CreateBuckets(db, string(bucketInstalls), string(bucketMeta)); err != nil {
+		return nil, err
+	}
+	var salt []byte
+	err := db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if v := meta.Get(keyInstallSalt); v != nil {
+			salt = append([]byte(nil), v...)
+			return nil
+		}
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		return meta.Put(keyInstallSalt, salt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Installs{db: db, salt: salt}
+	s.queue = newQueue("installs", s.write)
+	return s, nil
+}
+
+// Record 记录一次检查更新，队列已满或已关闭时丢弃
+func (s *Installs) Record(c Checkin) {
+	s.queue.add(c)
+}
+
+// Close 写入队列中剩余的记录并停止写入 goroutine (不关闭数据库)
+func (s *Installs) Close() {
+	s.queue.close()
+}
+
+func (s *Installs) key(day, installID string) []byte {
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(installID))
+	return append([]byte(day), mac.Sum(nil)[:hashSize]...)
+}
+
+func (s *Installs) write(batch []Checkin) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketInstalls)
+		for _, c := range batch {
+			key := s.key(c.Day, c.InstallID)
+			var a activeInstall
+			if v := b.Get(key); v != nil {
+				if err := json.Unmarshal(v, &a); err != nil {
+					return err
+				}
+			}
+			a.Version, a.Platform, a.Arch, a.OSVersion = c.Version, c.Platform, c.Arch, c.OSVersion
+			a.Brand, a.Channel = c.Brand, c.Channel
+			a.Checks++
+			value, err := json.Marshal(a)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Prune 删除 before (YYYY-MM-DD) 之前的记录，返回删除的条数
+func (s *Installs) Prune(before string) (int, error) {
+	end := []byte(before)
+	deleted := 0
+	for {
+		n := 0
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			c := tx.Bucket(bucketInstalls).Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0 && n < 10000; k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				n++
+			}
+			return nil
+		})
+		deleted += n
+		if err != nil || n < 10000 {
+			return deleted, err
+		}
+	}
+}
+
+// InstallQuery 查询条件，From/To 为日期 (YYYY-MM-DD，包含两端)，其余为空时不过滤
+type InstallQuery struct {
+	From     string
+	To       string
+	Brand    string
+	Platform string
+	Channel  string
+}
+
+func (q InstallQuery) match(a *activeInstall) bool {
+	return (q.Brand == "" || a.Brand == q.Brand) &&
+		(q.Platform == "" || a.Platform == q.Platform) &&
+		(q.Channel == "" || a.Channel == q.Channel)
+}
+
+// scan 按日期顺序遍历记录，fn 的参数为日期和安装 ID 哈希
+func (s *Installs) scan(q InstallQuery, fn func(day string, hash string, a *activeInstall)) error {
+	start := []byte(q.From)
+	for start != nil {
+		var next []byte
+		err := s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(bucketInstalls).Cursor()
+			n := 0
+			for k, v := c.Seek(start); k != nil && len(k) > 10 && string(k[:10]) <= q.To; k, v = c.Next() {
+				if n == scanChunk {
+					next = append([]byte(nil), k...)
+					return nil
+				}
+				n++
+				var a activeInstall
+				if err := json.Unmarshal(v, &a); err != nil {
+					return err
+				}
+				if q.match(&a) {
+					fn(string(k[:10]), string(k[10:]), &a)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		start = next
+	}
+	return nil
+}
+
+// 统计周期
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// Active 某个周期 (日或月) 的活跃安装数
+type Active struct {
+	Period   string         `json:"period" example:"2024-01-02"` // YYYY-MM-DD 或 YYYY-MM
+	Total    int            `json:"total"`                       // 活跃安装数
+	Versions map[string]int `json:"versions"`                    // 各版本的活跃安装数
+}
+
+// Active 按日 (DAU) 或按月 (MAU) 统计活跃安装，按周期排序
+//
+// 按月统计时同一安装只计一次，版本取该月最后一次检查更新时的版本。
+func (s *Installs) Active(q InstallQuery, period string) ([]Active, error) {
+	periodOf := func(day string) string { return day }
+	if period == PeriodMonth {
+		periodOf = func(day string) string { return day[:7] }
+	}
+
+	// 周期 -> 安装哈希 -> 版本；按日期顺序遍历，后面的覆盖前面的
+	seen := make(map[string]map[string]string)
+	err := s.scan(q, func(day, hash string, a *activeInstall) {
+		p := periodOf(day)
+		if seen[p] == nil {
+			seen[p] = make(map[string]string)
+		}
+		seen[p][hash] = a.Version
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Active, 0, len(seen))
+	for p, installs := range seen {
+		a := Active{Period: p, Total: len(installs), Versions: make(map[string]int)}
+		for _, v := range installs {
+			a.Versions[v]++
+		}
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Period < result[j].Period })
+	return result, nil
+}
+
+// FirstSeen 版本第一次出现在活跃安装中的日期 (近似发布日期)，没有记录时返回空字符串
+func (s *Installs) FirstSeen(version string) (string, error) {
+	var first string
+	err := s.scan(InstallQuery{To: "9999-12-31"}, func(day, _ string, a *activeInstall) {
+		if first == "" && a.Version == version {
+			first = day
+		}
+	})
+	return first, err
+}
+
+// AdoptionPoint 版本发布后某一天的采用率
+type AdoptionPoint struct {
+	Date     string  `json:"date" example:"2024-01-02"`
+	Installs int     `json:"installs"` // 使用该版本的活跃安装数
+	Total    int     `json:"total"`    // 当天全部活跃安装数
+	Share    float64 `json:"share"`    // installs / total
+}
+
+// Adoption 从 q.From 到 q.To 每天使用 version 的活跃安装占比，没有活跃安装的日期也会列出
+func (s *Installs) Adoption(version string, q InstallQuery) ([]AdoptionPoint, error) {
+	daily, err := s.Active(q, PeriodDay)
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]Active, len(daily))
+	for _, a := range daily {
+		byDay[a.Period] = a
+	}
+
+	from, err := time.Parse(time.DateOnly, q.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(time.DateOnly, q.To)
+	if err != nil {
+		return nil, err
+	}
+	var points []AdoptionPoint
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format(time.DateOnly)
+		a := byDay[date]
+		p := AdoptionPoint{Date: date, Installs: a.Versions[version], Total: a.Total}
+		if p.Total > 0 {
+			p.Share = float64(p.Installs) / float64(p.Total)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
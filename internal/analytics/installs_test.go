@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"bytes"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"path/filepath"
+	"update-server/internal/database"
+)
+
+func newTestInstalls(t *testing.T) (*Installs, *bolt.DB) {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewInstalls(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return s, db
+}
+
+func TestInstalls_Active(t *testing.T) {
+	t.Parallel()
+	s, db := newTestInstalls(t)
+
+	s.Record(Checkin{Day: "2024-01-30", InstallID: "install-a", Version: "v1.0.0", Platform: "windows"})
+	s.Record(Checkin{Day: "2024-01-30", InstallID: "install-a", Version: "v1.0.0", Platform: "windows"})
+	s.Record(Checkin{Day: "2024-01-30", InstallID: "install-b", Version: "v1.0.0", Platform: "android"})
+	s.Record(Checkin{Day: "2024-01-31", InstallID: "install-a", Version: "v1.1.0", Platform: "windows"})
+	s.Record(Checkin{Day: "2024-02-01", InstallID: "install-a", Version: "v1.1.0", Platform: "windows"})
+	s.Close()
+
+	// 不保存原始安装 ID
+	db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketInstalls).ForEach(func(k, v []byte) error {
+			if bytes.Contains(k, []byte("install-")) || bytes.Contains(v, []byte("install-")) {
+				t.Errorf("记录中包含原始安装 ID: %q", k)
+			}
+			return nil
+		})
+	})
+
+	daily, err := s.Active(InstallQuery{From: "2024-01-01", To: "2024-01-31"}, PeriodDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 2 || daily[0].Total != 2 || daily[0].Versions["v1.0.0"] != 2 || daily[1].Total != 1 || daily[1].Versions["v1.1.0"] != 1 {
+		t.Errorf("日活不匹配: %+v", daily)
+	}
+
+	// 按月去重，版本取当月最后一次
+	monthly, err := s.Active(InstallQuery{From: "2024-01-01", To: "2024-02-29"}, PeriodMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monthly) != 2 || monthly[0].Period != "2024-01" || monthly[0].Total != 2 ||
+		monthly[0].Versions["v1.0.0"] != 1 || monthly[0].Versions["v1.1.0"] != 1 ||
+		monthly[1].Period != "2024-02" || monthly[1].Total != 1 {
+		t.Errorf("月活不匹配: %+v", monthly)
+	}
+
+	filtered, err := s.Active(InstallQuery{From: "2024-01-01", To: "2024-01-31", Platform: "android"}, PeriodMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 || filtered[0].Total != 1 {
+		t.Errorf("按平台过滤不匹配: %+v", filtered)
+	}
+
+	first, err := s.FirstSeen("v1.1.0")
+	if err != nil || first != "2024-01-31" {
+		t.Errorf("期望 2024-01-31, 得到 %q %v", first, err)
+	}
+
+	points, err := s.Adoption("v1.1.0", InstallQuery{From: "2024-01-30", To: "2024-02-02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AdoptionPoint{
+		{Date: "2024-01-30", Installs: 0, Total: 2},
+		{Date: "2024-01-31", Installs: 1, Total: 1, Share: 1},
+		{Date: "2024-02-01", Installs: 1, Total: 1, Share: 1},
+		{Date: "2024-02-02"},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("期望 %+v, 得到 %+v", want, points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("第 %d 天: 期望 %+v, 得到 %+v", i, want[i], points[i])
+		}
+	}
+}
+
+func TestInstalls_SaltAndPrune(t *testing.T) {
+	t.Parallel()
+	s, db := newTestInstalls(t)
+
+	// 重新打开时沿用同一个密钥，同一安装的哈希不变
+	again, err := NewInstalls(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Close()
+	if !bytes.Equal(s.key("2024-01-01", "id"), again.key("2024-01-01", "id")) {
+		t.Error("重新打开后哈希发生变化")
+	}
+
+	if err := s.write([]Checkin{
+		{Day: "2024-01-01", InstallID: "install-a", Version: "v1"},
+		{Day: "2024-01-02", InstallID: "install-a", Version: "v1"},
+		{Day: "2024-01-03", InstallID: "install-a", Version: "v1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Prune("2024-01-03")
+	if err != nil || n != 2 {
+		t.Errorf("期望删除 2 条, 得到 %d %v", n, err)
+	}
+}
@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// 队列长度和每个事务最多写入的条数
+const (
+	queueSize = 1024
+	batchSize = 256
+)
+
+// queue 由后台 goroutine 批量写入数据库的记录队列，请求只负责入队，不等待磁盘
+type queue[T any] struct {
+	name  string
+	write func([]T) error
+
+	mu     sync.Mutex
+	closed bool
+	items  chan T
+	done   chan struct{}
+}
+
+func newQueue[T any](name string, write func([]T) error) *queue[T] {
+	q := &queue[T]{
+		name:  name,
+		write: write,
+		items: make(chan T, queueSize),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// add 入队，队列已满或已关闭时丢弃
+func (q *queue[T]) add(item T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.items <- item:
+	default:
+		slog.Warn("记录队列已满，丢弃记录", "queue", q.name)
+	}
+}
+
+// close 写入剩余的记录并停止写入 goroutine
+func (q *queue[T]) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.items)
+	q.mu.Unlock()
+	<-q.done
+}
+
+// run 每次取出队列中已有的全部记录 (最多 batchSize 条)，在一个事务中写入
+func (q *queue[T]) run() {
+	defer close(q.done)
+	for item := range q.items {
+		batch := []T{item}
+	drain:
+		for len(batch) < batchSize {
+			select {
+			case item, ok := <-q.items:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, item)
+			default:
+				break drain
+			}
+		}
+		if err := q.write(batch); err != nil {
+			slog.Error("写入记录失败", "queue", q.name, "count", len(batch), "err", err)
+		}
+	}
+}
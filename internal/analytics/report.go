@@ -0,0 +1,128 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 每个读事务最多读取的记录数，导出大量记录时不长时间占用读事务 (会阻止数据库文件回收空间)
+const scanChunk = 1000
+
+// Query 查询条件，From 包含、To 不包含；Brand/InviteCode 为空时不过滤
+type Query struct {
+	From       time.Time
+	To         time.Time
+	Brand      string
+	InviteCode string
+}
+
+func (q Query) match(d *Download) bool {
+	return (q.Brand == "" || d.Brand == q.Brand) && (q.InviteCode == "" || d.InviteCode == q.InviteCode)
+}
+
+// Events 按时间顺序遍历符合条件的下载记录，fn 返回错误时停止并返回该错误
+func (s *Store) Events(q Query, fn func(Download) error) error {
+	start := eventKey(q.From, 0)
+	end := eventKey(q.To, 0)
+
+	for start != nil {
+		var batch []Download
+		var next []byte
+		err := s.db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket(bucketDownloads).Cursor()
+			for k, v := c.Seek(start); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+				if len(batch) == scanChunk {
+					next = append([]byte(nil), k...)
+					return nil
+				}
+				var d Download
+				if err := json.Unmarshal(v, &d); err != nil {
+					return err
+				}
+				if q.match(&d) {
+					batch = append(batch, d)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, d := range batch {
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		start = next
+	}
+	return nil
+}
+
+// Daily 某天某个品牌、邀请码的下载汇总
+type Daily struct {
+	Date       string `json:"date" example:"2024-01-02"`
+	Brand      string `json:"brand" example:"orange"`
+	InviteCode string `json:"invite_code" example:"ABC123"`
+	Requests   int    `json:"requests"`   // 下载请求数 (含未完成的)
+	Completed  int    `json:"completed"`  // 完整下载
+	Partial    int    `json:"partial"`    // 分段下载的中间分段
+	Aborted    int    `json:"aborted"`    // 客户端中途断开
+	Redirected int    `json:"redirected"` // 重定向到对象存储
+	Failed     int    `json:"failed"`     // 服务端错误
+	Bytes      int64  `json:"bytes"`      // 本服务实际发送的字节数
+}
+
+type dailyKey struct {
+	date, brand, invite string
+}
+
+// Daily 按天 (loc 时区)、品牌、邀请码汇总下载记录，按日期、品牌、邀请码排序
+func (s *Store) Daily(q Query, loc *time.Location) ([]Daily, error) {
+	groups := make(map[dailyKey]*Daily)
+	err := s.Events(q, func(d Download) error {
+		k := dailyKey{d.Time.In(loc).Format(time.DateOnly), d.Brand, d.InviteCode}
+		g := groups[k]
+		if g == nil {
+			g = &Daily{Date: k.date, Brand: k.brand, InviteCode: k.invite}
+			groups[k] = g
+		}
+		g.Requests++
+		g.Bytes += d.Bytes
+		switch d.Outcome {
+		case OutcomeCompleted:
+			g.Completed++
+		case OutcomePartial:
+			g.Partial++
+		case OutcomeAborted:
+			g.Aborted++
+		case OutcomeRedirected:
+			g.Redirected++
+		case OutcomeFailed:
+			g.Failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Daily, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Brand != b.Brand {
+			return a.Brand < b.Brand
+		}
+		return a.InviteCode < b.InviteCode
+	})
+	return result, nil
+}
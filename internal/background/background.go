@@ -0,0 +1,60 @@
+package background
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Group 后台任务组
+//
+// 组内任务 (缓存同步、定时刷新、配置监听等) 共享同一个根 context，
+// 关闭服务时统一取消并等待退出。
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建后台任务组
+func New() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Context 返回后台任务的根 context
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Go 启动一个后台任务，fn 应在 ctx 取消后尽快返回
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("后台任务 panic", "task", name, "panic", r)
+			}
+		}()
+		fn(g.ctx)
+	}()
+}
+
+// Shutdown 取消所有后台任务并等待退出，超过 waitCtx 期限时返回其错误
+func (g *Group) Shutdown(waitCtx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-waitCtx.Done():
+		return waitCtx.Err()
+	}
+}
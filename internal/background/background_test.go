@@ -0,0 +1,29 @@
+package background
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdown_CancelsAndWaits(t *testing.T) {
+	g := New()
+	exited := make(chan struct{})
+	g.Go("test", func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(exited)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := g.Shutdown(ctx); err != nil {
+		t.Fatalf("期望后台任务正常退出, 得到 %v", err)
+	}
+
+	select {
+	case <-exited:
+	default:
+		t.Error("Shutdown 返回前后台任务应已退出")
+	}
+}
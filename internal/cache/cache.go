@@ -1,108 +1,330 @@
 package cache
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"update-server/internal/config"
-	"update-server/internal/github"
+	"update-server/internal/httpclient"
+	"update-server/internal/release"
+	"update-server/internal/storage"
+	"update-server/internal/tracing"
 )
 
-// Sync 同步最新版本的所有文件到本地缓存
-func Sync() error {
-	release, err := github.FetchLatestRelease()
+// Cache Release 文件缓存
+type Cache struct {
+	cfg      *config.Store
+	releases release.Source
+	outbound httpclient.Pool // 镜像下载用的 HTTP 客户端
+	health   health
+
+	// 最近一个版本的校验文件内容 (文件名 -> SHA-256)
+	sumsMu  sync.Mutex
+	sumsTag string
+	sums    map[string]string
+
+	mu    sync.RWMutex
+	store storage.Storage
+
+	flightsMu sync.Mutex
+	flights   map[string]*flight
+
+	syncMu   sync.Mutex
+	lastSync SyncStatus
+}
+
+// SyncStatus 最近一次同步的状态
+type SyncStatus struct {
+	Tag        string    `json:"tag,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Running    bool      `json:"running"`
+	Failed     int       `json:"failed"` // 下载失败的文件数
+	Error      string    `json:"error,omitempty"`
+}
+
+// New 按配置创建缓存，存储后端由 storage 配置决定
+func New(cfg *config.Store, releases release.Source) (*Cache, error) {
+	store, err := storage.New(cfg.Get())
 	if err != nil {
-		return fmt.Errorf("获取 release 失败: %w", err)
+		return nil, err
 	}
+	return &Cache{
+		cfg:      cfg,
+		releases: releases,
+		store:    store,
+		flights:  make(map[string]*flight),
+	}, nil
+}
+
+// Storage 返回当前存储后端
+func (c *Cache) Storage() storage.Storage {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.store
+}
+
+// SetStorage 替换存储后端 (配置重载时使用)
+func (c *Cache) SetStorage(store storage.Storage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = store
+}
 
-	cfg := config.Get()
-	cacheDir := filepath.Join(cfg.CacheDir, release.TagName)
+// Sync 同步最新版本的所有文件到本地缓存，ctx 取消时中止未完成的下载
+func (c *Cache) Sync(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "cache.Sync")
+	status := SyncStatus{StartedAt: time.Now(), Running: true}
+	c.setSyncStatus(status)
+	var failed atomic.Int32
+	defer func() {
+		status.FinishedAt, status.Running, status.Failed = time.Now(), false, int(failed.Load())
+		if err != nil {
+			status.Error = err.Error()
+		} else if status.Failed > 0 {
+			status.Error = fmt.Sprintf("%d 个文件下载失败", status.Failed)
+		}
+		c.setSyncStatus(status)
+		span.SetAttributes(attribute.String("release.tag", status.Tag), attribute.Int("cache.failed", status.Failed))
+		tracing.End(span, err)
+	}()
 
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		return fmt.Errorf("创建缓存目录失败: %w", err)
+	rel, err := c.releases.LatestRelease(ctx)
+	if err != nil {
+		return fmt.Errorf("获取 release 失败: %w", err)
 	}
+	status.Tag = rel.Tag
+
+	cfg := c.cfg.Get()
+	store := c.Storage()
+
+	slog.InfoContext(ctx, "开始同步版本文件", "tag", rel.Tag, "assets", len(rel.Assets), "concurrency", cfg.Cache.Concurrency)
 
-	log.Printf("开始同步版本 %s 的文件 (%d 个)", release.TagName, len(release.Assets))
+	// 限制并发下载数
+	sem := make(chan struct{}, cfg.Cache.Concurrency)
+	var wg sync.WaitGroup
 
-	for _, asset := range release.Assets {
-		cachePath := filepath.Join(cacheDir, asset.Name)
+	for _, asset := range rel.Assets {
+		if ctx.Err() != nil {
+			break
+		}
+
+		key := storage.Key(rel.Tag, asset.Name)
 
-		// 检查文件是否已存在且大小一致
-		if info, err := os.Stat(cachePath); err == nil {
-			if info.Size() == asset.Size {
-				log.Printf("  [跳过] %s (已缓存)", asset.Name)
+		// 检查文件是否已存在且大小一致 (来源不提供大小时只检查是否存在)
+		if info, err := store.Stat(ctx, key); err == nil {
+			if asset.Size == 0 || info.Size == asset.Size {
+				slog.DebugContext(ctx, "跳过已缓存的文件", "asset", asset.Name)
 				continue
 			}
 		}
 
-		log.Printf("  [下载] %s (%d MB)", asset.Name, asset.Size/1024/1024)
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(asset release.Asset) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
 
-		downloadURL := fmt.Sprintf("https://github.com/%s/releases/download/%s/%s",
-			cfg.Release.Repo, release.TagName, asset.Name)
+			slog.InfoContext(ctx, "下载文件", "asset", asset.Name, "size", asset.Size)
 
-		if err := downloadFile(downloadURL, cachePath, cfg.Release.Token); err != nil {
-			log.Printf("  [失败] %s: %v", asset.Name, err)
-			continue
-		}
+			if err := c.download(ctx, store, rel, asset); err != nil {
+				slog.ErrorContext(ctx, "下载文件失败", "asset", asset.Name, "err", err)
+				failed.Add(1)
+				return
+			}
+
+			slog.InfoContext(ctx, "下载完成", "asset", asset.Name)
 
-		log.Printf("  [完成] %s", asset.Name)
+			// 强制 GC 并释放内存给操作系统
+			debug.FreeOSMemory()
+		}(asset)
+	}
+	wg.Wait()
 
-		// 强制 GC 并释放内存给操作系统
-		debug.FreeOSMemory()
+	if err := ctx.Err(); err != nil {
+		slog.WarnContext(ctx, "同步已取消", "tag", rel.Tag)
+		return err
 	}
 
-	log.Printf("版本 %s 同步完成", release.TagName)
+	slog.InfoContext(ctx, "同步完成", "tag", rel.Tag)
 	return nil
 }
 
-func downloadFile(url, dest, token string) error {
-	req, err := http.NewRequest("GET", url, nil)
+// download 下载文件写入存储：先从来源下载，失败时按顺序尝试镜像
+//
+// 已知校验值时对每个地址的内容都做校验；没有可信校验值时默认不使用镜像。
+// 处于冷却期的地址会被跳过，全部处于冷却期时仍逐个尝试。
+func (c *Cache) download(ctx context.Context, store storage.Storage, rel *release.Release, asset release.Asset) (err error) {
+	ctx, span := tracing.Start(ctx, "cache.download",
+		attribute.String("release.tag", rel.Tag),
+		attribute.String("release.asset", asset.Name),
+		attribute.Int64("release.asset.size", asset.Size),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	cfg := c.cfg.Get()
+	key := storage.Key(rel.Tag, asset.Name)
+
+	sum, err := c.checksum(ctx, cfg, rel, asset)
 	if err != nil {
-		return err
-	}
-	if token != "" {
-		req.Header.Set("Authorization", "token "+token)
+		slog.WarnContext(ctx, "获取校验值失败", "asset", asset.Name, "err", err)
 	}
 
-	// 禁用连接复用，每次下载后释放连接
-	transport := &http.Transport{
-		DisableKeepAlives: true,
+	candidates := c.upstreams(cfg, rel, asset)
+	var healthy []upstream
+	for _, u := range candidates {
+		if c.health.available(u.name) {
+			healthy = append(healthy, u)
+		}
 	}
-	client := &http.Client{
-		Timeout:   30 * time.Minute,
-		Transport: transport,
+	if len(healthy) > 0 {
+		candidates = healthy
+	}
+
+	var errs []error
+	for _, u := range candidates {
+		if u.mirror && sum == "" && !cfg.Mirrors.AllowUnverified {
+			errs = append(errs, fmt.Errorf("%s: 没有可信的校验值，跳过镜像", u.name))
+			continue
+		}
+		if u.mirror {
+			slog.InfoContext(ctx, "从镜像下载", "asset", asset.Name, "upstream", u.name)
+		}
+
+		err := c.put(ctx, store, key, u, sum)
+		if err == nil {
+			c.health.success(u.name)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.health.failure(u.name, err, cfg.Mirrors.MaxFailures, cfg.Mirrors.Cooldown)
+		slog.WarnContext(ctx, "下载地址失败", "asset", asset.Name, "upstream", u.name, "err", err)
+		errs = append(errs, fmt.Errorf("%s: %w", u.name, err))
 	}
-	resp, err := client.Do(req)
+	return errors.Join(errs...)
+}
+
+// put 从一个下载地址读取文件写入存储，sum 不为空时校验内容
+//
+// span 覆盖从来源读取和写入存储的全过程，子 span 中的出站请求记录来源的响应时间。
+func (c *Cache) put(ctx context.Context, store storage.Storage, key string, u upstream, sum string) (err error) {
+	ctx, span := tracing.Start(ctx, "cache.put",
+		attribute.String("cache.key", key),
+		attribute.String("cache.upstream", u.name),
+		attribute.Bool("cache.mirror", u.mirror),
+		attribute.Bool("cache.verify", sum != ""),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	body, size, err := u.open(ctx)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	defer transport.CloseIdleConnections()
+	defer body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	if sum == "" {
+		return store.Put(ctx, key, body, size)
 	}
 
-	tmpPath := dest + ".tmp"
-	f, err := os.Create(tmpPath)
+	f, n, err := spoolVerified(body, sum)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	return store.Put(ctx, key, f, n)
+}
 
-	// 使用固定 32KB buffer 避免内存膨胀
-	buf := make([]byte, 32*1024)
-	_, err = io.CopyBuffer(f, resp.Body, buf)
-	f.Close()
-	if err != nil {
-		os.Remove(tmpPath)
-		return err
+func (c *Cache) setSyncStatus(status SyncStatus) {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.lastSync = status
+}
+
+// SyncStatus 返回最近一次同步的状态
+func (c *Cache) SyncStatus() SyncStatus {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	return c.lastSync
+}
+
+// Upstreams 返回出现过失败的下载地址 (来源和镜像) 的健康状态
+func (c *Cache) Upstreams() []UpstreamStatus {
+	return c.health.snapshot()
+}
+
+// flight 一次进行中的按需下载，多个请求同一文件的客户端共享
+type flight struct {
+	done    chan struct{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Fetch 按需下载文件到缓存，同一 key 的并发请求只下载一次
+//
+// 下载不随单个请求结束：发起请求的客户端断开后，只要还有其他客户端在等待
+// 就继续下载；最后一个等待者离开 (包括关闭服务时强制断开连接) 时取消下载。
+func (c *Cache) Fetch(ctx context.Context, rel *release.Release, asset release.Asset) (err error) {
+	key := storage.Key(rel.Tag, asset.Name)
+	ctx, span := tracing.Start(ctx, "cache.Fetch", attribute.String("cache.key", key))
+	defer func() { tracing.End(span, err) }()
+
+	c.flightsMu.Lock()
+	f, ok := c.flights[key]
+	// 加入已有的下载时，下载的 span 属于发起下载的请求
+	span.SetAttributes(attribute.Bool("cache.shared", ok))
+	if !ok {
+		// 下载不随发起请求取消，但保留其 context 中的请求 ID，日志可与请求关联
+		dctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		c.flights[key] = f
+
+		store := c.Storage()
+		go func() {
+			f.err = c.download(dctx, store, rel, asset)
+			cancel()
+
+			c.flightsMu.Lock()
+			// 已取消的下载可能已被移除并由新的下载替换
+			if c.flights[key] == f {
+				delete(c.flights, key)
+			}
+			c.flightsMu.Unlock()
+			close(f.done)
+		}()
 	}
+	f.waiters++
+	c.flightsMu.Unlock()
 
-	return os.Rename(tmpPath, dest)
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		c.flightsMu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			slog.InfoContext(ctx, "按需下载已无等待者，取消", "key", key)
+			f.cancel()
+			// 之后的请求重新下载，而不是加入已取消的下载并得到 context.Canceled
+			if c.flights[key] == f {
+				delete(c.flights, key)
+			}
+		}
+		c.flightsMu.Unlock()
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"update-server/internal/config"
+	"update-server/internal/release"
+	"update-server/internal/storage"
+)
+
+// 慢速上游：收到请求后等待 finish 关闭再返回内容
+func slowUpstream(t *testing.T, finish <-chan struct{}, requests *int32, aborted chan<- struct{}) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Length", "7")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-finish:
+			w.Write([]byte("content"))
+		case <-r.Context().Done():
+			close(aborted)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// urlSource 直接按 Asset.URL 下载的测试来源
+type urlSource struct{ release.Source }
+
+func (urlSource) OpenAsset(ctx context.Context, asset release.Asset) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", asset.URL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// newTestCache 创建使用临时目录的缓存，modify 可调整默认配置
+func newTestCache(t *testing.T, modify func(c *config.Config)) *Cache {
+	cfg := config.Default()
+	cfg.Cache.Dir = t.TempDir()
+	if modify != nil {
+		modify(cfg)
+	}
+	c, err := New(config.NewStatic(cfg), urlSource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFetch_SharedAndOutlivesRequester(t *testing.T) {
+	c := newTestCache(t, nil)
+
+	finish := make(chan struct{})
+	var requests int32
+	srv := slowUpstream(t, finish, &requests, make(chan struct{}))
+	rel := &release.Release{Tag: "v1.0.0"}
+	asset := release.Asset{Name: "app.zip", URL: srv.URL}
+
+	// 第一个客户端发起下载后断开
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	err1 := make(chan error, 1)
+	go func() { err1 <- c.Fetch(ctx1, rel, asset) }()
+
+	// 第二个客户端加入等待
+	err2 := make(chan error, 1)
+	time.Sleep(50 * time.Millisecond)
+	go func() { err2 <- c.Fetch(context.Background(), rel, asset) }()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel1()
+	if err := <-err1; err == nil {
+		t.Error("断开的客户端应返回错误")
+	}
+
+	close(finish)
+	if err := <-err2; err != nil {
+		t.Fatalf("仍在等待的客户端应下载成功, 得到 %v", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("期望只请求上游 1 次, 实际 %d 次", n)
+	}
+	if _, err := c.Storage().Stat(context.Background(), storage.Key("v1.0.0", "app.zip")); err != nil {
+		t.Errorf("文件应已缓存: %v", err)
+	}
+}
+
+func TestFetch_CancelWhenNoWaiters(t *testing.T) {
+	c := newTestCache(t, nil)
+
+	var requests int32
+	aborted := make(chan struct{})
+	srv := slowUpstream(t, make(chan struct{}), &requests, aborted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Fetch(ctx, &release.Release{Tag: "v1.0.0"}, release.Asset{Name: "app.zip", URL: srv.URL})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("最后一个等待者离开后应取消上游下载")
+	}
+}
+
+func TestFetch_AfterCancelledFlight(t *testing.T) {
+	c := newTestCache(t, nil)
+
+	finish := make(chan struct{})
+	var requests int32
+	srv := slowUpstream(t, finish, &requests, make(chan struct{}))
+	rel := &release.Release{Tag: "v1.0.0"}
+	asset := release.Asset{Name: "app.zip", URL: srv.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Fetch(ctx, rel, asset) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	// 已取消的下载可能还没退出，新的请求不应加入它
+	close(finish)
+	if err := c.Fetch(context.Background(), rel, asset); err != nil {
+		t.Fatalf("等待者都离开后的新请求应重新下载, 得到 %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("期望请求上游 2 次, 实际 %d 次", n)
+	}
+}
@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"update-server/internal/config"
+	"update-server/internal/release"
+)
+
+// ErrChecksumMismatch 下载内容与校验值不一致
+var ErrChecksumMismatch = errors.New("SHA-256 校验失败")
+
+// originName 健康状态中来源本身的名称
+const originName = "origin"
+
+// upstream 一个下载地址：来源本身或某个镜像
+type upstream struct {
+	name   string // 健康状态的 key：origin 或镜像 URL 模板
+	mirror bool
+	open   func(ctx context.Context) (io.ReadCloser, int64, error)
+}
+
+// upstreams 按顺序列出可尝试的下载地址，来源在前，镜像在后
+func (c *Cache) upstreams(cfg *config.Config, rel *release.Release, asset release.Asset) []upstream {
+	list := []upstream{{
+		name: originName,
+		open: func(ctx context.Context) (io.ReadCloser, int64, error) {
+			return c.releases.OpenAsset(ctx, asset)
+		},
+	}}
+
+	if len(cfg.Mirrors.URLs) == 0 {
+		return list
+	}
+	clients, err := c.outbound.Get(cfg.HTTP)
+	if err != nil {
+		return list
+	}
+	replacer := strings.NewReplacer(
+		"{url}", asset.URL,
+		"{repo}", cfg.Release.Repo,
+		"{tag}", rel.Tag,
+		"{name}", asset.Name,
+	)
+	for _, tmpl := range cfg.Mirrors.URLs {
+		url := replacer.Replace(tmpl)
+		list = append(list, upstream{
+			name:   tmpl,
+			mirror: true,
+			open: func(ctx context.Context) (io.ReadCloser, int64, error) {
+				return openMirror(ctx, clients.Download, url)
+			},
+		})
+	}
+	return list
+}
+
+func openMirror(ctx context.Context, client *http.Client, url string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// checksum 返回文件的可信 SHA-256：来源 API 提供的值，或来源 Release 中校验文件里的值
+//
+// 校验文件只从来源下载，不经过镜像，镜像无法伪造校验值。
+func (c *Cache) checksum(ctx context.Context, cfg *config.Config, rel *release.Release, asset release.Asset) (string, error) {
+	if asset.SHA256 != "" {
+		return strings.ToLower(asset.SHA256), nil
+	}
+
+	c.sumsMu.Lock()
+	defer c.sumsMu.Unlock()
+
+	if c.sumsTag != rel.Tag {
+		sums, err := c.fetchChecksums(ctx, cfg, rel)
+		if err != nil {
+			return "", err
+		}
+		c.sumsTag, c.sums = rel.Tag, sums
+	}
+	return c.sums[asset.Name], nil
+}
+
+func (c *Cache) fetchChecksums(ctx context.Context, cfg *config.Config, rel *release.Release) (map[string]string, error) {
+	sums := make(map[string]string)
+	for _, name := range cfg.Mirrors.ChecksumFiles {
+		file, ok := rel.Asset(name)
+		if !ok {
+			continue
+		}
+		body, _, err := c.releases.OpenAsset(ctx, file)
+		if err != nil {
+			return nil, fmt.Errorf("下载校验文件 %s 失败: %w", name, err)
+		}
+		err = parseChecksums(io.LimitReader(body, 1<<20), sums)
+		body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析校验文件 %s 失败: %w", name, err)
+		}
+	}
+	return sums, nil
+}
+
+// parseChecksums 解析 sha256sum 输出格式: "<hex>  <name>" 或 "<hex> *<name>"
+func parseChecksums(r io.Reader, sums map[string]string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			continue
+		}
+		if _, err := hex.DecodeString(fields[0]); err != nil {
+			continue
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return scanner.Err()
+}
+
+// spoolVerified 把内容下载到临时文件并校验 SHA-256，返回定位到开头的文件和大小
+//
+// 校验通过后才写入存储：按大小上传的后端 (S3) 读够 size 字节即提交对象，
+// 边读边校验无法阻止未校验的内容对外可见。调用方负责关闭并删除返回的文件。
+func spoolVerified(r io.Reader, want string) (*os.File, int64, error) {
+	f, err := os.CreateTemp("", "orange-verify-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != want {
+		err = ErrChecksumMismatch
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, err
+	}
+	return f, n, nil
+}
+
+// health 各下载地址的健康状态
+//
+// 连续失败 max_failures 次后在 cooldown 内跳过该地址，成功一次即恢复。
+type health struct {
+	mu    sync.Mutex
+	state map[string]*upstreamState
+}
+
+type upstreamState struct {
+	failures  int
+	downUntil time.Time
+	lastError string
+}
+
+// UpstreamStatus 下载地址的健康状态
+type UpstreamStatus struct {
+	Name      string    `json:"name"`
+	Failures  int       `json:"failures"`
+	DownUntil time.Time `json:"down_until,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func (h *health) available(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := h.state[name]
+	return st == nil || time.Now().After(st.downUntil)
+}
+
+func (h *health) success(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.state, name)
+}
+
+func (h *health) failure(name string, err error, maxFailures int, cooldown time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.state == nil {
+		h.state = make(map[string]*upstreamState)
+	}
+	st := h.state[name]
+	if st == nil {
+		st = &upstreamState{}
+		h.state[name] = st
+	}
+	st.failures++
+	st.lastError = err.Error()
+	if maxFailures > 0 && st.failures >= maxFailures {
+		st.downUntil = time.Now().Add(cooldown)
+	}
+}
+
+func (h *health) snapshot() []UpstreamStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]UpstreamStatus, 0, len(h.state))
+	for name, st := range h.state {
+		out = append(out, UpstreamStatus{Name: name, Failures: st.failures, DownUntil: st.downUntil, LastError: st.lastError})
+	}
+	return out
+}
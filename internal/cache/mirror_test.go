@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"update-server/internal/config"
+	"update-server/internal/release"
+	"update-server/internal/storage"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// fileServer 按路径返回固定内容，未列出的路径返回 500，并统计请求次数
+func fileServer(t *testing.T, files map[string]string, requests *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func readCached(t *testing.T, c *Cache, tag, name string) string {
+	t.Helper()
+	obj, _, err := c.Storage().Open(context.Background(), storage.Key(tag, name))
+	if err != nil {
+		t.Fatalf("文件应已缓存: %v", err)
+	}
+	defer obj.Close()
+	data, _ := io.ReadAll(obj)
+	return string(data)
+}
+
+func withMirror(mirrorURL string) func(c *config.Config) {
+	return func(c *config.Config) {
+		c.Mirrors.URLs = []string{mirrorURL + "/releases/{tag}/{name}"}
+	}
+}
+
+func TestDownload_MirrorFallback(t *testing.T) {
+	var originRequests, mirrorRequests int32
+	origin := fileServer(t, nil, &originRequests)
+	mirror := fileServer(t, map[string]string{"/releases/v1.0.0/app.zip": "content"}, &mirrorRequests)
+
+	c := newTestCache(t, withMirror(mirror.URL))
+	rel := &release.Release{Tag: "v1.0.0"}
+	asset := release.Asset{Name: "app.zip", URL: origin.URL + "/app.zip", SHA256: sha256Hex("content")}
+
+	if err := c.Fetch(context.Background(), rel, asset); err != nil {
+		t.Fatalf("来源失败时应从镜像下载成功, 得到 %v", err)
+	}
+	if got := readCached(t, c, "v1.0.0", "app.zip"); got != "content" {
+		t.Errorf("期望缓存内容 content, 得到 %q", got)
+	}
+	if originRequests != 1 || mirrorRequests != 1 {
+		t.Errorf("期望来源和镜像各请求 1 次, 得到 %d/%d", originRequests, mirrorRequests)
+	}
+}
+
+func TestDownload_MirrorChecksumMismatch(t *testing.T) {
+	var originRequests, mirrorRequests int32
+	origin := fileServer(t, nil, &originRequests)
+	mirror := fileServer(t, map[string]string{"/releases/v1.0.0/app.zip": "tampered"}, &mirrorRequests)
+
+	c := newTestCache(t, withMirror(mirror.URL))
+	rel := &release.Release{Tag: "v1.0.0"}
+	asset := release.Asset{Name: "app.zip", URL: origin.URL + "/app.zip", SHA256: sha256Hex("content")}
+
+	err := c.Fetch(context.Background(), rel, asset)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("期望校验失败, 得到 %v", err)
+	}
+	if _, err := c.Storage().Stat(context.Background(), storage.Key("v1.0.0", "app.zip")); !errors.Is(err, storage.ErrNotExist) {
+		t.Errorf("校验失败的文件不应留在缓存中, 得到 %v", err)
+	}
+}
+
+// putCounter 统计 Put 调用次数，模拟读够 size 字节即提交对象的后端
+type putCounter struct {
+	storage.Storage
+	puts int32
+}
+
+func (p *putCounter) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	atomic.AddInt32(&p.puts, 1)
+	return p.Storage.Put(ctx, key, r, size)
+}
+
+func TestDownload_MirrorChecksumMismatchNeverPut(t *testing.T) {
+	var originRequests, mirrorRequests int32
+	origin := fileServer(t, nil, &originRequests)
+	mirror := fileServer(t, map[string]string{"/releases/v1.0.0/app.zip": "tampered"}, &mirrorRequests)
+
+	c := newTestCache(t, withMirror(mirror.URL))
+	store := &putCounter{Storage: c.Storage()}
+	c.SetStorage(store)
+	rel := &release.Release{Tag: "v1.0.0"}
+	asset := release.Asset{Name: "app.zip", URL: origin.URL + "/app.zip", SHA256: sha256Hex("content")}
+
+	if err := c.Fetch(context.Background(), rel, asset); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("期望校验失败, 得到 %v", err)
+	}
+	if store.puts != 0 {
+		t.Errorf("校验失败的内容不应写入存储, 实际 Put %d 次", store.puts)
+	}
+}
+
+func TestDownload_MirrorRequiresChecksum(t *testing.T) {
+	var originRequests, mirrorRequests int32
+	origin := fileServer(t, nil, &originRequests)
+	mirror := fileServer(t, map[string]string{"/releases/v1.0.0/app.zip": "content"}, &mirrorRequests)
+
+	c := newTestCache(t, withMirror(mirror.URL))
+	rel := &release.Release{Tag: "v1.0.0"}
+	asset := release.Asset{Name: "app.zip", URL: origin.URL + "/app.zip"}
+
+	if err := c.Fetch(context.Background(), rel, asset); err == nil {
+		t.Error("没有校验值时不应从镜像下载")
+	}
+	if mirrorRequests != 0 {
+		t.Errorf("期望不请求镜像, 实际 %d 次", mirrorRequests)
+	}
+}
+
+func TestDownload_ChecksumFile(t *testing.T) {
+	var originRequests, mirrorRequests int32
+	sums := fmt.Sprintf("%s  app.zip\n%s *other.zip\n", sha256Hex("content"), sha256Hex("other"))
+	origin := fileServer(t, map[string]string{"/SHA256SUMS": sums}, &originRequests)
+	mirror := fileServer(t, map[string]string{"/releases/v1.0.0/app.zip": "content"}, &mirrorRequests)
+
+	c := newTestCache(t, withMirror(mirror.URL))
+	asset := release.Asset{Name: "app.zip", URL: origin.URL + "/app.zip"}
+	rel := &release.Release{Tag: "v1.0.0", Assets: []release.Asset{
+		asset,
+		{Name: "SHA256SUMS", URL: origin.URL + "/SHA256SUMS"},
+	}}
+
+	if err := c.Fetch(context.Background(), rel, asset); err != nil {
+		t.Fatalf("来源 Release 中有校验文件时应允许镜像, 得到 %v", err)
+	}
+	if got := readCached(t, c, "v1.0.0", "app.zip"); got != "content" {
+		t.Errorf("期望缓存内容 content, 得到 %q", got)
+	}
+}
+
+func TestDownload_SkipUnhealthyUpstream(t *testing.T) {
+	var originRequests, mirrorRequests int32
+	origin := fileServer(t, nil, &originRequests)
+	mirror := fileServer(t, map[string]string{
+		"/releases/v1.0.0/a.zip": "a",
+		"/releases/v1.0.0/b.zip": "b",
+	}, &mirrorRequests)
+
+	c := newTestCache(t, func(cfg *config.Config) {
+		withMirror(mirror.URL)(cfg)
+		cfg.Mirrors.MaxFailures = 1
+	})
+	rel := &release.Release{Tag: "v1.0.0"}
+
+	for _, name := range []string{"a.zip", "b.zip"} {
+		asset := release.Asset{Name: name, URL: origin.URL + "/" + name, SHA256: sha256Hex(strings.TrimSuffix(name, ".zip"))}
+		if err := c.Fetch(context.Background(), rel, asset); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 来源失败一次后进入冷却期，第二个文件直接走镜像
+	if originRequests != 1 {
+		t.Errorf("期望来源只请求 1 次, 实际 %d 次", originRequests)
+	}
+	status := c.Upstreams()
+	if len(status) != 1 || status[0].Name != originName || status[0].DownUntil.IsZero() {
+		t.Errorf("期望来源处于冷却期, 得到 %+v", status)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := make(map[string]string)
+	input := sha256Hex("a") + "  a.zip\ninvalid line\n" + strings.ToUpper(sha256Hex("b")) + " *b.zip\n"
+	if err := parseChecksums(strings.NewReader(input), sums); err != nil {
+		t.Fatal(err)
+	}
+	if len(sums) != 2 || sums["b.zip"] != sha256Hex("b") {
+		t.Errorf("解析结果错误: %v", sums)
+	}
+}
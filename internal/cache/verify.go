@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"update-server/internal/release"
+	"update-server/internal/storage"
+	"update-server/internal/tracing"
+)
+
+// VerifyResult 缓存文件的校验结果
+type VerifyResult struct {
+	Key      string `json:"key" example:"v1.2.0/app-windows-amd64.exe"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Expected string `json:"expected,omitempty"` // 来源的校验值，未知时只比较大小
+	OK       bool   `json:"ok"`
+	Removed  bool   `json:"removed,omitempty"` // 校验失败，已从缓存删除 (下次请求时重新下载)
+	Error    string `json:"error,omitempty"`
+}
+
+// Verify 重新计算缓存文件的 SHA-256，与来源的校验值比较 (没有校验值时比较大小)，
+// 不一致时从缓存删除
+func (c *Cache) Verify(ctx context.Context, rel *release.Release, asset release.Asset) (result VerifyResult) {
+	key := storage.Key(rel.Tag, asset.Name)
+	ctx, span := tracing.Start(ctx, "cache.Verify", attribute.String("cache.key", key))
+	defer func() {
+		var err error
+		if result.Error != "" {
+			err = errors.New(result.Error)
+		}
+		tracing.End(span, err)
+	}()
+
+	result.Key = key
+	store := c.Storage()
+	obj, info, err := store.Open(ctx, key)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	h := sha256.New()
+	_, err = io.Copy(h, obj)
+	obj.Close()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Size = info.Size
+	result.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	sum, err := c.checksum(ctx, c.cfg.Get(), rel, asset)
+	if err != nil {
+		result.Error = "获取校验值失败: " + err.Error()
+		return result
+	}
+	result.Expected = sum
+	if sum != "" {
+		result.OK = result.SHA256 == sum
+	} else {
+		result.OK = asset.Size == 0 || asset.Size == info.Size
+	}
+	if result.OK {
+		return result
+	}
+
+	slog.WarnContext(ctx, "缓存文件校验失败，删除", "key", key, "sha256", result.SHA256, "expected", sum, "size", info.Size)
+	if err := store.Delete(ctx, key); err != nil {
+		result.Error = "删除失败: " + err.Error()
+		return result
+	}
+	result.Removed = true
+	return result
+}
@@ -1,24 +1,319 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
-// GitHubRepo GitHub 仓库配置
+// Release 来源类型
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea" // 同样适用于 Forgejo
+	ProviderLocal  = "local" // 本地目录，用于离线部署
+)
+
+// GitHubRepo 代码托管仓库配置 (默认 GitHub，也支持 GitLab、Gitea/Forgejo 和本地目录)
 type GitHubRepo struct {
-	Repo          string `yaml:"repo"`           // owner/repo 格式
-	Token         string `yaml:"token"`          // 访问令牌 (私有仓库需要)
-	WebhookSecret string `yaml:"webhook_secret"` // Webhook 签名密钥
+	Provider      string `yaml:"provider"`                     // github (默认) / gitlab / gitea / forgejo / local
+	BaseURL       string `yaml:"base_url"`                     // GitHub Enterprise 的 API 地址，或 GitLab/Gitea 实例地址
+	Repo          string `yaml:"repo"`                         // owner/repo 格式 (GitLab 为 group/project)
+	Dir           string `yaml:"dir"`                          // 本地 release 目录 (provider 为 local 时使用)
+	Token         string `yaml:"token" secret:"true"`          // 访问令牌 (私有仓库需要)
+	WebhookSecret string `yaml:"webhook_secret" secret:"true"` // Webhook 签名密钥
+
+	// GitHub App 认证，配置后代替 token 使用自动续期的安装令牌
+	AppID          int64  `yaml:"app_id"`
+	InstallationID int64  `yaml:"installation_id"`
+	PrivateKey     string `yaml:"private_key" secret:"true"` // App 私钥 PEM，通常用 private_key_file 指定
+}
+
+// UseApp 是否使用 GitHub App 认证
+func (r GitHubRepo) UseApp() bool {
+	return r.AppID != 0
+}
+
+// S3Storage S3 兼容对象存储配置 (AWS S3 / MinIO / R2 等)
+type S3Storage struct {
+	Endpoint      string        `yaml:"endpoint"`                 // 例如 s3.amazonaws.com 或 minio:9000
+	Region        string        `yaml:"region"`                   // 区域 (MinIO 可留空)
+	Bucket        string        `yaml:"bucket"`                   // 存储桶
+	Prefix        string        `yaml:"prefix"`                   // 对象 key 前缀
+	AccessKey     string        `yaml:"access_key"`               // 访问密钥 ID
+	SecretKey     string        `yaml:"secret_key" secret:"true"` // 访问密钥
+	Insecure      bool          `yaml:"insecure"`                 // 使用 HTTP 而非 HTTPS
+	PathStyle     bool          `yaml:"path_style"`               // 强制 path-style 访问 (MinIO 需要)
+	Redirect      bool          `yaml:"redirect"`                 // 下载时 302 到预签名 URL，而非由本服务转发
+	PresignExpiry time.Duration `yaml:"presign_expiry"`           // 预签名 URL 有效期 (默认 15m)
+}
+
+// Storage 缓存存储后端配置
+type Storage struct {
+	Type string    `yaml:"type"` // local (默认) 或 s3
+	S3   S3Storage `yaml:"s3"`
+}
+
+// Cache 缓存与同步配置
+type Cache struct {
+	Dir             string        `yaml:"dir"`                                            // 本地缓存目录 (默认 "github_cache")
+	Concurrency     int           `yaml:"concurrency" env:"ORANGE_DOWNLOAD_CONCURRENCY"`  // 同步时并发下载数 (默认 1)
+	SyncOnStartup   *bool         `yaml:"sync_on_startup" env:"ORANGE_SYNC_ON_STARTUP"`   // 启动时同步缓存 (默认 true)
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"ORANGE_REFRESH_INTERVAL"` // 定时刷新版本信息间隔，0 表示关闭
+}
+
+// Mirrors Release 文件下载镜像
+//
+// 从来源下载失败时按顺序尝试镜像。URL 模板支持 {url} (来源下载地址)、{repo}、{tag}、{name}，
+// 例如 "https://ghproxy.example.com/{url}" 或 "https://oss.example.com/releases/{tag}/{name}"。
+type Mirrors struct {
+	URLs            []string      `yaml:"urls"`             // 镜像 URL 模板，按顺序尝试
+	ChecksumFiles   []string      `yaml:"checksum_files"`   // Release 中的校验文件名 (sha256sum 格式，默认 SHA256SUMS, checksums.txt)
+	AllowUnverified bool          `yaml:"allow_unverified"` // 没有可信校验值时仍允许从镜像下载 (默认不允许)
+	MaxFailures     int           `yaml:"max_failures"`     // 连续失败多少次后暂时跳过该地址 (默认 3)
+	Cooldown        time.Duration `yaml:"cooldown"`         // 跳过的时长 (默认 5m)
+}
+
+// HTTP 出站 HTTP 客户端配置 (GitHub/GitLab/Gitea API、Release 下载、domains 仓库)
+type HTTP struct {
+	APITimeout      time.Duration `yaml:"api_timeout"`      // GitHub API 请求超时 (默认 30s)
+	DownloadTimeout time.Duration `yaml:"download_timeout"` // 文件下载超时 (默认 30m)
+
+	Proxy   string `yaml:"proxy" secret:"true"` // 代理地址 http:// https:// socks5:// socks5h://，为空时使用 HTTPS_PROXY 等环境变量
+	NoProxy string `yaml:"no_proxy"`            // 不走代理的主机，逗号分隔 (同 NO_PROXY 格式，如 .internal,10.0.0.0/8)
+	CAFile  string `yaml:"ca_file"`             // 额外信任的 CA 证书 (PEM)，追加到系统证书
+	Resolve string `yaml:"resolve"`             // DNS 覆盖，逗号分隔的 host=ip (如 github.com=140.82.112.3)
+
+	MaxIdleConns        int           `yaml:"max_idle_conns"`          // 最大空闲连接数 (默认 100)
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数 (默认 10)
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`       // 空闲连接保留时间 (默认 90s)
+}
+
+// ResolveMap 解析 resolve 配置为 host -> ip
+func (h HTTP) ResolveMap() (map[string]string, error) {
+	out := make(map[string]string)
+	for _, entry := range strings.Split(h.Resolve, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, addr, ok := strings.Cut(entry, "=")
+		if !ok || host == "" || net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("http.resolve 格式错误 (应为 host=ip): %s", entry)
+		}
+		out[strings.ToLower(host)] = addr
+	}
+	return out, nil
+}
+
+// TLS HTTPS 配置
+//
+// 配置 cert_file/key_file 使用静态证书，或配置 acme.domains 自动申请和续期证书，二者选一。
+// 启用后在 tls.port 上提供 HTTPS (支持 HTTP/2)，server.port 继续提供 HTTP。
+type TLS struct {
+	Port         int    `yaml:"port"`          // HTTPS 端口 (默认 8443)
+	CertFile     string `yaml:"cert_file"`     // 证书 (PEM，可包含中间证书)，文件更新后自动重新加载
+	KeyFile      string `yaml:"key_file"`      // 私钥 (PEM)
+	RedirectHTTP bool   `yaml:"redirect_http"` // HTTP 端口只做 308 跳转到 HTTPS (ACME HTTP-01 验证除外)
+	ACME         ACME   `yaml:"acme"`
+}
+
+// ACME 自动证书 (Let's Encrypt 等)
+type ACME struct {
+	Domains      []string `yaml:"domains"`       // 申请证书的域名，非空时启用 ACME
+	Email        string   `yaml:"email"`         // 联系邮箱 (证书到期提醒)
+	CacheDir     string   `yaml:"cache_dir"`     // 证书和账户密钥存储目录 (默认 acme_cache)
+	DirectoryURL string   `yaml:"directory_url"` // ACME 目录地址，留空为 Let's Encrypt 生产环境
+	CAFile       string   `yaml:"ca_file"`       // 信任的 ACME 服务器 CA (PEM)，用于 Pebble 等测试服务器
+}
+
+// Log 日志配置
+type Log struct {
+	Level  string `yaml:"level"`  // debug / info / warn / error (默认 info，支持热重载)
+	Format string `yaml:"format"` // text / json (默认 text，修改后需要重启)
+}
+
+// Tracing OpenTelemetry 链路追踪，通过 OTLP/HTTP 导出 (修改后需要重启)
+type Tracing struct {
+	Enabled     bool     `yaml:"enabled"`
+	Endpoint    string   `yaml:"endpoint"`              // OTLP/HTTP 地址，如 http://localhost:4318 (留空时使用 OTEL_EXPORTER_OTLP_ENDPOINT)
+	Headers     string   `yaml:"headers" secret:"true"` // 导出请求的额外请求头，如 "Authorization=Bearer xxx,X-Tenant=orange"
+	ServiceName string   `yaml:"service_name"`          // service.name (默认 orange-service)
+	SampleRatio *float64 `yaml:"sample_ratio"`          // 采样比例 0-1 (默认 1)，有上游 traceparent 时跟随上游
+}
+
+// HeaderMap 解析 headers 配置
+func (t Tracing) HeaderMap() (map[string]string, error) {
+	out := make(map[string]string)
+	for _, entry := range strings.Split(t.Headers, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, errors.New("tracing.headers 格式错误 (应为 key=value，逗号分隔)")
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
+
+// Ratio 采样比例
+func (t Tracing) Ratio() float64 {
+	if t.SampleRatio == nil {
+		return 1
+	}
+	return *t.SampleRatio
+}
+
+// Database 本地嵌入式数据库 (下载统计等)，修改后需要重启
+type Database struct {
+	Path string `yaml:"path"` // 数据库文件 (默认 data/orange.db)
+}
+
+// Analytics 下载统计
+type Analytics struct {
+	Enabled       *bool         `yaml:"enabled"`             // 是否记录下载 (默认开启)
+	Token         string        `yaml:"token" secret:"true"` // 统计报表接口的 Bearer token，为空时关闭报表接口
+	GeoIPDB       string        `yaml:"geoip_db"`            // MaxMind GeoLite2-Country / GeoIP2-Country 数据库 (mmdb)，用于按 IP 判断国家
+	CountryHeader string        `yaml:"country_header"`      // CDN 提供的国家代码请求头 (如 CF-IPCountry)，只信任来自 server.trusted_proxies 的请求
+	Timezone      string        `yaml:"timezone"`            // 按天统计使用的时区 (默认服务器本地时区)，如 Asia/Shanghai
+	Retention     time.Duration `yaml:"retention"`           // 下载记录保留时长 (默认 0，永久保留)
+}
+
+// On 是否记录下载
+func (a Analytics) On() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+// Location 按天统计使用的时区
+func (a Analytics) Location() (*time.Location, error) {
+	if a.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(a.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("analytics.timezone 无效: %s", a.Timezone)
+	}
+	return loc, nil
+}
+
+// Telemetry 活跃安装统计 (来自检查更新请求)
+type Telemetry struct {
+	Enabled   *bool         `yaml:"enabled"`   // 是否记录 (默认开启)，客户端未传 install_id 时不记录
+	Retention time.Duration `yaml:"retention"` // 保留时长 (默认 180 天)
+}
+
+// On 是否记录活跃安装
+func (t Telemetry) On() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// Yank 撤回版本
+type Yank struct {
+	Label          string `yaml:"label"`           // release 名称或说明中包含该标记时视为撤回 (默认 "[yanked]")
+	ForceDowngrade bool   `yaml:"force_downgrade"` // 建议仍在使用撤回版本的客户端降级到当前版本
+}
+
+// 管理接口权限
+const (
+	ScopeRead     = "read"     // 查看缓存、版本、webhook 记录、审计日志和活跃安装
+	ScopeRefresh  = "refresh"  // 刷新版本信息、同步缓存
+	ScopeCache    = "cache"    // 清除、重新校验缓存文件
+	ScopeVersions = "versions" // 固定、撤回版本
+	ScopeAll      = "*"        // 全部权限
+)
+
+var adminScopes = []string{ScopeRead, ScopeRefresh, ScopeCache, ScopeVersions, ScopeAll}
+
+// Admin 管理接口，token 和 tokens 都为空时关闭管理接口
+type Admin struct {
+	Token  string       `yaml:"token" secret:"true"` // 拥有全部权限的 Bearer token (审计日志中记为 admin)
+	Tokens []AdminToken `yaml:"tokens"`              // 按权限划分的 token (只能在 YAML 中配置)
+}
+
+// AdminToken 管理接口 token
+type AdminToken struct {
+	Name   string   `yaml:"name"`                // 名称，记录在审计日志中
+	Token  string   `yaml:"token" secret:"true"` // Bearer token
+	Scopes []string `yaml:"scopes"`              // read / refresh / cache / versions / *
+}
+
+// Enabled 是否配置了管理接口的 token
+func (a Admin) Enabled() bool {
+	return a.Token != "" || len(a.Tokens) > 0
+}
+
+// Allows token 是否拥有 scope 权限
+func (t AdminToken) Allows(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Admin) validate() error {
+	names := make(map[string]bool)
+	for i, t := range a.Tokens {
+		if t.Name == "" || t.Token == "" {
+			return fmt.Errorf("admin.tokens[%d] 需要 name 和 token", i)
+		}
+		if t.Name == "admin" || names[t.Name] {
+			return fmt.Errorf("admin.tokens 名称重复或保留: %s", t.Name)
+		}
+		names[t.Name] = true
+		if len(t.Scopes) == 0 {
+			return fmt.Errorf("admin.tokens[%s] 没有配置 scopes", t.Name)
+		}
+		for _, s := range t.Scopes {
+			if !slices.Contains(adminScopes, s) {
+				return fmt.Errorf("admin.tokens[%s] 未知的 scope: %s", t.Name, s)
+			}
+		}
+	}
+	return nil
+}
+
+// SlogLevel 解析 log.level
+func (l Log) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return 0, fmt.Errorf("未知的 log.level (应为 debug / info / warn / error): %s", l.Level)
+	}
+	return level, nil
+}
+
+// Enabled 是否启用 HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || len(t.ACME.Domains) > 0
 }
 
 type Config struct {
 	Server struct {
-		Port    int    `yaml:"port"`
-		Host    string `yaml:"host"`
-		BaseURL string `yaml:"base_url"`
+		Port            int            `yaml:"port"`
+		Host            string         `yaml:"host"`        // 监听地址，或 unix socket 路径 (unix:/run/orange/orange.sock 或以 / 开头)
+		SocketMode      string         `yaml:"socket_mode"` // unix socket 文件权限 (八进制，默认 0660)
+		BaseURL         string         `yaml:"base_url"`
+		ShutdownTimeout time.Duration  `yaml:"shutdown_timeout"`           // 关闭时等待进行中下载的最长时间 (默认 5m)
+		CacheMaxAge     *time.Duration `yaml:"cache_max_age"`              // JSON 接口的 Cache-Control max-age (默认 1m，0 表示每次重新验证)
+		TLS             TLS            `yaml:"tls"`                        // HTTPS (修改后需要重启)
+		StatusToken     string         `yaml:"status_token" secret:"true"` // /api/v1/status 的 Bearer token，为空时关闭该接口
+		TrustedProxies  []string       `yaml:"trusted_proxies"`            // 可信反向代理 (IP 或 CIDR)，来自这些地址的请求按 X-Forwarded-For 取客户端 IP
 	} `yaml:"server"`
 
 	// 构建/发布仓库 (公开仓库，用于 check-update/download)
@@ -27,39 +322,385 @@ type Config struct {
 	// 域名配置仓库 (私有仓库，用于 redirect/domains)
 	Domains GitHubRepo `yaml:"domains"`
 
-	// 缓存目录 (内部使用，默认 "github_cache")
-	CacheDir string `yaml:"-"`
-}
+	// 缓存与同步
+	Cache Cache `yaml:"cache"`
+
+	// 出站 HTTP 客户端
+	HTTP HTTP `yaml:"http"`
+
+	// 缓存存储后端
+	Storage Storage `yaml:"storage"`
+
+	// 下载镜像
+	Mirrors Mirrors `yaml:"mirrors"`
+
+	// 日志
+	Log Log `yaml:"log"`
 
-var cfg Config
+	// 链路追踪
+	Tracing Tracing `yaml:"tracing"`
 
-func Load() *Config {
-	path := os.Getenv("CONFIG_PATH")
-	if path == "" {
-		path = "config.yaml"
+	// 本地数据库
+	Database Database `yaml:"database"`
+
+	// 下载统计
+	Analytics Analytics `yaml:"analytics"`
+
+	// 活跃安装统计
+	Telemetry Telemetry `yaml:"telemetry"`
+
+	// 管理接口
+	Admin Admin `yaml:"admin"`
+
+	// 撤回版本
+	Yank Yank `yaml:"yank"`
+
+	// 各配置项的来源 (key -> source)，用于 config print
+	sources map[string]string
+}
+
+// Path 返回配置文件路径 (CONFIG_PATH，默认 config.yaml)
+func Path() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
 	}
+	return "config.yaml"
+}
+
+// Default 返回只包含默认值的配置，主要用于测试和嵌入
+func Default() *Config {
+	c := &Config{}
+	setDefaults(c)
+	return c
+}
 
+// Parse 按 YAML → YAML *_file → 环境变量 → 环境变量 *_FILE 的顺序叠加配置，
+// 并补全默认值、校验。配置文件不存在时仅使用环境变量和默认值
+// (显式指定 CONFIG_PATH 时除外)。
+func Parse(path string) (*Config, error) {
+	var root yaml.Node
 	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	case errors.Is(err, fs.ErrNotExist) && os.Getenv("CONFIG_PATH") == "":
+		slog.Info("配置文件不存在，仅使用环境变量和默认值", "path", path)
+	default:
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	c := &Config{}
+	if root.Kind != 0 {
+		if err := root.Decode(c); err != nil {
+			return nil, fmt.Errorf("解析配置文件失败: %w", err)
+		}
+	}
+
+	if err := applySources(c, &root); err != nil {
+		return nil, err
+	}
+
+	setDefaults(c)
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("配置无效: %w", err)
+	}
+
+	return c, nil
+}
+
+// SyncOnStartup 启动时是否同步缓存
+func (c *Config) SyncOnStartup() bool {
+	return c.Cache.SyncOnStartup == nil || *c.Cache.SyncOnStartup
+}
+
+// UnixSocket server.host 为 unix socket 时返回其路径
+func (c *Config) UnixSocket() (string, bool) {
+	if path, ok := strings.CutPrefix(c.Server.Host, "unix:"); ok {
+		return path, true
+	}
+	return c.Server.Host, strings.HasPrefix(c.Server.Host, "/")
+}
+
+// SocketMode unix socket 文件权限
+func (c *Config) SocketMode() os.FileMode {
+	mode, err := strconv.ParseUint(c.Server.SocketMode, 8, 32)
 	if err != nil {
-		log.Fatalf("读取配置文件失败: %v", err)
+		return 0660
 	}
+	return os.FileMode(mode)
+}
 
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		log.Fatalf("解析配置文件失败: %v", err)
+// TrustedProxies 解析 server.trusted_proxies，单个 IP 视为 /32 (IPv6 为 /128)
+func (c *Config) TrustedProxies() ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(c.Server.TrustedProxies))
+	for _, entry := range c.Server.TrustedProxies {
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("server.trusted_proxies 格式错误 (应为 IP 或 CIDR): %s", entry)
+		}
+		out = append(out, prefix.Masked())
+	}
+	return out, nil
+}
+
+// CacheMaxAge JSON 接口允许客户端和 CDN 缓存的时长
+func (c *Config) CacheMaxAge() time.Duration {
+	if c.Server.CacheMaxAge == nil {
+		return time.Minute
+	}
+	return *c.Server.CacheMaxAge
+}
+
+func setDefaults(c *Config) {
+	if c.Server.Port == 0 {
+		c.Server.Port = 8080
+	}
+	if c.Server.Host == "" {
+		c.Server.Host = "0.0.0.0"
+	}
+	if c.Server.SocketMode == "" {
+		c.Server.SocketMode = "0660"
+	}
+	if c.Server.ShutdownTimeout == 0 {
+		c.Server.ShutdownTimeout = 5 * time.Minute
+	}
+	if c.Server.TLS.Port == 0 {
+		c.Server.TLS.Port = 8443
+	}
+	if c.Server.TLS.ACME.CacheDir == "" {
+		c.Server.TLS.ACME.CacheDir = "acme_cache"
+	}
+	if c.Cache.Dir == "" {
+		c.Cache.Dir = "github_cache"
+	}
+	if c.Cache.Concurrency == 0 {
+		c.Cache.Concurrency = 1
+	}
+	if c.HTTP.APITimeout == 0 {
+		c.HTTP.APITimeout = 30 * time.Second
+	}
+	if c.HTTP.DownloadTimeout == 0 {
+		c.HTTP.DownloadTimeout = 30 * time.Minute
+	}
+	if c.HTTP.MaxIdleConns == 0 {
+		c.HTTP.MaxIdleConns = 100
+	}
+	if c.HTTP.MaxIdleConnsPerHost == 0 {
+		c.HTTP.MaxIdleConnsPerHost = 10
+	}
+	if c.HTTP.IdleConnTimeout == 0 {
+		c.HTTP.IdleConnTimeout = 90 * time.Second
+	}
+	switch c.Release.Provider {
+	case "":
+		c.Release.Provider = ProviderGitHub
+	case "forgejo":
+		c.Release.Provider = ProviderGitea
+	}
+	if c.Storage.Type == "" {
+		c.Storage.Type = "local"
+	}
+	if c.Storage.S3.PresignExpiry == 0 {
+		c.Storage.S3.PresignExpiry = 15 * time.Minute
+	}
+	if c.Mirrors.ChecksumFiles == nil {
+		c.Mirrors.ChecksumFiles = []string{"SHA256SUMS", "checksums.txt"}
+	}
+	if c.Mirrors.MaxFailures == 0 {
+		c.Mirrors.MaxFailures = 3
+	}
+	if c.Mirrors.Cooldown == 0 {
+		c.Mirrors.Cooldown = 5 * time.Minute
+	}
+	if c.Log.Level == "" {
+		c.Log.Level = "info"
+	}
+	if c.Log.Format == "" {
+		c.Log.Format = "text"
 	}
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "orange-service"
+	}
+	if c.Database.Path == "" {
+		c.Database.Path = filepath.Join("data", "orange.db")
+	}
+	if c.Telemetry.Retention == 0 {
+		c.Telemetry.Retention = 180 * 24 * time.Hour
+	}
+	if c.Yank.Label == "" {
+		c.Yank.Label = "[yanked]"
+	}
+}
 
-	// 默认值
-	if cfg.Server.Port == 0 {
-		cfg.Server.Port = 8080
+func (r GitHubRepo) validateApp(name string) error {
+	if !r.UseApp() {
+		return nil
+	}
+	if r.Provider != "" && r.Provider != ProviderGitHub {
+		return fmt.Errorf("%s.app_id 仅适用于 GitHub", name)
 	}
-	if cfg.Server.Host == "" {
-		cfg.Server.Host = "0.0.0.0"
+	if r.InstallationID == 0 || r.PrivateKey == "" {
+		return fmt.Errorf("%s 使用 GitHub App 时需要配置 installation_id 和 private_key", name)
 	}
-	cfg.CacheDir = "github_cache"
+	return nil
+}
 
-	return &cfg
+func (t TLS) validate(httpPort int) error {
+	if !t.Enabled() {
+		return nil
+	}
+	if t.Port < 1 || t.Port > 65535 {
+		return fmt.Errorf("server.tls.port 超出范围: %d", t.Port)
+	}
+	if t.Port == httpPort {
+		return errors.New("server.tls.port 不能与 server.port 相同")
+	}
+	if t.CertFile != "" && len(t.ACME.Domains) > 0 {
+		return errors.New("server.tls.cert_file 和 server.tls.acme.domains 不能同时配置")
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("server.tls.cert_file 和 server.tls.key_file 需要同时配置")
+	}
+	if t.ACME.DirectoryURL != "" && !strings.HasPrefix(t.ACME.DirectoryURL, "https://") {
+		return fmt.Errorf("server.tls.acme.directory_url 必须是 https 地址: %s", t.ACME.DirectoryURL)
+	}
+	return nil
 }
 
-func Get() *Config {
-	return &cfg
+// Validate 校验配置取值
+func (c *Config) Validate() error {
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		return fmt.Errorf("server.port 超出范围: %d", c.Server.Port)
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("server.shutdown_timeout 不能为负数: %s", c.Server.ShutdownTimeout)
+	}
+	if mode, err := strconv.ParseUint(c.Server.SocketMode, 8, 32); err != nil || mode > 0777 {
+		return fmt.Errorf("server.socket_mode 格式错误 (应为八进制，如 0660): %s", c.Server.SocketMode)
+	}
+	if path, ok := c.UnixSocket(); ok {
+		if path == "" {
+			return errors.New("server.host 缺少 unix socket 路径")
+		}
+		if c.Server.TLS.Enabled() {
+			return errors.New("server.host 为 unix socket 时不能启用 server.tls (由前面的反向代理终止 TLS)")
+		}
+	}
+	if err := c.Server.TLS.validate(c.Server.Port); err != nil {
+		return err
+	}
+	if _, err := c.TrustedProxies(); err != nil {
+		return err
+	}
+	if c.CacheMaxAge() < 0 {
+		return fmt.Errorf("server.cache_max_age 不能为负数: %s", c.CacheMaxAge())
+	}
+	if c.Cache.Concurrency < 1 || c.Cache.Concurrency > 16 {
+		return fmt.Errorf("cache.concurrency 必须在 1-16 之间: %d", c.Cache.Concurrency)
+	}
+	if c.Cache.RefreshInterval < 0 {
+		return fmt.Errorf("cache.refresh_interval 不能为负数: %s", c.Cache.RefreshInterval)
+	}
+	if c.Cache.RefreshInterval > 0 && c.Cache.RefreshInterval < time.Minute {
+		return fmt.Errorf("cache.refresh_interval 不能小于 1m: %s", c.Cache.RefreshInterval)
+	}
+	if c.HTTP.APITimeout < 0 || c.HTTP.DownloadTimeout < 0 || c.HTTP.IdleConnTimeout < 0 {
+		return errors.New("http 超时不能为负数")
+	}
+	if c.HTTP.MaxIdleConns < 0 || c.HTTP.MaxIdleConnsPerHost < 0 {
+		return errors.New("http 空闲连接数不能为负数")
+	}
+	if c.HTTP.Proxy != "" {
+		u, err := url.Parse(c.HTTP.Proxy)
+		if err != nil || u.Host == "" {
+			return errors.New("http.proxy 格式错误")
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("http.proxy 不支持的协议: %s", u.Scheme)
+		}
+	}
+	if _, err := c.HTTP.ResolveMap(); err != nil {
+		return err
+	}
+	switch c.Release.Provider {
+	case ProviderGitHub, ProviderGitLab:
+	case ProviderGitea:
+		if c.Release.BaseURL == "" {
+			return errors.New("release.provider 为 gitea 时需要配置 release.base_url")
+		}
+	case ProviderLocal:
+		if c.Release.Dir == "" {
+			return errors.New("release.provider 为 local 时需要配置 release.dir")
+		}
+	default:
+		return fmt.Errorf("未知的 release.provider: %s", c.Release.Provider)
+	}
+	if c.Domains.Provider != "" && c.Domains.Provider != ProviderGitHub {
+		return errors.New("domains 仓库目前仅支持 github")
+	}
+	if err := c.Release.validateApp("release"); err != nil {
+		return err
+	}
+	if err := c.Domains.validateApp("domains"); err != nil {
+		return err
+	}
+	for _, tmpl := range c.Mirrors.URLs {
+		if !strings.HasPrefix(tmpl, "http://") && !strings.HasPrefix(tmpl, "https://") {
+			return fmt.Errorf("mirrors.urls 必须以 http:// 或 https:// 开头: %s", tmpl)
+		}
+		if !strings.Contains(tmpl, "{url}") && !strings.Contains(tmpl, "{name}") {
+			return fmt.Errorf("mirrors.urls 需要包含 {url} 或 {name}: %s", tmpl)
+		}
+	}
+	if c.Mirrors.MaxFailures < 0 || c.Mirrors.Cooldown < 0 {
+		return errors.New("mirrors.max_failures 和 mirrors.cooldown 不能为负数")
+	}
+	switch c.Storage.Type {
+	case "local":
+	case "s3":
+		if c.Storage.S3.Endpoint == "" || c.Storage.S3.Bucket == "" {
+			return errors.New("storage.s3 需要配置 endpoint 和 bucket")
+		}
+	default:
+		return fmt.Errorf("未知的 storage.type: %s", c.Storage.Type)
+	}
+	if _, err := c.Log.SlogLevel(); err != nil {
+		return err
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		return fmt.Errorf("未知的 log.format (应为 text 或 json): %s", c.Log.Format)
+	}
+	if _, err := c.Tracing.HeaderMap(); err != nil {
+		return err
+	}
+	if r := c.Tracing.Ratio(); r < 0 || r > 1 {
+		return fmt.Errorf("tracing.sample_ratio 必须在 0-1 之间: %v", r)
+	}
+	if e := c.Tracing.Endpoint; e != "" && !strings.HasPrefix(e, "http://") && !strings.HasPrefix(e, "https://") {
+		return fmt.Errorf("tracing.endpoint 必须以 http:// 或 https:// 开头: %s", e)
+	}
+	if _, err := c.Analytics.Location(); err != nil {
+		return err
+	}
+	if c.Analytics.Retention < 0 {
+		return fmt.Errorf("analytics.retention 不能为负数: %s", c.Analytics.Retention)
+	}
+	if err := c.Admin.validate(); err != nil {
+		return err
+	}
+	if c.Telemetry.Retention < 24*time.Hour {
+		return fmt.Errorf("telemetry.retention 不能少于 1 天: %s", c.Telemetry.Retention)
+	}
+	return nil
 }
@@ -0,0 +1,350 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+}
+
+func mustLoad(t *testing.T) *Config {
+	t.Helper()
+	c, err := Parse(Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	writeTestConfig(t, `
+release:
+  repo: "test/repo"
+`)
+	c := mustLoad(t)
+
+	if c.Server.Port != 8080 || c.Server.Host != "0.0.0.0" {
+		t.Errorf("server 默认值不正确: %+v", c.Server)
+	}
+	if c.Cache.Dir != "github_cache" {
+		t.Errorf("期望 cache.dir=github_cache, 得到 %s", c.Cache.Dir)
+	}
+	if c.Cache.Concurrency != 1 {
+		t.Errorf("期望 cache.concurrency=1, 得到 %d", c.Cache.Concurrency)
+	}
+	if !c.SyncOnStartup() {
+		t.Error("sync_on_startup 默认应为 true")
+	}
+	if c.HTTP.APITimeout != 30*time.Second || c.HTTP.DownloadTimeout != 30*time.Minute {
+		t.Errorf("http 超时默认值不正确: %+v", c.HTTP)
+	}
+}
+
+func TestLoad_YAMLValues(t *testing.T) {
+	writeTestConfig(t, `
+cache:
+  dir: "/var/cache/orange"
+  concurrency: 4
+  sync_on_startup: false
+  refresh_interval: "10m"
+http:
+  api_timeout: "5s"
+`)
+	c := mustLoad(t)
+
+	if c.Cache.Dir != "/var/cache/orange" || c.Cache.Concurrency != 4 {
+		t.Errorf("cache 配置不正确: %+v", c.Cache)
+	}
+	if c.SyncOnStartup() {
+		t.Error("期望 sync_on_startup=false")
+	}
+	if c.Cache.RefreshInterval != 10*time.Minute {
+		t.Errorf("期望 refresh_interval=10m, 得到 %s", c.Cache.RefreshInterval)
+	}
+	if c.HTTP.APITimeout != 5*time.Second {
+		t.Errorf("期望 api_timeout=5s, 得到 %s", c.HTTP.APITimeout)
+	}
+}
+
+func TestLoad_EnvOverride(t *testing.T) {
+	writeTestConfig(t, `
+cache:
+  dir: "from-yaml"
+  concurrency: 2
+`)
+	t.Setenv("ORANGE_CACHE_DIR", "from-env")
+	t.Setenv("ORANGE_DOWNLOAD_CONCURRENCY", "8")
+	t.Setenv("ORANGE_SYNC_ON_STARTUP", "false")
+	t.Setenv("ORANGE_HTTP_DOWNLOAD_TIMEOUT", "1h")
+
+	c := mustLoad(t)
+
+	if c.Cache.Dir != "from-env" {
+		t.Errorf("期望环境变量覆盖 cache.dir, 得到 %s", c.Cache.Dir)
+	}
+	if c.Cache.Concurrency != 8 {
+		t.Errorf("期望 concurrency=8, 得到 %d", c.Cache.Concurrency)
+	}
+	if c.SyncOnStartup() {
+		t.Error("期望 sync_on_startup=false")
+	}
+	if c.HTTP.DownloadTimeout != time.Hour {
+		t.Errorf("期望 download_timeout=1h, 得到 %s", c.HTTP.DownloadTimeout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		valid  bool
+	}{
+		{"默认值", func(c *Config) {}, true},
+		{"端口越界", func(c *Config) { c.Server.Port = 70000 }, false},
+		{"并发过大", func(c *Config) { c.Cache.Concurrency = 100 }, false},
+		{"刷新间隔过短", func(c *Config) { c.Cache.RefreshInterval = time.Second }, false},
+		{"刷新间隔为负", func(c *Config) { c.Cache.RefreshInterval = -time.Minute }, false},
+		{"未知存储类型", func(c *Config) { c.Storage.Type = "ftp" }, false},
+		{"s3 缺少 bucket", func(c *Config) { c.Storage.Type = "s3"; c.Storage.S3.Endpoint = "minio:9000" }, false},
+		{"未知来源", func(c *Config) { c.Release.Provider = "svn" }, false},
+		{"gitea 缺少 base_url", func(c *Config) { c.Release.Provider = ProviderGitea }, false},
+		{"local 缺少 dir", func(c *Config) { c.Release.Provider = ProviderLocal }, false},
+		{"GitHub App 缺少私钥", func(c *Config) { c.Release.AppID = 1; c.Release.InstallationID = 2 }, false},
+		{"GitHub App", func(c *Config) { c.Domains.AppID = 1; c.Domains.InstallationID = 2; c.Domains.PrivateKey = "pem" }, true},
+		{"socks5 代理", func(c *Config) { c.HTTP.Proxy = "socks5://127.0.0.1:1080" }, true},
+		{"不支持的代理协议", func(c *Config) { c.HTTP.Proxy = "ftp://proxy:21" }, false},
+		{"resolve 格式错误", func(c *Config) { c.HTTP.Resolve = "github.com=not-an-ip" }, false},
+		{"镜像模板", func(c *Config) { c.Mirrors.URLs = []string{"https://ghproxy.example.com/{url}"} }, true},
+		{"镜像缺少占位符", func(c *Config) { c.Mirrors.URLs = []string{"https://mirror.example.com/"} }, false},
+		{"local 目录", func(c *Config) { c.Release.Provider = ProviderLocal; c.Release.Dir = "/srv/releases" }, true},
+		{"unix socket", func(c *Config) { c.Server.Host = "unix:/run/orange/orange.sock" }, true},
+		{"socket 权限格式错误", func(c *Config) { c.Server.SocketMode = "rw" }, false},
+		{"unix socket 与 TLS", func(c *Config) {
+			c.Server.Host = "/run/orange/orange.sock"
+			c.Server.TLS.CertFile, c.Server.TLS.KeyFile = "cert.pem", "key.pem"
+		}, false},
+		{"静态证书", func(c *Config) { c.Server.TLS.CertFile = "cert.pem"; c.Server.TLS.KeyFile = "key.pem" }, true},
+		{"证书缺少私钥", func(c *Config) { c.Server.TLS.CertFile = "cert.pem" }, false},
+		{"HTTPS 端口冲突", func(c *Config) { c.Server.TLS.ACME.Domains = []string{"a.example.com"}; c.Server.TLS.Port = 8080 }, false},
+		{"证书和 ACME 同时配置", func(c *Config) {
+			c.Server.TLS.CertFile, c.Server.TLS.KeyFile = "cert.pem", "key.pem"
+			c.Server.TLS.ACME.Domains = []string{"a.example.com"}
+		}, false},
+		{"可信代理", func(c *Config) { c.Server.TrustedProxies = []string{"127.0.0.1", "10.0.0.0/8", "::1"} }, true},
+		{"可信代理格式错误", func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/33"} }, false},
+		{"json 日志", func(c *Config) { c.Log.Format = "json"; c.Log.Level = "DEBUG" }, true},
+		{"未知日志级别", func(c *Config) { c.Log.Level = "verbose" }, false},
+		{"未知日志格式", func(c *Config) { c.Log.Format = "xml" }, false},
+		{"链路追踪", func(c *Config) { c.Tracing.Enabled = true; c.Tracing.Endpoint = "http://localhost:4318" }, true},
+		{"采样比例越界", func(c *Config) { r := 1.5; c.Tracing.SampleRatio = &r }, false},
+		{"追踪地址缺少协议", func(c *Config) { c.Tracing.Endpoint = "localhost:4318" }, false},
+		{"统计时区", func(c *Config) { c.Analytics.Timezone = "UTC"; c.Analytics.Retention = 90 * 24 * time.Hour }, true},
+		{"未知统计时区", func(c *Config) { c.Analytics.Timezone = "Mars/Olympus" }, false},
+		{"保留时长为负", func(c *Config) { c.Analytics.Retention = -time.Hour }, false},
+		{"活跃安装保留时长过短", func(c *Config) { c.Telemetry.Retention = time.Hour }, false},
+		{"管理 token", func(c *Config) {
+			c.Admin.Tokens = []AdminToken{{Name: "ci", Token: "t1", Scopes: []string{"refresh"}}, {Name: "ops", Token: "t2", Scopes: []string{"*"}}}
+		}, true},
+		{"管理 token 名称重复", func(c *Config) {
+			c.Admin.Tokens = []AdminToken{{Name: "ci", Token: "t1", Scopes: []string{"read"}}, {Name: "ci", Token: "t2", Scopes: []string{"read"}}}
+		}, false},
+		{"管理 token 未知权限", func(c *Config) { c.Admin.Tokens = []AdminToken{{Name: "ci", Token: "t1", Scopes: []string{"write"}}} }, false},
+		{"管理 token 缺少 token", func(c *Config) { c.Admin.Tokens = []AdminToken{{Name: "ci", Scopes: []string{"read"}}} }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			setDefaults(&c)
+			tt.modify(&c)
+			err := c.Validate()
+			if (err == nil) != tt.valid {
+				t.Errorf("期望 valid=%v, 得到 err=%v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestLoad_SecretSources(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "webhook_secret")
+	if err := os.WriteFile(secretPath, []byte("from-secret-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	domainsTokenPath := filepath.Join(dir, "domains_token")
+	if err := os.WriteFile(domainsTokenPath, []byte("ghp_domainstoken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestConfig(t, `
+release:
+  repo: "test/repo"
+  token: "from-yaml"
+  webhook_secret_file: "`+secretPath+`"
+`)
+	t.Setenv("ORANGE_RELEASE_TOKEN", "ghp_fromenvironment")
+	t.Setenv("ORANGE_DOMAINS_TOKEN_FILE", domainsTokenPath)
+
+	c := mustLoad(t)
+
+	if c.Release.Token != "ghp_fromenvironment" {
+		t.Errorf("环境变量应覆盖 YAML, 得到 %s", c.Release.Token)
+	}
+	if c.Release.WebhookSecret != "from-secret-file" {
+		t.Errorf("期望从文件读取 webhook_secret, 得到 %q", c.Release.WebhookSecret)
+	}
+	if c.Domains.Token != "ghp_domainstoken" {
+		t.Errorf("期望从 _FILE 读取 domains.token, 得到 %q", c.Domains.Token)
+	}
+
+	entries := make(map[string]Entry)
+	for _, e := range c.Entries() {
+		entries[e.Key] = e
+	}
+
+	tests := []struct {
+		key    string
+		value  string
+		source string
+	}{
+		{"release.repo", "test/repo", SourceYAML},
+		{"release.token", "****", "env:ORANGE_RELEASE_TOKEN"},
+		{"release.webhook_secret", "****", "file:" + secretPath},
+		{"domains.token", "****", "env:ORANGE_DOMAINS_TOKEN_FILE (" + domainsTokenPath + ")"},
+		{"server.port", "8080", SourceDefault},
+	}
+	for _, tt := range tests {
+		e := entries[tt.key]
+		if e.Value != tt.value || e.Source != tt.source {
+			t.Errorf("%s: 期望 %q (%s), 得到 %q (%s)", tt.key, tt.value, tt.source, e.Value, e.Source)
+		}
+	}
+}
+
+func TestLoad_StructSlice(t *testing.T) {
+	writeTestConfig(t, `
+release:
+  repo: "test/repo"
+admin:
+  tokens:
+    - name: ops
+      token: "ops-secret-token"
+      scopes: ["cache", "read"]
+`)
+	// 结构体列表不从环境变量读取
+	t.Setenv("ORANGE_ADMIN_TOKENS", "ignored")
+	c := mustLoad(t)
+
+	if len(c.Admin.Tokens) != 1 || c.Admin.Tokens[0].Token != "ops-secret-token" {
+		t.Fatalf("admin.tokens 解析错误: %+v", c.Admin.Tokens)
+	}
+	for _, e := range c.Entries() {
+		if e.Key != "admin.tokens" {
+			continue
+		}
+		if e.Env != "" || e.Source != SourceYAML || e.Value != "[{name=ops token=**** scopes=cache,read}]" {
+			t.Errorf("admin.tokens 输出错误: %+v", e)
+		}
+	}
+}
+
+func TestParse_MissingFile(t *testing.T) {
+	// 显式指定的配置文件不存在时报错
+	t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Parse(Path()); err == nil {
+		t.Error("期望配置文件不存在时报错")
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := map[string]string{
+		"":                     "",
+		"short":                "****",
+		"ghp_1234567890abcdef": "****",
+	}
+	for in, expected := range tests {
+		if got := Mask(in); got != expected {
+			t.Errorf("Mask(%q): 期望 %q, 得到 %q", in, expected, got)
+		}
+	}
+}
+
+func TestStore_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`
+release:
+  repo: "test/old"
+`)
+	store, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notified [2]string
+	store.OnReload(func(old, new *Config) {
+		notified = [2]string{old.Release.Repo, new.Release.Repo}
+	})
+
+	write(`
+release:
+  repo: "test/new"
+`)
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if store.Get().Release.Repo != "test/new" {
+		t.Errorf("期望重载后 repo=test/new, 得到 %s", store.Get().Release.Repo)
+	}
+	if notified != [2]string{"test/old", "test/new"} {
+		t.Errorf("重载回调参数不正确: %v", notified)
+	}
+
+	// 无效配置应被拒绝，保留旧配置
+	write(`
+release:
+  repo: "test/invalid"
+cache:
+  concurrency: 1000
+`)
+	if err := store.Reload(); err == nil {
+		t.Error("期望无效配置重载失败")
+	}
+	if store.Get().Release.Repo != "test/new" {
+		t.Errorf("无效配置不应替换当前配置, 得到 %s", store.Get().Release.Repo)
+	}
+
+	// 检查未通过时同样保留旧配置，不通知回调
+	store.OnCheck(func(old, new *Config) error {
+		if new.Release.Repo == "test/rejected" {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	write(`
+release:
+  repo: "test/rejected"
+`)
+	if err := store.Reload(); err == nil {
+		t.Error("期望检查未通过时重载失败")
+	}
+	if store.Get().Release.Repo != "test/new" || notified[1] != "test/new" {
+		t.Errorf("检查未通过不应替换配置或通知回调, 得到 %s %v", store.Get().Release.Repo, notified)
+	}
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置值来源
+const (
+	SourceDefault = "default"
+	SourceYAML    = "yaml"
+)
+
+// field 单个配置项
+//
+// key 为 YAML 路径 (如 release.token)，env 默认为 ORANGE_ + 大写路径
+// (如 ORANGE_RELEASE_TOKEN)，可用 `env` 标签覆盖；`secret:"true"` 的项在输出时脱敏。
+// 结构体列表 (如 admin.tokens) 只能在 YAML 中配置，env 为空。
+type field struct {
+	key    string
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fields 遍历 Config 中所有叶子配置项
+func fields(c *Config) []field {
+	var out []field
+	walkFields(reflect.ValueOf(c).Elem(), "", &out)
+	return out
+}
+
+func walkFields(v reflect.Value, prefix string, out *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if !sf.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+			walkFields(fv, key, out)
+			continue
+		}
+
+		env := sf.Tag.Get("env")
+		if env == "" && !isStructSlice(sf.Type) {
+			env = "ORANGE_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		}
+		*out = append(*out, field{
+			key:    key,
+			env:    env,
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+}
+
+// applySources 在 YAML 解码结果之上叠加 *_file 与环境变量，并记录每项来源
+func applySources(c *Config, root *yaml.Node) error {
+	c.sources = make(map[string]string)
+
+	for _, f := range fields(c) {
+		if lookupNode(root, f.key) != nil {
+			c.sources[f.key] = SourceYAML
+		}
+		if f.env == "" {
+			continue
+		}
+
+		// YAML 中的 <key>_file: 从文件读取 (Docker/Kubernetes secrets)
+		if n := lookupNode(root, f.key+"_file"); n != nil && n.Value != "" {
+			if err := setFromFile(f, n.Value); err != nil {
+				return fmt.Errorf("%s_file: %w", f.key, err)
+			}
+			c.sources[f.key] = "file:" + n.Value
+		}
+
+		if v := os.Getenv(f.env); v != "" {
+			if err := setValue(f.value, v); err != nil {
+				return fmt.Errorf("%s: %w", f.env, err)
+			}
+			c.sources[f.key] = "env:" + f.env
+		}
+
+		if path := os.Getenv(f.env + "_FILE"); path != "" {
+			if err := setFromFile(f, path); err != nil {
+				return fmt.Errorf("%s_FILE: %w", f.env, err)
+			}
+			c.sources[f.key] = "env:" + f.env + "_FILE (" + path + ")"
+		}
+	}
+
+	return nil
+}
+
+func setFromFile(f field, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return setValue(f.value, strings.TrimSpace(string(data)))
+}
+
+// lookupNode 按点分路径查找 YAML 映射节点中的值
+func lookupNode(root *yaml.Node, key string) *yaml.Node {
+	n := root
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	for _, part := range strings.Split(key, ".") {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == part {
+				next = n.Content[i+1]
+				break
+			}
+		}
+		n = next
+	}
+	return n
+}
+
+func setValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		// 逗号分隔的列表
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case v.Kind() == reflect.Pointer:
+		// 可选项 (*bool、*time.Duration 等)，未设置时为 nil
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+	default:
+		return fmt.Errorf("不支持的配置类型: %s", v.Type())
+	}
+	return nil
+}
+
+func isStructSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct
+}
+
+func formatValue(v reflect.Value) string {
+	switch {
+	case isStructSlice(v.Type()):
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatStruct(v.Index(i))
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		return fmt.Sprint(v.Elem().Interface())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		return strings.Join(v.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// formatStruct 以 {key=value ...} 输出列表中的一项，`secret:"true"` 的字段脱敏
+func formatStruct(v reflect.Value) string {
+	t := v.Type()
+	var parts []string
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if !sf.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		value := formatValue(v.Field(i))
+		if sf.Tag.Get("secret") == "true" {
+			value = Mask(value)
+		}
+		parts = append(parts, name+"="+value)
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// Mask 脱敏显示密钥，只显示是否已设置
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "****"
+}
+
+// Entry 生效配置项及其来源
+type Entry struct {
+	Key    string
+	Env    string
+	Value  string // 密钥已脱敏
+	Source string
+}
+
+// Entries 返回所有配置项的生效值与来源，按 key 排序
+func (c *Config) Entries() []Entry {
+	var entries []Entry
+	for _, f := range fields(c) {
+		value := formatValue(f.value)
+		if f.secret {
+			value = Mask(value)
+		}
+		source := c.sources[f.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		entries = append(entries, Entry{Key: f.key, Env: f.env, Value: value, Source: source})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
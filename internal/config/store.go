@@ -0,0 +1,104 @@
+package config
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// ReloadFunc 配置重载回调，old 为替换前的配置
+type ReloadFunc func(old, new *Config)
+
+// CheckFunc 替换配置前的检查，返回错误时放弃本次重载
+type CheckFunc func(old, new *Config) error
+
+// Store 持有当前生效的配置，热重载时整体原子替换
+type Store struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	checks    []CheckFunc
+	listeners []ReloadFunc
+}
+
+// NewStore 从配置文件加载配置
+func NewStore(path string) (*Store, error) {
+	c, err := Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{path: path}
+	s.current.Store(c)
+	return s, nil
+}
+
+// NewStatic 用已构造好的配置创建 Store (不关联配置文件，Reload 无效)
+func NewStatic(c *Config) *Store {
+	s := &Store{}
+	s.current.Store(c)
+	return s
+}
+
+// Path 返回配置文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get 返回当前生效的配置
+//
+// 热重载后返回新的 *Config，调用方不应长期持有旧指针。
+func (s *Store) Get() *Config {
+	return s.current.Load()
+}
+
+// OnReload 注册配置重载回调，用于刷新依赖配置的状态 (版本信息、存储后端等)
+func (s *Store) OnReload(fn ReloadFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// OnCheck 注册重载前的检查，用于校验需要创建外部资源才能确认的配置 (存储后端等)
+//
+// 检查和回调在同一次 Reload 中串行执行。
+func (s *Store) OnCheck(fn CheckFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, fn)
+}
+
+// Reload 重新解析并校验配置文件，成功后原子替换当前配置并通知回调。
+// 新配置无效时保留旧配置并返回错误。
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil
+	}
+
+	c, err := Parse(s.path)
+	if err != nil {
+		slog.Error("配置重载失败，保留旧配置", "err", err)
+		return err
+	}
+
+	for _, check := range s.checks {
+		if err := check(s.current.Load(), c); err != nil {
+			slog.Error("配置重载失败，保留旧配置", "err", err)
+			return err
+		}
+	}
+
+	old := s.current.Swap(c)
+	if old.Server.Host != c.Server.Host || old.Server.Port != c.Server.Port {
+		slog.Warn("server.host/port 变更需要重启才能生效")
+	}
+	slog.Info("配置已重载", "path", s.path)
+
+	for _, fn := range s.listeners {
+		fn(old, c)
+	}
+	return nil
+}
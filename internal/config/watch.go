@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch 监听配置文件变化并自动重载，ctx 取消时停止
+//
+// 监听的是所在目录而非文件本身，以兼容编辑器的原子替换写入和
+// Kubernetes ConfigMap 的符号链接切换。
+func (s *Store) Watch(ctx context.Context) error {
+	path := s.path
+	if path == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(abs)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// 合并短时间内的多次写入事件
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != abs && filepath.Base(ev.Name) != "..data" {
+					continue
+				}
+				if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(500 * time.Millisecond)
+				}
+			case <-debounce:
+				debounce = nil
+				s.Reload()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("监听配置文件失败", "err", err)
+			}
+		}
+	}()
+
+	slog.Info("监听配置文件变化", "path", path)
+	return nil
+}
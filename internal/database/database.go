@@ -0,0 +1,36 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Open 打开本地嵌入式数据库 (bbolt)，目录不存在时创建
+//
+// 同一个文件只能被一个进程打开，被占用时 1 秒后返回错误而不是一直等待。
+func Open(path string) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库 %s 失败: %w", path, err)
+	}
+	return db, nil
+}
+
+// CreateBuckets 创建不存在的 bucket
+func CreateBuckets(db *bolt.DB, names ...string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range names {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,196 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"update-server/internal/config"
+)
+
+// TokenSource 提供 GitHub 请求使用的访问令牌
+type TokenSource interface {
+	// Token 返回当前可用的令牌，未配置认证时返回空字符串
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken 固定的个人访问令牌 (PAT)
+type StaticToken string
+
+func (t StaticToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
+
+// NewTokenSource 按仓库配置创建令牌来源：配置了 GitHub App 时使用安装令牌，否则使用 token
+func NewTokenSource(repo config.GitHubRepo, client *http.Client) (TokenSource, error) {
+	if !repo.UseApp() {
+		return StaticToken(repo.Token), nil
+	}
+	return NewAppTokenSource(repo.BaseURL, repo.AppID, repo.InstallationID, []byte(repo.PrivateKey), client)
+}
+
+// tokenRefreshMargin 安装令牌到期前多久重新签发
+const tokenRefreshMargin = 5 * time.Minute
+
+// AppTokenSource GitHub App 安装令牌
+//
+// 用 App 私钥签名的 JWT 换取安装令牌 (有效期 1 小时)，缓存到临近过期前再重新签发。
+type AppTokenSource struct {
+	baseURL        string
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	http           *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAppTokenSource 创建 App 令牌来源，privateKeyPEM 支持 PKCS#1 和 PKCS#8
+func NewAppTokenSource(baseURL string, appID, installationID int64, privateKeyPEM []byte, client *http.Client) (*AppTokenSource, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("解析 GitHub App 私钥失败: %w", err)
+	}
+	return &AppTokenSource{
+		baseURL:        APIURL(baseURL),
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		http:           client,
+	}, nil
+}
+
+func (a *AppTokenSource) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Until(a.expires) > tokenRefreshMargin {
+		return a.token, nil
+	}
+
+	token, expires, err := a.mint(ctx)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.expires = token, expires
+	return token, nil
+}
+
+// mint 签发新的安装令牌
+func (a *AppTokenSource) mint(ctx context.Context) (string, time.Time, error) {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.baseURL, a.installationID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", time.Time{}, fmt.Errorf("获取 GitHub App 安装令牌失败: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", time.Time{}, err
+	}
+	return result.Token, result.ExpiresAt, nil
+}
+
+// jwt 生成 App 身份的 JWT (RS256)，签发时间提前 60 秒以容忍时钟偏差
+func (a *AppTokenSource) jwt(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("不是有效的 PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("私钥不是 RSA 类型")
+	}
+	return rsaKey, nil
+}
+
+// Credentials 按仓库配置缓存令牌来源
+//
+// 配置不变时复用同一个来源，已签发的安装令牌不会因每次请求重新创建而丢失；
+// 配置热重载修改凭据后自动换用新的来源。零值可直接使用。
+type Credentials struct {
+	mu     sync.Mutex
+	repo   config.GitHubRepo
+	client *http.Client
+	src    TokenSource
+}
+
+// Token 返回 repo 对应的访问令牌，client 用于签发 App 安装令牌
+func (c *Credentials) Token(ctx context.Context, repo config.GitHubRepo, client *http.Client) (string, error) {
+	c.mu.Lock()
+	if c.src == nil || c.repo != repo || c.client != client {
+		src, err := NewTokenSource(repo, client)
+		if err != nil {
+			c.mu.Unlock()
+			return "", err
+		}
+		c.repo, c.client, c.src = repo, client, src
+	}
+	src := c.src
+	c.mu.Unlock()
+
+	return src.Token(ctx)
+}
@@ -0,0 +1,127 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeApp 模拟签发安装令牌的 GitHub API，校验 JWT 签名
+func fakeApp(t *testing.T, key *rsa.PrivateKey, ttl time.Duration, minted *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/99/access_tokens":
+			jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			parts := strings.Split(jwt, ".")
+			if len(parts) != 3 {
+				http.Error(w, "bad jwt", http.StatusUnauthorized)
+				return
+			}
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+				http.Error(w, "bad signature", http.StatusUnauthorized)
+				return
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			var c struct {
+				Iss string `json:"iss"`
+			}
+			json.Unmarshal(claims, &c)
+			if c.Iss != "12" {
+				http.Error(w, "bad iss", http.StatusUnauthorized)
+				return
+			}
+
+			n := atomic.AddInt32(minted, 1)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{
+				"token":      fmt.Sprintf("ghs_%d", n),
+				"expires_at": time.Now().Add(ttl).UTC().Format(time.RFC3339),
+			})
+		case "/repos/org/app/releases/latest":
+			if r.Header.Get("Authorization") != "token ghs_1" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"tag_name":"v1.0.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAppTokenSource_CachesToken(t *testing.T) {
+	key, keyPEM := testKey(t)
+	var minted int32
+	srv := fakeApp(t, key, time.Hour, &minted)
+
+	src, err := NewAppTokenSource(srv.URL, 12, 99, keyPEM, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(srv.URL, src, http.DefaultClient)
+	for i := 0; i < 3; i++ {
+		if _, err := client.FetchLatestRelease(context.Background(), "org/app"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&minted); n != 1 {
+		t.Errorf("令牌未过期时期望只签发 1 次, 实际 %d 次", n)
+	}
+}
+
+func TestAppTokenSource_RefreshBeforeExpiry(t *testing.T) {
+	key, keyPEM := testKey(t)
+	var minted int32
+	// 有效期短于提前续期的余量，每次都应重新签发
+	srv := fakeApp(t, key, time.Minute, &minted)
+
+	src, err := NewAppTokenSource(srv.URL, 12, 99, keyPEM, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := src.Token(context.Background())
+	second, err := src.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("即将过期的令牌应重新签发, 两次都得到 %s", first)
+	}
+}
+
+func TestNewAppTokenSource_InvalidKey(t *testing.T) {
+	if _, err := NewAppTokenSource("", 1, 2, []byte("not a pem"), http.DefaultClient); err == nil {
+		t.Error("无效私钥应返回错误")
+	}
+}
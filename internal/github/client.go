@@ -1,57 +1,161 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"time"
-
-	"update-server/internal/config"
+	"net/url"
+	"strings"
 )
 
+// DefaultBaseURL github.com 的 API 地址，GitHub Enterprise 为 https://{host}/api/v3
+const DefaultBaseURL = "https://api.github.com"
+
+// ErrNotFound 资源不存在 (HTTP 404)
+var ErrNotFound = errors.New("GitHub 资源不存在")
+
+type Asset struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	Digest             string `json:"digest"` // 如 "sha256:..."，较早上传的文件可能为空
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
 type Release struct {
-	TagName     string `json:"tag_name"`
-	Name        string `json:"name"`
-	Body        string `json:"body"`
-	PublishedAt string `json:"published_at"`
-	Assets      []struct {
-		Name               string `json:"name"`
-		Size               int64  `json:"size"`
-		BrowserDownloadURL string `json:"browser_download_url"`
-	} `json:"assets"`
-}
-
-func FetchLatestRelease() (*Release, error) {
-	cfg := config.Get()
-	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", cfg.Release.Repo)
-
-	req, err := http.NewRequest("GET", url, nil)
+	TagName     string  `json:"tag_name"`
+	Name        string  `json:"name"`
+	Body        string  `json:"body"`
+	PublishedAt string  `json:"published_at"`
+	Draft       bool    `json:"draft"`
+	Prerelease  bool    `json:"prerelease"`
+	Assets      []Asset `json:"assets"`
+}
+
+// Client GitHub REST API 客户端
+type Client struct {
+	baseURL string
+	auth    TokenSource
+	http    *http.Client
+}
+
+// NewClient 创建客户端，baseURL 为空时使用 github.com，auth 为 nil 时匿名访问
+func NewClient(baseURL string, auth TokenSource, client *http.Client) *Client {
+	if auth == nil {
+		auth = StaticToken("")
+	}
+	return &Client{
+		baseURL: APIURL(baseURL),
+		auth:    auth,
+		http:    client,
+	}
+}
+
+// authorize 设置认证头
+func (c *Client) authorize(req *http.Request) error {
+	token, err := c.auth.Token(req.Context())
 	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	return nil
+}
+
+// APIURL 返回规范化的 API 地址，为空时使用 github.com
+func APIURL(baseURL string) string {
+	if baseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
+// FetchLatestRelease 获取最新的正式版本
+func (c *Client) FetchLatestRelease(ctx context.Context, repo string) (*Release, error) {
+	var release Release
+	if err := c.get(ctx, "/repos/"+repo+"/releases/latest", &release); err != nil {
 		return nil, err
 	}
+	return &release, nil
+}
 
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	if cfg.Release.Token != "" {
-		req.Header.Set("Authorization", "token "+cfg.Release.Token)
+// FetchRelease 按 tag 获取版本
+func (c *Client) FetchRelease(ctx context.Context, repo, tag string) (*Release, error) {
+	var release Release
+	if err := c.get(ctx, "/repos/"+repo+"/releases/tags/"+url.PathEscape(tag), &release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// FetchReleases 获取版本列表 (按发布时间倒序，最多 100 个)
+func (c *Client) FetchReleases(ctx context.Context, repo string) ([]Release, error) {
+	var releases []Release
+	if err := c.get(ctx, "/repos/"+repo+"/releases?per_page=100", &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// OpenAsset 通过 REST API 下载 Release 文件，私有仓库也可用
+//
+// API 会 302 到带签名的存储地址，client 跟随跳转时不应把 token 转发给存储主机。
+func (c *Client) OpenAsset(ctx context.Context, client *http.Client, repo string, id int64) (io.ReadCloser, int64, error) {
+	path := fmt.Sprintf("/repos/%s/releases/assets/%d", repo, id)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req.Header.Set("Accept", "application/octet-stream")
+	if err := c.authorize(req); err != nil {
+		return nil, 0, err
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API 错误: %d - %s", resp.StatusCode, string(body))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, 0, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+		return nil, 0, fmt.Errorf("下载 GitHub 文件失败: HTTP %d", resp.StatusCode)
 	}
 
-	var release Release
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return nil, err
+	return resp.Body, resp.ContentLength, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return err
 	}
 
-	return &release, nil
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if err := c.authorize(req); err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitHub API 错误: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
 }
@@ -0,0 +1,632 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"update-server/internal/admin"
+	"update-server/internal/cache"
+	"update-server/internal/config"
+	"update-server/internal/logging"
+	"update-server/internal/release"
+	"update-server/internal/storage"
+)
+
+// adminAuth 校验管理接口的 token 和权限，返回操作者名称 (记录到审计日志)
+//
+// admin.token 拥有全部权限，操作者为 "admin"；admin.tokens 按 scopes 授权。
+// 都未配置时管理接口关闭，返回 404。
+func (s *Server) adminAuth(w http.ResponseWriter, r *http.Request, scope string) (string, bool) {
+	cfg := s.config.Get().Admin
+	if !cfg.Enabled() {
+		httpError(w, http.StatusNotFound, "未配置 admin.token 或 admin.tokens")
+		return "", false
+	}
+	if cfg.Token != "" && bearerTokenMatches(r, cfg.Token) {
+		return "admin", true
+	}
+	for _, t := range cfg.Tokens {
+		if !bearerTokenMatches(r, t.Token) {
+			continue
+		}
+		if !t.Allows(scope) {
+			httpError(w, http.StatusForbidden, "token 没有 "+scope+" 权限")
+			return "", false
+		}
+		return t.Name, true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="orange-service"`)
+	httpError(w, http.StatusUnauthorized, "认证失败")
+	return "", false
+}
+
+// audit 记录一次管理操作，err 为操作失败的原因
+func (s *Server) audit(r *http.Request, actor, action, target, detail string, err error) {
+	trusted, _ := s.config.Get().TrustedProxies()
+	e := admin.Entry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+		RequestID: logging.RequestID(r.Context()),
+		ClientIP:  clientIP(r, trusted),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := s.auditLog.Record(e); err != nil {
+		slog.ErrorContext(r.Context(), "写入审计日志失败", "action", action, "err", err)
+	}
+}
+
+// refreshVersions 立即刷新版本信息
+func (s *Server) refreshVersions(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Get().HTTP.APITimeout)
+	defer cancel()
+	return s.versions.Refresh(ctx)
+}
+
+// startSync 在后台同步缓存
+func (s *Server) startSync(r *http.Request, trigger string) {
+	ctx := s.taskContext(r)
+	s.tasks.Go(trigger+"-sync", func(context.Context) {
+		if err := s.syncCache(ctx, trigger); err != nil {
+			slog.ErrorContext(ctx, "同步缓存失败", "trigger", trigger, "err", err)
+		}
+	})
+}
+
+// AdminActionResponse 管理操作结果
+type AdminActionResponse struct {
+	Status  string `json:"status" example:"ok"`
+	Version string `json:"version,omitempty" example:"v1.2.0"` // 操作后提供的版本
+	Syncing bool   `json:"syncing,omitempty"`                  // 已在后台开始同步缓存
+	Error   string `json:"error,omitempty"`                    // 刷新版本信息失败的原因
+}
+
+// actionResponse 刷新版本信息 (refresh 为 true 时) 并返回当前版本
+func (s *Server) actionResponse(w http.ResponseWriter, r *http.Request, refresh, syncing bool) {
+	resp := AdminActionResponse{Status: "ok", Syncing: syncing}
+	if refresh {
+		if err := s.refreshVersions(r.Context()); err != nil {
+			slog.ErrorContext(r.Context(), "刷新版本信息失败", "err", err)
+			resp.Error = err.Error()
+		}
+	}
+	if info := s.versions.Get(); info != nil {
+		resp.Version = info.Version
+	}
+	jsonResponse(w, resp)
+}
+
+// AdminRefresh 刷新版本信息
+// @Summary 立即刷新版本信息
+// @Description 从来源重新获取最新版本；sync=true 时随后在后台同步缓存
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param sync query bool false "刷新后同步缓存"
+// @Success 200 {object} AdminActionResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /api/v1/admin/refresh [post]
+func (s *Server) AdminRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 POST")
+		return
+	}
+	actor, ok := s.adminAuth(w, r, config.ScopeRefresh)
+	if !ok {
+		return
+	}
+
+	err := s.refreshVersions(r.Context())
+	s.audit(r, actor, "versions.refresh", "", "", err)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "刷新版本信息失败", "err", err)
+		httpError(w, http.StatusBadGateway, "刷新版本信息失败: "+err.Error())
+		return
+	}
+	sync := r.URL.Query().Get("sync") == "true"
+	if sync {
+		s.startSync(r, "admin")
+	}
+	s.actionResponse(w, r, false, sync)
+}
+
+// AdminSync 同步缓存
+// @Summary 在后台同步缓存
+// @Description 立即开始同步当前版本的文件，同步状态见 /api/v1/status
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} AdminActionResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/admin/sync [post]
+func (s *Server) AdminSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 POST")
+		return
+	}
+	actor, ok := s.adminAuth(w, r, config.ScopeRefresh)
+	if !ok {
+		return
+	}
+	// 先占住手动同步再检查同步状态，两个同时到达的请求只有一个启动同步
+	if !s.adminSyncing.CompareAndSwap(false, true) {
+		httpError(w, http.StatusConflict, "缓存正在同步")
+		return
+	}
+	if s.cache.SyncStatus().Running {
+		s.adminSyncing.Store(false)
+		httpError(w, http.StatusConflict, "缓存正在同步")
+		return
+	}
+
+	s.audit(r, actor, "cache.sync", "", "", nil)
+	ctx := s.taskContext(r)
+	s.tasks.Go("admin-sync", func(context.Context) {
+		defer s.adminSyncing.Store(false)
+		if err := s.syncCache(ctx, "admin"); err != nil {
+			slog.ErrorContext(ctx, "同步缓存失败", "trigger", "admin", "err", err)
+		}
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(AdminActionResponse{Status: "started", Syncing: true})
+}
+
+// PurgeResponse 清除缓存的结果
+type PurgeResponse struct {
+	Removed []string `json:"removed"`
+}
+
+// AdminCache 查看或清除缓存
+// @Summary 查看或清除缓存文件
+// @Description GET 列出缓存文件 (read 权限)；DELETE 删除某个版本的全部文件或指定文件 (cache 权限)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param version query string false "版本号 (DELETE 必填)" example(v1.2.0)
+// @Param file query string false "文件名 (为空时删除该版本的全部文件)" example(app-windows-amd64.exe)
+// @Success 200 {object} CacheStatus
+// @Success 200 {object} PurgeResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/cache [get]
+// @Router /api/v1/admin/cache [delete]
+func (s *Server) AdminCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := s.adminAuth(w, r, config.ScopeRead); !ok {
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		jsonResponse(w, s.cacheStatus(r.Context()))
+	case http.MethodDelete:
+		actor, ok := s.adminAuth(w, r, config.ScopeCache)
+		if !ok {
+			return
+		}
+		s.purgeCache(w, r, actor)
+	default:
+		httpError(w, http.StatusMethodNotAllowed, "只支持 GET 和 DELETE")
+	}
+}
+
+func (s *Server) purgeCache(w http.ResponseWriter, r *http.Request, actor string) {
+	q := r.URL.Query()
+	tag, file := q.Get("version"), q.Get("file")
+	if tag == "" {
+		httpError(w, http.StatusBadRequest, "缺少 version 参数")
+		return
+	}
+	target := tag
+	if file != "" {
+		target = storage.Key(tag, file)
+	}
+	if !storage.ValidKey(target) {
+		httpError(w, http.StatusBadRequest, "非法的 version 或 file")
+		return
+	}
+
+	store := s.cache.Storage()
+	keys := []string{target}
+	if file == "" {
+		objects, err := store.List(r.Context(), tag+"/")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "列出缓存文件失败", "err", err)
+			httpError(w, http.StatusInternalServerError, "列出缓存文件失败")
+			return
+		}
+		keys = keys[:0]
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+	}
+
+	resp := PurgeResponse{Removed: []string{}}
+	var err error
+	for _, key := range keys {
+		if err = store.Delete(r.Context(), key); err != nil {
+			break
+		}
+		resp.Removed = append(resp.Removed, key)
+	}
+	s.audit(r, actor, "cache.purge", target, fmt.Sprintf("删除 %d 个文件", len(resp.Removed)), err)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "删除缓存文件失败", "target", target, "err", err)
+		httpError(w, http.StatusInternalServerError, "删除缓存文件失败")
+		return
+	}
+	jsonResponse(w, resp)
+}
+
+// VerifyResponse 重新校验缓存的结果
+type VerifyResponse struct {
+	Version string               `json:"version" example:"v1.2.0"`
+	Files   []cache.VerifyResult `json:"files"`
+	Failed  int                  `json:"failed"` // 校验失败或出错的文件数
+}
+
+// AdminVerifyCache 重新校验缓存
+// @Summary 重新校验缓存文件
+// @Description 重新计算缓存文件的 SHA-256 并与来源的校验值比较，不一致的文件从缓存删除
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param version query string true "版本号" example(v1.2.0)
+// @Param file query string false "文件名 (为空时校验该版本已缓存的全部文件)" example(app-windows-amd64.exe)
+// @Success 200 {object} VerifyResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/cache/verify [post]
+func (s *Server) AdminVerifyCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 POST")
+		return
+	}
+	actor, ok := s.adminAuth(w, r, config.ScopeCache)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	tag, file := q.Get("version"), q.Get("file")
+	if tag == "" {
+		httpError(w, http.StatusBadRequest, "缺少 version 参数")
+		return
+	}
+
+	rel, ok := s.getRelease(w, r, tag)
+	if !ok {
+		return
+	}
+	var assets []release.Asset
+	if file != "" {
+		asset, found := rel.Asset(file)
+		if !found {
+			httpError(w, http.StatusNotFound, "文件不存在: "+file)
+			return
+		}
+		assets = append(assets, asset)
+	} else {
+		// 只校验已缓存的文件
+		objects, err := s.cache.Storage().List(r.Context(), tag+"/")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "列出缓存文件失败", "err", err)
+			httpError(w, http.StatusInternalServerError, "列出缓存文件失败")
+			return
+		}
+		for _, obj := range objects {
+			if asset, found := rel.Asset(strings.TrimPrefix(obj.Key, tag+"/")); found {
+				assets = append(assets, asset)
+			}
+		}
+	}
+
+	resp := VerifyResponse{Version: tag, Files: []cache.VerifyResult{}}
+	for _, asset := range assets {
+		res := s.cache.Verify(r.Context(), rel, asset)
+		if !res.OK {
+			resp.Failed++
+		}
+		resp.Files = append(resp.Files, res)
+	}
+	target := tag
+	if file != "" {
+		target = storage.Key(tag, file)
+	}
+	s.audit(r, actor, "cache.verify", target, fmt.Sprintf("校验 %d 个文件，%d 个失败", len(resp.Files), resp.Failed), nil)
+	jsonResponse(w, resp)
+}
+
+// getRelease 从来源获取版本，失败时写入错误响应
+func (s *Server) getRelease(w http.ResponseWriter, r *http.Request, tag string) (*release.Release, bool) {
+	for _, candidate := range tagCandidates(tag) {
+		rel, err := s.releases.GetRelease(r.Context(), candidate)
+		if errors.Is(err, release.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "获取版本失败", "tag", candidate, "err", err)
+			httpError(w, http.StatusBadGateway, "获取版本失败: "+err.Error())
+			return nil, false
+		}
+		return rel, true
+	}
+	httpError(w, http.StatusNotFound, "版本不存在: "+tag)
+	return nil, false
+}
+
+// VersionsResponse 版本策略
+type VersionsResponse struct {
+	Current string       `json:"current,omitempty" example:"v1.2.0"` // 当前提供的版本
+	Pinned  string       `json:"pinned,omitempty" example:"v1.1.0"`
+	Yanked  []admin.Yank `json:"yanked"`
+}
+
+// AdminVersions 版本策略
+// @Summary 查看固定和撤回的版本
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} VersionsResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/versions [get]
+func (s *Server) AdminVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 GET")
+		return
+	}
+	if _, ok := s.adminAuth(w, r, config.ScopeRead); !ok {
+		return
+	}
+	resp := VersionsResponse{Pinned: s.pins.Pinned(), Yanked: s.pins.Yanks()}
+	if info := s.versions.Get(); info != nil {
+		resp.Current = info.Version
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	jsonResponse(w, resp)
+}
+
+// VersionRequest 固定或撤回版本的请求
+type VersionRequest struct {
+	Version string `json:"version" example:"v1.2.0"`
+	Reason  string `json:"reason,omitempty" example:"启动崩溃"` // 撤回原因
+}
+
+func decodeVersionRequest(w http.ResponseWriter, r *http.Request) (VersionRequest, bool) {
+	var req VersionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, "解析请求体失败")
+		return req, false
+	}
+	req.Version = strings.TrimSpace(req.Version)
+	if req.Version == "" {
+		httpError(w, http.StatusBadRequest, "缺少 version")
+		return req, false
+	}
+	return req, true
+}
+
+// AdminPin 固定版本
+// @Summary 固定或取消固定提供的版本
+// @Description POST 固定提供指定版本 (不再跟随最新版本)，DELETE 取消固定；随后刷新版本信息并在后台同步缓存
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body VersionRequest false "要固定的版本 (POST)"
+// @Success 200 {object} AdminActionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/admin/versions/pin [post]
+// @Router /api/v1/admin/versions/pin [delete]
+func (s *Server) AdminPin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 POST 和 DELETE")
+		return
+	}
+	actor, ok := s.adminAuth(w, r, config.ScopeVersions)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		prev := s.pins.Pinned()
+		err := s.pins.Pin("")
+		s.audit(r, actor, "versions.unpin", prev, "", err)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "取消固定版本失败", "err", err)
+			httpError(w, http.StatusInternalServerError, "取消固定版本失败")
+			return
+		}
+		s.startSync(r, "admin")
+		s.actionResponse(w, r, true, true)
+		return
+	}
+
+	req, ok := decodeVersionRequest(w, r)
+	if !ok {
+		return
+	}
+	rel, ok := s.getRelease(w, r, req.Version)
+	if !ok {
+		return
+	}
+	if s.pins.Yanked(rel.Tag) {
+		httpError(w, http.StatusConflict, "版本已撤回: "+rel.Tag)
+		return
+	}
+	err := s.pins.Pin(rel.Tag)
+	s.audit(r, actor, "versions.pin", rel.Tag, "", err)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "固定版本失败", "err", err)
+		httpError(w, http.StatusInternalServerError, "固定版本失败")
+		return
+	}
+	s.startSync(r, "admin")
+	s.actionResponse(w, r, true, true)
+}
+
+// AdminYank 撤回版本
+// @Summary 撤回或取消撤回版本
+// @Description POST 撤回版本：不再作为最新版本提供，回退到之前最近的未撤回正式版本 (撤回固定的版本时同时取消固定)；
+// @Description DELETE 取消撤回。随后刷新版本信息并在后台同步缓存
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body VersionRequest false "要撤回的版本和原因 (POST)"
+// @Param version query string false "要取消撤回的版本 (DELETE)" example(v1.2.0)
+// @Success 200 {object} AdminActionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /api/v1/admin/versions/yank [post]
+// @Router /api/v1/admin/versions/yank [delete]
+func (s *Server) AdminYank(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 POST 和 DELETE")
+		return
+	}
+	actor, ok := s.adminAuth(w, r, config.ScopeVersions)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		tag := r.URL.Query().Get("version")
+		if tag == "" {
+			httpError(w, http.StatusBadRequest, "缺少 version 参数")
+			return
+		}
+		found, err := s.pins.Unyank(tag)
+		if err == nil && !found {
+			if s.pins.Yanked(tag) {
+				httpError(w, http.StatusConflict, "版本由 release 标记撤回，需要在来源移除 "+s.config.Get().Yank.Label)
+				return
+			}
+			httpError(w, http.StatusNotFound, "版本未撤回: "+tag)
+			return
+		}
+		s.audit(r, actor, "versions.unyank", tag, "", err)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "取消撤回版本失败", "err", err)
+			httpError(w, http.StatusInternalServerError, "取消撤回版本失败")
+			return
+		}
+		s.startSync(r, "admin")
+		s.actionResponse(w, r, true, true)
+		return
+	}
+
+	req, ok := decodeVersionRequest(w, r)
+	if !ok {
+		return
+	}
+	// 撤回按 tag 精确匹配，先确认版本存在并使用来源中的 tag
+	rel, ok := s.getRelease(w, r, req.Version)
+	if !ok {
+		return
+	}
+	err := s.pins.Yank(admin.Yank{Version: rel.Tag, Reason: req.Reason, By: actor, At: time.Now()})
+	if err == nil && s.pins.Pinned() == rel.Tag {
+		err = s.pins.Pin("")
+	}
+	s.audit(r, actor, "versions.yank", rel.Tag, req.Reason, err)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "撤回版本失败", "err", err)
+		httpError(w, http.StatusInternalServerError, "撤回版本失败")
+		return
+	}
+	s.startSync(r, "admin")
+	s.actionResponse(w, r, true, true)
+}
+
+// AdminWebhooks 最近的 webhook 请求
+// @Summary 最近的 webhook 请求
+// @Description 最近 50 次 webhook 请求 (只保存在内存中，重启后清空)，新的在前
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} WebhookDelivery
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/webhooks [get]
+func (s *Server) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 GET")
+		return
+	}
+	if _, ok := s.adminAuth(w, r, config.ScopeRead); !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	jsonResponse(w, s.webhookLog.list())
+}
+
+// AdminAudit 审计日志
+// @Summary 管理操作审计日志
+// @Description 最近的管理操作 (刷新、同步、清除缓存、固定/撤回版本)，新的在前
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "条数 (默认 100，最多 1000)"
+// @Success 200 {array} admin.Entry
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/audit [get]
+func (s *Server) AdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, http.StatusMethodNotAllowed, "只支持 GET")
+		return
+	}
+	if _, ok := s.adminAuth(w, r, config.ScopeRead); !ok {
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			httpError(w, http.StatusBadRequest, "limit 应为 1-1000")
+			return
+		}
+		limit = n
+	}
+	entries, err := s.auditLog.Recent(limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "读取审计日志失败", "err", err)
+		httpError(w, http.StatusInternalServerError, "读取审计日志失败")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	jsonResponse(w, entries)
+}
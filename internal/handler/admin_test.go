@@ -0,0 +1,426 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"update-server/internal/admin"
+	"update-server/internal/config"
+	"update-server/internal/storage"
+)
+
+func newAdminTestServer(t *testing.T, modify func(c *config.Config)) *Server {
+	t.Helper()
+	root := t.TempDir()
+	for tag, date := range map[string]string{"v1.0.0": "2024-01-01", "v1.1.0": "2024-02-01", "v1.2.0": "2024-03-01"} {
+		os.MkdirAll(filepath.Join(root, tag), 0755)
+		os.WriteFile(filepath.Join(root, tag, "release.yaml"), []byte("date: "+date+"\n"), 0644)
+		os.WriteFile(filepath.Join(root, tag, "app.zip"), []byte("app "+tag), 0644)
+	}
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+		c.Admin.Tokens = []config.AdminToken{
+			{Name: "viewer", Token: "read-token", Scopes: []string{config.ScopeRead}},
+			{Name: "ops", Token: "ops-token", Scopes: []string{config.ScopeAll}},
+		}
+		if modify != nil {
+			modify(c)
+		}
+	})
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func adminRequest(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuth_Scopes(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/api/v1/admin/versions", "", http.StatusUnauthorized},
+		{"GET", "/api/v1/admin/versions", "wrong", http.StatusUnauthorized},
+		{"GET", "/api/v1/admin/versions", "read-token", http.StatusOK},
+		{"GET", "/api/v1/admin/cache", "read-token", http.StatusOK},
+		{"GET", "/api/v1/admin/audit", "read-token", http.StatusOK},
+		{"GET", "/api/v1/admin/webhooks", "read-token", http.StatusOK},
+		{"POST", "/api/v1/admin/refresh", "read-token", http.StatusForbidden},
+		{"DELETE", "/api/v1/admin/cache?version=v1.2.0", "read-token", http.StatusForbidden},
+		{"POST", "/api/v1/admin/versions/yank", "read-token", http.StatusForbidden},
+		{"POST", "/api/v1/admin/refresh", "ops-token", http.StatusOK},
+		{"GET", "/api/v1/admin/refresh", "ops-token", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := adminRequest(s, tt.method, tt.path, tt.token, ""); w.Code != tt.want {
+			t.Errorf("%s %s (%s): 期望 %d, 得到 %d: %s", tt.method, tt.path, tt.token, tt.want, w.Code, w.Body)
+		}
+	}
+
+	// 只配置 admin.token 时拥有全部权限
+	s2 := newTestServer(t, func(c *config.Config) { c.Admin.Token = "root" })
+	if w := adminRequest(s2, "GET", "/api/v1/admin/audit", "root", ""); w.Code != http.StatusOK {
+		t.Errorf("admin.token 期望 200, 得到 %d", w.Code)
+	}
+}
+
+func TestAdminAPI_Disabled(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+	for _, path := range []string{"/api/v1/admin/versions", "/api/v1/admin/audit", "/api/v1/admin/installs/active"} {
+		if w := adminRequest(s, "GET", path, "anything", ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: 未配置 admin token 时期望 404, 得到 %d", path, w.Code)
+		}
+	}
+}
+
+func TestAdminYankAndPin(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+
+	current := func() string {
+		var resp VersionsResponse
+		w := adminRequest(s, "GET", "/api/v1/admin/versions", "read-token", "")
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Current
+	}
+	if v := current(); v != "v1.2.0" {
+		t.Fatalf("期望当前版本 v1.2.0, 得到 %s", v)
+	}
+
+	w := adminRequest(s, "POST", "/api/v1/admin/versions/yank", "ops-token", `{"version":"v1.2.0","reason":"启动崩溃"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("撤回期望 200, 得到 %d: %s", w.Code, w.Body)
+	}
+	var resp AdminActionResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Version != "v1.1.0" || !resp.Syncing {
+		t.Errorf("撤回最新版本后期望回退到 v1.1.0, 得到 %+v", resp)
+	}
+
+	// 不能固定已撤回的版本
+	if w := adminRequest(s, "POST", "/api/v1/admin/versions/pin", "ops-token", `{"version":"v1.2.0"}`); w.Code != http.StatusConflict {
+		t.Errorf("固定已撤回的版本期望 409, 得到 %d", w.Code)
+	}
+	if w := adminRequest(s, "POST", "/api/v1/admin/versions/pin", "ops-token", `{"version":"v9.9.9"}`); w.Code != http.StatusNotFound {
+		t.Errorf("固定不存在的版本期望 404, 得到 %d", w.Code)
+	}
+	if w := adminRequest(s, "POST", "/api/v1/admin/versions/pin", "ops-token", `{"version":"v1.0.0"}`); w.Code != http.StatusOK {
+		t.Fatalf("固定期望 200, 得到 %d: %s", w.Code, w.Body)
+	}
+	if v := current(); v != "v1.0.0" {
+		t.Errorf("固定后期望 v1.0.0, 得到 %s", v)
+	}
+
+	// 撤回固定的版本时同时取消固定
+	adminRequest(s, "POST", "/api/v1/admin/versions/yank", "ops-token", `{"version":"v1.0.0"}`)
+	if s.pins.Pinned() != "" {
+		t.Errorf("撤回固定的版本后期望取消固定, 得到 %q", s.pins.Pinned())
+	}
+
+	if w := adminRequest(s, "DELETE", "/api/v1/admin/versions/yank?version=v1.2.0", "ops-token", ""); w.Code != http.StatusOK {
+		t.Fatalf("取消撤回期望 200, 得到 %d", w.Code)
+	}
+	if v := current(); v != "v1.2.0" {
+		t.Errorf("取消撤回后期望 v1.2.0, 得到 %s", v)
+	}
+	if w := adminRequest(s, "DELETE", "/api/v1/admin/versions/yank?version=v1.2.0", "ops-token", ""); w.Code != http.StatusNotFound {
+		t.Errorf("取消撤回未撤回的版本期望 404, 得到 %d", w.Code)
+	}
+
+	var entries []admin.Entry
+	w = adminRequest(s, "GET", "/api/v1/admin/audit?limit=10", "read-token", "")
+	json.Unmarshal(w.Body.Bytes(), &entries)
+	var actions []string
+	for _, e := range entries {
+		if e.Actor != "ops" {
+			t.Errorf("期望操作者 ops, 得到 %+v", e)
+		}
+		actions = append(actions, e.Action)
+	}
+	want := "versions.unyank,versions.yank,versions.pin,versions.yank"
+	if got := strings.Join(actions, ","); got != want {
+		t.Errorf("审计日志期望 %s, 得到 %s", want, got)
+	}
+}
+
+func TestAdminYank_ResolvesTag(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+
+	if w := adminRequest(s, "POST", "/api/v1/admin/versions/yank", "ops-token", `{"version":"v9.9.9"}`); w.Code != http.StatusNotFound {
+		t.Errorf("撤回不存在的版本期望 404, 得到 %d", w.Code)
+	}
+	if len(s.pins.Yanks()) != 0 {
+		t.Errorf("撤回不存在的版本不应记录, 得到 %+v", s.pins.Yanks())
+	}
+
+	// 不带 "v" 前缀的版本号按来源中的 tag 记录
+	w := adminRequest(s, "POST", "/api/v1/admin/versions/yank", "ops-token", `{"version":"1.2.0"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("撤回期望 200, 得到 %d: %s", w.Code, w.Body)
+	}
+	if !s.pins.Yanked("v1.2.0") {
+		t.Errorf("期望 v1.2.0 已撤回, 得到 %+v", s.pins.Yanks())
+	}
+	var resp AdminActionResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Version != "v1.1.0" {
+		t.Errorf("撤回最新版本后期望回退到 v1.1.0, 得到 %+v", resp)
+	}
+	if w := adminRequest(s, "DELETE", "/api/v1/admin/versions/yank?version=v1.2.0", "ops-token", ""); w.Code != http.StatusOK {
+		t.Errorf("按 tag 取消撤回期望 200, 得到 %d", w.Code)
+	}
+}
+
+func TestAdminCache_PurgeAndVerify(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+	ctx := context.Background()
+	store := s.cache.Storage()
+	store.Put(ctx, storage.Key("v1.2.0", "app.zip"), strings.NewReader("损坏的文件内容"), -1)
+	store.Put(ctx, storage.Key("v1.1.0", "app.zip"), strings.NewReader("app v1.1.0"), -1)
+
+	if w := adminRequest(s, "POST", "/api/v1/admin/cache/verify", "ops-token", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("缺少 version 期望 400, 得到 %d", w.Code)
+	}
+	w := adminRequest(s, "POST", "/api/v1/admin/cache/verify?version=v1.2.0", "ops-token", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("校验期望 200, 得到 %d: %s", w.Code, w.Body)
+	}
+	var verify VerifyResponse
+	json.Unmarshal(w.Body.Bytes(), &verify)
+	if verify.Failed != 1 || len(verify.Files) != 1 || !verify.Files[0].Removed {
+		t.Errorf("期望校验失败并删除, 得到 %+v", verify)
+	}
+	w = adminRequest(s, "POST", "/api/v1/admin/cache/verify?version=v1.1.0&file=app.zip", "ops-token", "")
+	json.Unmarshal(w.Body.Bytes(), &verify)
+	if verify.Failed != 0 || len(verify.Files) != 1 || !verify.Files[0].OK {
+		t.Errorf("期望校验通过, 得到 %+v", verify)
+	}
+
+	if w := adminRequest(s, "DELETE", "/api/v1/admin/cache?version=..", "ops-token", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("非法 version 期望 400, 得到 %d", w.Code)
+	}
+	w = adminRequest(s, "DELETE", "/api/v1/admin/cache?version=v1.1.0", "ops-token", "")
+	var purge PurgeResponse
+	json.Unmarshal(w.Body.Bytes(), &purge)
+	if w.Code != http.StatusOK || len(purge.Removed) != 1 || purge.Removed[0] != "v1.1.0/app.zip" {
+		t.Errorf("期望删除 v1.1.0/app.zip, 得到 %d %+v", w.Code, purge)
+	}
+	var status CacheStatus
+	json.Unmarshal(adminRequest(s, "GET", "/api/v1/admin/cache", "read-token", "").Body.Bytes(), &status)
+	if status.Objects != 0 {
+		t.Errorf("期望缓存为空, 得到 %+v", status)
+	}
+}
+
+func TestAdminWebhooks(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+
+	for _, event := range []string{"push", "release"} {
+		req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader([]byte(`{"action":"created","release":{"tag_name":"v1.3.0"}}`)))
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", "id-"+event)
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var deliveries []WebhookDelivery
+	w := adminRequest(s, "GET", "/api/v1/admin/webhooks", "read-token", "")
+	json.Unmarshal(w.Body.Bytes(), &deliveries)
+	if len(deliveries) != 2 {
+		t.Fatalf("期望 2 条 webhook 记录, 得到 %+v", deliveries)
+	}
+	if d := deliveries[0]; d.ID != "id-release" || d.Action != "created" || d.Tag != "v1.3.0" || d.Result != "ignored" {
+		t.Errorf("最新记录错误: %+v", d)
+	}
+	if d := deliveries[1]; d.ID != "id-push" || d.Event != "push" || d.Result != "ignored" {
+		t.Errorf("第二条记录错误: %+v", d)
+	}
+
+	for _, path := range []string{"/api/v1/admin/webhooks", "/api/v1/admin/audit"} {
+		if w := adminRequest(s, "POST", path, "ops-token", ""); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("POST %s 期望 405, 得到 %d", path, w.Code)
+		}
+	}
+}
+
+func TestCheckUpdate_Yanked(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, func(c *config.Config) { c.Yank.ForceDowngrade = true })
+
+	adminRequest(s, "POST", "/api/v1/admin/versions/yank", "ops-token", `{"version":"v1.2.0","reason":"启动崩溃"}`)
+
+	check := func(version string) UpdateCheckResponse {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/check-update?version="+version, nil))
+		var resp UpdateCheckResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	if resp := check("1.2.0"); !resp.Yanked || !resp.Downgrade || !resp.UpdateAvailable ||
+		resp.LatestVersion != "v1.1.0" || resp.YankReason != "启动崩溃" {
+		t.Errorf("撤回版本的客户端期望降级到 v1.1.0, 得到 %+v", resp)
+	}
+	if resp := check("v1.0.0"); resp.Yanked || resp.Downgrade || !resp.UpdateAvailable {
+		t.Errorf("旧版本客户端期望正常更新, 得到 %+v", resp)
+	}
+
+	var resources ResourcesResponse
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/resources", nil))
+	json.Unmarshal(w.Body.Bytes(), &resources)
+	if resources.Version != "v1.1.0" {
+		t.Errorf("资源列表期望回退到 v1.1.0, 得到 %s", resources.Version)
+	}
+}
+
+func TestYankLabel(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+	dir := s.config.Get().Release.Dir
+	os.WriteFile(filepath.Join(dir, "v1.2.0", "release.yaml"), []byte("name: v1.2.0 [yanked]\ndate: 2024-03-01\n"), 0644)
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.versions.Get().Version; v != "v1.1.0" {
+		t.Fatalf("release 标记撤回后期望回退到 v1.1.0, 得到 %s", v)
+	}
+
+	// 没有开启 force_downgrade 时只提示已撤回
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/check-update?version=v1.2.0", nil))
+	var resp UpdateCheckResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Yanked || resp.Downgrade || resp.UpdateAvailable {
+		t.Errorf("期望只提示已撤回, 得到 %+v", resp)
+	}
+
+	if w := adminRequest(s, "DELETE", "/api/v1/admin/versions/yank?version=v1.2.0", "ops-token", ""); w.Code != http.StatusConflict {
+		t.Errorf("取消 release 标记的撤回期望 409, 得到 %d", w.Code)
+	}
+
+	// 移除标记后恢复
+	os.WriteFile(filepath.Join(dir, "v1.2.0", "release.yaml"), []byte("date: 2024-03-01\n"), 0644)
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.versions.Get().Version; v != "v1.2.0" || s.pins.Yanked("v1.2.0") {
+		t.Errorf("移除标记后期望 v1.2.0, 得到 %s", v)
+	}
+}
+
+func TestYankLabel_OlderVersionDownload(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+	dir := s.config.Get().Release.Dir
+	os.WriteFile(filepath.Join(dir, "v1.0.0", "release.yaml"), []byte("name: v1.0.0 [yanked]\ndate: 2024-01-01\n"), 0644)
+	store := s.cache.Storage()
+	for _, tag := range []string{"v1.0.0", "v1.1.0"} {
+		if err := store.Put(context.Background(), storage.Key(tag, "app.zip"), strings.NewReader("app "+tag), -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 旧版本不是当前版本，刷新版本信息时不会读取它的撤回标记
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/v1.0.0/app.zip", nil))
+	if w.Code != http.StatusGone {
+		t.Errorf("下载 release 标记撤回的旧版本期望 410, 得到 %d", w.Code)
+	}
+	if !s.pins.Yanked("v1.0.0") {
+		t.Error("期望下载时同步 release 撤回标记")
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/v1.1.0/app.zip", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("下载未撤回的旧版本期望 200, 得到 %d", w.Code)
+	}
+}
+
+func TestYank_AllVersions(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, func(c *config.Config) { c.Yank.ForceDowngrade = true })
+	s.cache.Storage().Put(context.Background(), storage.Key("v1.2.0", "app.zip"), strings.NewReader("app v1.2.0"), -1)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	// 当前版本刚撤回、尚未刷新时，客户端同样收到撤回提示，但不建议降级到同一版本
+	if err := s.pins.Yank(admin.Yank{Version: "v1.2.0", Reason: "启动崩溃"}); err != nil {
+		t.Fatal(err)
+	}
+	var resp UpdateCheckResponse
+	json.Unmarshal(get("/api/v1/check-update?version=v1.2.0").Body.Bytes(), &resp)
+	if !resp.Yanked || resp.Downgrade || resp.UpdateAvailable {
+		t.Errorf("当前版本已撤回时期望只提示撤回, 得到 %+v", resp)
+	}
+
+	// 撤回的版本即使已缓存也不再提供下载
+	if w := get("/api/v1/download/v1.2.0/app.zip"); w.Code != http.StatusGone {
+		t.Errorf("下载已撤回的版本期望 410, 得到 %d", w.Code)
+	}
+
+	for _, v := range []string{"v1.1.0", "v1.0.0"} {
+		adminRequest(s, "POST", "/api/v1/admin/versions/yank", "ops-token", `{"version":"`+v+`"}`)
+	}
+	if info := s.versions.Get(); info != nil {
+		t.Errorf("所有版本都已撤回时不应继续提供 %s", info.Version)
+	}
+	if w := get("/api/v1/check-update?version=v1.2.0"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("所有版本都已撤回时检查更新期望 503, 得到 %d", w.Code)
+	}
+
+	// 取消撤回后恢复
+	adminRequest(s, "DELETE", "/api/v1/admin/versions/yank?version=v1.1.0", "ops-token", "")
+	if info := s.versions.Get(); info == nil || info.Version != "v1.1.0" {
+		t.Errorf("取消撤回后期望提供 v1.1.0, 得到 %+v", info)
+	}
+}
+
+func TestAdminSync_Conflict(t *testing.T) {
+	t.Parallel()
+	s := newAdminTestServer(t, nil)
+
+	// 另一个请求已经启动了同步
+	s.adminSyncing.Store(true)
+	if w := adminRequest(s, "POST", "/api/v1/admin/sync", "ops-token", ""); w.Code != http.StatusConflict {
+		t.Errorf("同步进行中期望 409, 得到 %d", w.Code)
+	}
+	s.adminSyncing.Store(false)
+
+	if w := adminRequest(s, "POST", "/api/v1/admin/sync", "ops-token", ""); w.Code != http.StatusAccepted {
+		t.Fatalf("期望 202, 得到 %d: %s", w.Code, w.Body)
+	}
+	for deadline := time.Now().Add(5 * time.Second); s.adminSyncing.Load(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("同步结束后应允许再次同步")
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"update-server/internal/analytics"
+	"update-server/internal/config"
+	"update-server/internal/logging"
+	"update-server/internal/storage"
+)
+
+// recordDownload 记录一次下载 (品牌、邀请码、国家、是否完成等)，dl 中已填好路径中的信息
+func (s *Server) recordDownload(r *http.Request, dl analytics.Download, source string, rec *ResponseRecorder) {
+	cfg := s.config.Get()
+	if !cfg.Analytics.On() {
+		return
+	}
+	outcome := downloadOutcome(r, rec)
+	if outcome == "" {
+		return
+	}
+
+	dl.Platform, _, dl.Arch = parseAssetName(dl.File)
+	dl.Country = s.clientCountry(r, cfg)
+	dl.Source = source
+	dl.Status = rec.status
+	dl.Outcome = outcome
+	if source != sourceRedirect {
+		dl.Bytes = rec.bytes
+	}
+	s.downloads.Record(dl)
+}
+
+// downloadOutcome 根据响应判断下载结果，返回空字符串表示不记录 (HEAD、304、416 等没有发送文件的响应)
+func downloadOutcome(r *http.Request, rec *ResponseRecorder) string {
+	if r.Method == http.MethodHead {
+		return ""
+	}
+	switch code := rec.status; {
+	case code == 0:
+		// 还没有响应客户端就断开了 (如等待来源下载时)
+		return analytics.OutcomeAborted
+	case code == http.StatusFound:
+		return analytics.OutcomeRedirected
+	case code >= 500:
+		return analytics.OutcomeFailed
+	case code != http.StatusOK && code != http.StatusPartialContent:
+		return ""
+	}
+
+	if length, err := strconv.ParseInt(rec.Header().Get("Content-Length"), 10, 64); err == nil {
+		if rec.bytes < length {
+			return analytics.OutcomeAborted
+		}
+	} else if r.Context().Err() != nil {
+		return analytics.OutcomeAborted
+	}
+	if rec.status == http.StatusPartialContent && !rangeReachesEnd(rec.Header().Get("Content-Range")) {
+		return analytics.OutcomePartial
+	}
+	return analytics.OutcomeCompleted
+}
+
+// rangeReachesEnd Content-Range (bytes start-end/size) 是否包含文件末尾，
+// 断点续传的最后一段算作完整下载。多段 Range 的响应没有 Content-Range，视为分段。
+func rangeReachesEnd(contentRange string) bool {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return false
+	}
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return false
+	}
+	_, end, ok := strings.Cut(rng, "-")
+	if !ok {
+		return false
+	}
+	e, err1 := strconv.ParseInt(end, 10, 64)
+	n, err2 := strconv.ParseInt(size, 10, 64)
+	return err1 == nil && err2 == nil && e+1 == n
+}
+
+// clientCountry 客户端所在国家
+//
+// 配置了 analytics.country_header 且请求来自受信任的代理 (如 CDN 回源) 时使用请求头，
+// 否则用 GeoIP 数据库按客户端 IP 查询。
+func (s *Server) clientCountry(r *http.Request, cfg *config.Config) string {
+	trusted, _ := cfg.TrustedProxies()
+	if h := cfg.Analytics.CountryHeader; h != "" && logging.FromTrustedProxy(r, trusted) {
+		if c := countryCode(r.Header.Get(h)); c != "" {
+			return c
+		}
+	}
+
+	addr, err := netip.ParseAddr(clientIP(r, trusted))
+	if err != nil {
+		return ""
+	}
+	return s.geoip.Load().Country(addr)
+}
+
+// clientIP 访问日志中间件解析出的客户端 IP，没有经过中间件时 (测试、嵌入) 重新解析
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	if req := logging.FromContext(r.Context()); req != nil {
+		return req.ClientIP
+	}
+	return logging.ClientIP(r, trusted)
+}
+
+// countryCode 只接受两位字母的国家代码，XX (未知) 等返回空字符串
+func countryCode(v string) string {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if len(v) != 2 || v[0] < 'A' || v[0] > 'Z' || v[1] < 'A' || v[1] > 'Z' || v == "XX" {
+		return ""
+	}
+	return v
+}
+
+// loadGeoIP 按配置 (重新) 加载 GeoIP 数据库，失败时不按 IP 判断国家
+func (s *Server) loadGeoIP(path string) {
+	if path == "" {
+		s.geoip.Store(nil)
+		return
+	}
+	g, err := analytics.OpenGeoIP(path)
+	if err != nil {
+		slog.Warn("加载 GeoIP 数据库失败", "err", err)
+		s.geoip.Store(nil)
+		return
+	}
+	s.geoip.Store(g)
+}
+
+// analyticsAuth 校验 analytics.token；没有打开本地数据库时没有下载记录，返回 404
+func (s *Server) analyticsAuth(w http.ResponseWriter, r *http.Request) bool {
+	if !requireToken(w, r, s.config.Get().Analytics.Token, "analytics.token") {
+		return false
+	}
+	if s.downloads == nil {
+		httpError(w, http.StatusNotFound, "未启用下载统计")
+		return false
+	}
+	return true
+}
+
+// pruneAnalytics 每小时删除超过 analytics.retention 的下载记录和超过 telemetry.retention 的活跃安装记录，
+// ctx 取消时停止
+func (s *Server) pruneAnalytics(ctx context.Context) {
+	for {
+		cfg := s.config.Get()
+		if retention := cfg.Analytics.Retention; retention > 0 {
+			n, err := s.downloads.Prune(time.Now().Add(-retention))
+			if err != nil {
+				slog.ErrorContext(ctx, "清理下载记录失败", "err", err)
+			} else if n > 0 {
+				slog.InfoContext(ctx, "已清理过期的下载记录", "count", n)
+			}
+		}
+		n, err := s.installs.Prune(s.today().Add(-cfg.Telemetry.Retention).Format(time.DateOnly))
+		if err != nil {
+			slog.ErrorContext(ctx, "清理活跃安装记录失败", "err", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "已清理过期的活跃安装记录", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Hour):
+		}
+	}
+}
+
+// DownloadReportResponse 按天汇总的下载统计
+type DownloadReportResponse struct {
+	From     string            `json:"from" example:"2024-01-01"`
+	To       string            `json:"to" example:"2024-01-30"`
+	Timezone string            `json:"timezone" example:"Asia/Shanghai"`
+	Days     []analytics.Daily `json:"days"`
+}
+
+// reportRange 统计的日期范围
+type reportRange struct {
+	from, to string // YYYY-MM-DD，包含两端
+	query    analytics.Query
+	loc      *time.Location
+}
+
+// parseReportRange 解析 from/to (YYYY-MM-DD，默认最近 30 天)、brand、invite_code 参数
+func (s *Server) parseReportRange(r *http.Request) (*reportRange, error) {
+	loc, err := s.config.Get().Analytics.Location()
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.URL.Query()
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if v := q.Get("to"); v != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+			return nil, fmt.Errorf("to 格式错误，应为 YYYY-MM-DD: %s", v)
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if v := q.Get("from"); v != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+			return nil, fmt.Errorf("from 格式错误，应为 YYYY-MM-DD: %s", v)
+		}
+	}
+	if from.After(to) {
+		return nil, fmt.Errorf("from 不能晚于 to")
+	}
+
+	return &reportRange{
+		from: from.Format(time.DateOnly),
+		to:   to.Format(time.DateOnly),
+		query: analytics.Query{
+			From:       from,
+			To:         to.AddDate(0, 0, 1),
+			Brand:      q.Get("brand"),
+			InviteCode: q.Get("invite_code"),
+		},
+		loc: loc,
+	}, nil
+}
+
+// DownloadReport 下载统计
+// @Summary 按天统计下载
+// @Description 按天 (analytics.timezone)、品牌、邀请码汇总下载次数、完成情况和流量，用于合作方结算
+// @Tags analytics
+// @Produce json
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "开始日期 (默认 29 天前)" example(2024-01-01)
+// @Param to query string false "结束日期 (包含，默认今天)" example(2024-01-30)
+// @Param brand query string false "品牌"
+// @Param invite_code query string false "邀请码"
+// @Param format query string false "json 或 csv" Enums(json, csv)
+// @Success 200 {object} DownloadReportResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/analytics/downloads [get]
+func (s *Server) DownloadReport(w http.ResponseWriter, r *http.Request) {
+	if !s.analyticsAuth(w, r) {
+		return
+	}
+	rr, err := s.parseReportRange(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		httpError(w, http.StatusBadRequest, "format 只支持 json 或 csv")
+		return
+	}
+
+	days, err := s.downloads.Daily(rr.query, rr.loc)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "读取下载记录失败", "err", err)
+		httpError(w, http.StatusInternalServerError, "读取下载记录失败")
+		return
+	}
+
+	if format != "csv" {
+		jsonResponse(w, DownloadReportResponse{
+			From:     rr.from,
+			To:       rr.to,
+			Timezone: rr.loc.String(),
+			Days:     days,
+		})
+		return
+	}
+
+	cw := csvResponse(w, fmt.Sprintf("downloads-daily-%s-%s.csv", rr.from, rr.to))
+	cw.Write([]string{"date", "brand", "invite_code", "requests", "completed", "partial", "aborted", "redirected", "failed", "bytes"})
+	for _, d := range days {
+		cw.Write([]string{
+			d.Date, d.Brand, d.InviteCode,
+			strconv.Itoa(d.Requests), strconv.Itoa(d.Completed), strconv.Itoa(d.Partial),
+			strconv.Itoa(d.Aborted), strconv.Itoa(d.Redirected), strconv.Itoa(d.Failed),
+			strconv.FormatInt(d.Bytes, 10),
+		})
+	}
+	cw.Flush()
+}
+
+// DownloadEvents 导出下载记录
+// @Summary 导出下载记录 (CSV)
+// @Description 导出日期范围内的每一次下载，时间使用 analytics.timezone
+// @Tags analytics
+// @Produce text/csv
+// @Security BearerAuth
+// @Param from query string false "开始日期 (默认 29 天前)" example(2024-01-01)
+// @Param to query string false "结束日期 (包含，默认今天)" example(2024-01-30)
+// @Param brand query string false "品牌"
+// @Param invite_code query string false "邀请码"
+// @Success 200 {file} binary
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/analytics/downloads/events [get]
+func (s *Server) DownloadEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.analyticsAuth(w, r) {
+		return
+	}
+	rr, err := s.parseReportRange(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cw := csvResponse(w, fmt.Sprintf("downloads-%s-%s.csv", rr.from, rr.to))
+	cw.Write([]string{"time", "brand", "invite_code", "version", "file", "platform", "arch", "country", "source", "status", "bytes", "outcome"})
+	err = s.downloads.Events(rr.query, func(d analytics.Download) error {
+		return cw.Write([]string{
+			d.Time.In(rr.loc).Format(time.RFC3339), d.Brand, d.InviteCode, d.Version, d.File,
+			d.Platform, d.Arch, d.Country, d.Source, strconv.Itoa(d.Status),
+			strconv.FormatInt(d.Bytes, 10), d.Outcome,
+		})
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		// 已经开始输出，只能记录日志
+		slog.ErrorContext(r.Context(), "导出下载记录失败", "err", err)
+	}
+}
+
+// csvResponse 设置 CSV 下载的响应头
+func csvResponse(w http.ResponseWriter, filename string) *csv.Writer {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", storage.ContentDisposition(filename))
+	return csv.NewWriter(w)
+}
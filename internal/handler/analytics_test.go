@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"update-server/internal/analytics"
+	"update-server/internal/config"
+)
+
+func TestDownloadAnalytics(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, func(c *config.Config) {
+		c.Analytics.Token = "secret"
+		c.Analytics.CountryHeader = "CF-IPCountry"
+		c.Analytics.Timezone = "UTC"
+		c.Server.TrustedProxies = []string{"192.0.2.0/24"}
+	})
+
+	dir := filepath.Join(s.config.Get().Cache.Dir, "v1.0.0")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "app-windows-amd64.zip"), []byte("0123456789"), 0644)
+
+	download := func(method, path, rangeHeader string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("CF-IPCountry", "de")
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+	download("GET", "/api/v1/download/orange/v1.0.0/INV1/app-windows-amd64.zip", "")
+	download("GET", "/api/v1/download/orange/v1.0.0/INV1/app-windows-amd64.zip", "bytes=0-3")
+	download("GET", "/api/v1/download/orange/v1.0.0/INV1/app-windows-amd64.zip", "bytes=4-")
+	download("GET", "/api/v1/download/v1.0.0/app-windows-amd64.zip", "")
+	// 没有发送文件的请求不记录
+	download("HEAD", "/api/v1/download/orange/v1.0.0/INV1/app-windows-amd64.zip", "")
+	download("GET", "/api/v1/download/orange/v1.0.0/INV1/app-windows-amd64.zip", "bytes=100-")
+	if code := download("GET", "/api/v1/download/orange/v9.9.9/INV1/missing.zip", ""); code != http.StatusNotFound {
+		t.Fatalf("期望 404, 得到 %d", code)
+	}
+
+	// 写入队列中的记录
+	s.downloads.Close()
+
+	report := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/analytics/downloads"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := report("", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("错误 token 期望 401, 得到 %d", w.Code)
+	}
+	if w := report("?from=2024-02-01&to=2024-01-01", "secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("from 晚于 to 期望 400, 得到 %d", w.Code)
+	}
+
+	w := report("", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望 200, 得到 %d: %s", w.Code, w.Body.String())
+	}
+	var resp DownloadReportResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	today := time.Now().UTC().Format(time.DateOnly)
+	if resp.To != today || resp.Timezone != "UTC" {
+		t.Errorf("期望默认截止到今天 (UTC), 得到 %+v", resp)
+	}
+	want := []analytics.Daily{
+		{Date: today, Requests: 1, Completed: 1, Bytes: 10},
+		{Date: today, Brand: "orange", InviteCode: "INV1", Requests: 3, Completed: 2, Partial: 1, Bytes: 20},
+	}
+	if len(resp.Days) != len(want) {
+		t.Fatalf("期望 %+v, 得到 %+v", want, resp.Days)
+	}
+	for i := range want {
+		if resp.Days[i] != want[i] {
+			t.Errorf("第 %d 行: 期望 %+v, 得到 %+v", i, want[i], resp.Days[i])
+		}
+	}
+
+	// 按邀请码过滤 + CSV
+	w = report("?invite_code=INV1&format=csv", "secret")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][2] != "INV1" || rows[1][3] != "3" {
+		t.Errorf("CSV 内容不匹配: %v", rows)
+	}
+
+	// 导出明细
+	req := httptest.NewRequest("GET", "/api/v1/analytics/downloads/events?brand=orange", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("期望 CSV, 得到 %s", w.Header().Get("Content-Type"))
+	}
+	rows, err = csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("期望表头 + 3 条记录, 得到 %v", rows)
+	}
+	// time,brand,invite_code,version,file,platform,arch,country,source,status,bytes,outcome
+	if got := strings.Join(rows[2][1:], ","); got != "orange,INV1,v1.0.0,app-windows-amd64.zip,windows,amd64,DE,cache,206,4,partial" {
+		t.Errorf("记录内容不匹配: %s", got)
+	}
+}
+
+func TestDownloadReport_Disabled(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/analytics/downloads", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未配置 token 期望 404, 得到 %d", w.Code)
+	}
+}
+
+func TestDownloadOutcome(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		bytes        int64
+		length       string
+		contentRange string
+		want         string
+	}{
+		{"完整", 200, 10, "10", "", analytics.OutcomeCompleted},
+		{"中途断开", 200, 4, "10", "", analytics.OutcomeAborted},
+		{"未响应就断开", 0, 0, "", "", analytics.OutcomeAborted},
+		{"分段", 206, 4, "4", "bytes 0-3/10", analytics.OutcomePartial},
+		{"续传到末尾", 206, 6, "6", "bytes 4-9/10", analytics.OutcomeCompleted},
+		{"重定向", 302, 0, "", "", analytics.OutcomeRedirected},
+		{"服务端错误", 500, 30, "", "", analytics.OutcomeFailed},
+		{"未修改", 304, 0, "", "", ""},
+		{"Range 无效", 416, 0, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &ResponseRecorder{ResponseWriter: httptest.NewRecorder(), status: tt.status, bytes: tt.bytes}
+			if tt.length != "" {
+				rec.Header().Set("Content-Length", tt.length)
+			}
+			if tt.contentRange != "" {
+				rec.Header().Set("Content-Range", tt.contentRange)
+			}
+			if got := downloadOutcome(httptest.NewRequest("GET", "/", nil), rec); got != tt.want {
+				t.Errorf("期望 %q, 得到 %q", tt.want, got)
+			}
+		})
+	}
+}
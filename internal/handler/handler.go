@@ -1,16 +1,25 @@
 package handler
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	"update-server/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+
+	"update-server/internal/admin"
+	"update-server/internal/analytics"
+	"update-server/internal/release"
+	"update-server/internal/storage"
+	"update-server/internal/tracing"
 	"update-server/internal/version"
 )
 
@@ -20,6 +29,9 @@ type UpdateCheckResponse struct {
 	LatestVersion   string `json:"latest_version" example:"v1.2.0"`
 	ReleaseNotes    string `json:"release_notes,omitempty" example:"Bug fixes and improvements"`
 	DownloadURL     string `json:"download_url" example:"https://example.com"`
+	Yanked          bool   `json:"yanked,omitempty"`      // 客户端当前版本已撤回
+	YankReason      string `json:"yank_reason,omitempty"` // 撤回原因
+	Downgrade       bool   `json:"downgrade,omitempty"`   // 建议降级到 latest_version (yank.force_downgrade)
 }
 
 // ErrorResponse 错误响应
@@ -40,7 +52,7 @@ type RootResponse struct {
 // @Produce json
 // @Success 200 {object} RootResponse
 // @Router / [get]
-func Root(w http.ResponseWriter, r *http.Request) {
+func (s *Server) Root(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
@@ -53,38 +65,111 @@ func Root(w http.ResponseWriter, r *http.Request) {
 
 // CheckUpdate 检查更新
 // @Summary 检查客户端是否有新版本
-// @Description 根据客户端版本号判断是否需要更新
+// @Description 根据客户端版本号判断是否需要更新；客户端版本已撤回时返回 yanked，开启 yank.force_downgrade 时建议降级
 // @Tags update
 // @Produce json
 // @Param version query string true "客户端当前版本号" example("v1.0.0")
+// @Param platform query string false "客户端平台 (仅用于统计)" example("windows")
+// @Param channel query string false "更新渠道 (仅用于统计)" example("stable")
+// @Param install_id query string false "客户端生成的匿名安装 ID (仅用于统计活跃安装，只保存哈希)"
+// @Param arch query string false "客户端架构 (仅用于统计)" example("amd64")
+// @Param os_version query string false "操作系统版本 (仅用于统计)" example("10.0.22631")
+// @Param brand query string false "品牌 (仅用于统计)" example("orange")
 // @Success 200 {object} UpdateCheckResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 503 {object} ErrorResponse
 // @Router /api/v1/check-update [get]
-func CheckUpdate(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CheckUpdate(w http.ResponseWriter, r *http.Request) {
 	clientVersion := r.URL.Query().Get("version")
 	if clientVersion == "" {
 		httpError(w, http.StatusBadRequest, "缺少 version 参数")
 		return
 	}
 
-	info := version.Get()
+	info := s.versions.Get()
 	if info == nil {
 		httpError(w, http.StatusServiceUnavailable, "版本信息暂不可用")
 		return
 	}
 
-	cfg := config.Get()
+	query := r.URL.Query()
+	s.metrics.checkUpdates.Inc(s.versionLabel(clientVersion, info),
+		enumLabel(query.Get("platform"), knownPlatforms), enumLabel(query.Get("channel"), knownChannels))
+	s.recordCheckin(r, clientVersion)
+
+	cfg := s.config.Get()
 	latestVer := strings.TrimPrefix(info.Version, "v")
 	clientVer := strings.TrimPrefix(clientVersion, "v")
 	updateAvailable := latestVer != clientVer && latestVer > clientVer
 
-	jsonResponse(w, UpdateCheckResponse{
+	resp := UpdateCheckResponse{
 		UpdateAvailable: updateAvailable,
 		LatestVersion:   info.Version,
 		ReleaseNotes:    info.ReleaseNotes,
 		DownloadURL:     cfg.Server.BaseURL,
-	})
+	}
+	// 客户端仍在使用撤回的版本；当前版本刚被撤回、尚未刷新时同样提示，但没有可降级的版本
+	if y, ok := s.yankedVersion(clientVersion); ok {
+		resp.Yanked, resp.YankReason = true, y.Reason
+		if cfg.Yank.ForceDowngrade && !updateAvailable && latestVer != clientVer {
+			resp.UpdateAvailable, resp.Downgrade = true, true
+		}
+	}
+
+	s.cachedJSONResponse(w, r, info, resp)
+}
+
+// labelReadInterval 按需读取的 release 撤回标记的有效期
+const labelReadInterval = 5 * time.Minute
+
+// yanked 下载的版本是否已撤回 (运维撤回或 release 标记)
+//
+// release 标记在读取 release 时才同步到 pins：当前版本刷新时已经读取过，
+// 缓存中的旧版本按需读取来源，labelReadInterval 内不重复读取；缓存中没有的版本不会提供下载，无需读取。
+func (s *Server) yanked(ctx context.Context, store storage.Storage, tag, key string) bool {
+	if s.pins.Yanked(tag) {
+		return true
+	}
+	if info := s.versions.Get(); info != nil && info.Version == tag {
+		return false
+	}
+	if _, err := store.Stat(ctx, key); err != nil {
+		return false
+	}
+
+	s.labelMu.Lock()
+	due := time.Since(s.labelRead[tag]) > labelReadInterval
+	if due {
+		s.labelRead[tag] = time.Now()
+	}
+	s.labelMu.Unlock()
+	if !due {
+		return false
+	}
+	// policy 来源的 GetRelease 会把撤回标记同步给 pins
+	if _, err := s.releases.GetRelease(ctx, tag); err != nil && !errors.Is(err, release.ErrNotFound) {
+		slog.WarnContext(ctx, "读取版本撤回标记失败", "tag", tag, "err", err)
+	}
+	return s.pins.Yanked(tag)
+}
+
+// yankedVersion 客户端版本的撤回记录，客户端版本号可能不带 tag 的 "v" 前缀
+func (s *Server) yankedVersion(clientVersion string) (admin.Yank, bool) {
+	for _, tag := range tagCandidates(clientVersion) {
+		if y, ok := s.pins.Get(tag); ok {
+			return y, true
+		}
+	}
+	return admin.Yank{}, false
+}
+
+// tagCandidates 用户输入的版本号可能与 tag 的 "v" 前缀不一致，依次尝试原样、加 "v"、去掉 "v"
+func tagCandidates(version string) []string {
+	trimmed := strings.TrimPrefix(version, "v")
+	if version == trimmed {
+		return []string{version, "v" + trimmed}
+	}
+	return []string{version, trimmed}
 }
 
 // Version 获取最新版本信息
@@ -95,13 +180,13 @@ func CheckUpdate(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} version.Info
 // @Failure 503 {object} ErrorResponse
 // @Router /api/v1/version [get]
-func Version(w http.ResponseWriter, r *http.Request) {
-	info := version.Get()
+func (s *Server) Version(w http.ResponseWriter, r *http.Request) {
+	info := s.versions.Get()
 	if info == nil {
 		httpError(w, http.StatusServiceUnavailable, "版本信息暂不可用")
 		return
 	}
-	jsonResponse(w, info)
+	s.cachedJSONResponse(w, r, info, info)
 }
 
 // BuildInfo 构建文件信息
@@ -131,14 +216,14 @@ type ResourcesResponse struct {
 // @Success 200 {object} ResourcesResponse
 // @Failure 503 {object} ErrorResponse
 // @Router /api/v1/resources [get]
-func Resources(w http.ResponseWriter, r *http.Request) {
-	info := version.Get()
+func (s *Server) Resources(w http.ResponseWriter, r *http.Request) {
+	info := s.versions.Get()
 	if info == nil {
 		httpError(w, http.StatusServiceUnavailable, "版本信息暂不可用")
 		return
 	}
 
-	cfg := config.Get()
+	cfg := s.config.Get()
 	builds := make(map[string][]BuildInfo)
 
 	for _, asset := range info.Assets {
@@ -161,7 +246,7 @@ func Resources(w http.ResponseWriter, r *http.Request) {
 		builds[platform] = append(builds[platform], build)
 	}
 
-	jsonResponse(w, ResourcesResponse{
+	s.cachedJSONResponse(w, r, info, ResourcesResponse{
 		Status:  "success",
 		Version: info.Version,
 		Builds:  builds,
@@ -242,25 +327,24 @@ func parseAssetName(name string) (platform, fileType, arch string) {
 // @Success 200 {file} binary
 // @Failure 400 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/download/{version}/{filename} [get]
-func Download(w http.ResponseWriter, r *http.Request) {
-	cfg := config.Get()
-
+func (s *Server) Download(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/api/v1/download/")
 	parts := strings.Split(path, "/")
 
 	// 支持多种格式:
 	// - /api/v1/download/{version}/{filename}
 	// - /api/v1/download/{brand}/{version}/{invite_code}/{filename}
-	var ver, filename string
+	var brand, ver, inviteCode, filename string
 	switch len(parts) {
 	case 2:
 		// 格式: {version}/{filename}
 		ver, filename = parts[0], parts[1]
 	case 4:
 		// 格式: {brand}/{version}/{invite_code}/{filename}
-		ver, filename = parts[1], parts[3]
+		brand, ver, inviteCode, filename = parts[0], parts[1], parts[2], parts[3]
 	default:
 		httpError(w, http.StatusBadRequest, "无效的下载路径")
 		return
@@ -271,89 +355,169 @@ func Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cachePath := filepath.Join(cfg.CacheDir, ver, filename)
-	if _, err := os.Stat(cachePath); err == nil {
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-		http.ServeFile(w, r, cachePath)
+	store := s.cache.Storage()
+	rec := &ResponseRecorder{ResponseWriter: w}
+	dl := analytics.Download{Brand: brand, InviteCode: inviteCode, Version: ver, File: filename}
+
+	key := storage.Key(ver, filename)
+	// 撤回的版本不再提供下载，即使缓存中仍有文件
+	if s.yanked(r.Context(), store, ver, key) {
+		httpError(w, http.StatusGone, "版本已撤回")
+		return
+	}
+	if source, ok := s.serveCached(rec, r, store, key, filename); ok {
+		s.observeDownload(filename, source, rec)
+		s.recordDownload(r, dl, source, rec)
 		return
 	}
 
-	info := version.Get()
+	info := s.versions.Get()
 	if info == nil || info.Version != ver {
 		httpError(w, http.StatusNotFound, "版本不存在")
 		return
 	}
 
+	var asset release.Asset
 	var found bool
-	for _, asset := range info.Assets {
-		if asset.Name == filename {
-			found = true
-			break
-		}
+	if info.Release != nil {
+		asset, found = info.Release.Asset(filename)
 	}
-
 	if !found {
 		httpError(w, http.StatusNotFound, "文件不存在")
 		return
 	}
 
-	downloadURL := fmt.Sprintf("https://github.com/%s/releases/download/%s/%s",
-		cfg.Release.Repo, ver, filename)
+	if err := s.cache.Fetch(r.Context(), info.Release, asset); err != nil {
+		// 客户端已断开时不再响应，记录为中途放弃
+		if r.Context().Err() == nil {
+			httpError(rec, http.StatusInternalServerError, "下载文件失败")
+		}
+		s.recordDownload(r, dl, sourceUpstream, rec)
+		return
+	}
 
-	if err := downloadAndCache(downloadURL, cachePath, cfg.Release.Token); err != nil {
-		httpError(w, http.StatusInternalServerError, "下载文件失败")
+	source, ok := s.serveCached(rec, r, store, key, filename)
+	if !ok {
+		httpError(rec, http.StatusInternalServerError, "读取缓存失败")
+		s.recordDownload(r, dl, sourceUpstream, rec)
 		return
 	}
+	if source == sourceCache {
+		source = sourceUpstream
+	}
+	s.observeDownload(filename, source, rec)
+	s.recordDownload(r, dl, source, rec)
+}
 
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	http.ServeFile(w, r, cachePath)
+// observeDownload 记录成功发出的下载 (不含 304 等无内容的响应)
+func (s *Server) observeDownload(filename, source string, rec *ResponseRecorder) {
+	if code := rec.StatusCode(); code != http.StatusOK && code != http.StatusPartialContent && code != http.StatusFound {
+		return
+	}
+	s.metrics.downloads.Inc(filename, source)
+	if source != sourceRedirect {
+		s.metrics.downloadBytes.Add(float64(rec.bytes), filename, source)
+	}
 }
 
-func downloadAndCache(url, cachePath, token string) error {
-	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
-		return err
+// serveCached 从缓存提供文件，返回下载来源 (cache 或 redirect)，缓存不存在时返回 false
+//
+// S3 后端开启 redirect 时 302 到预签名 URL，否则由本服务转发 (支持 Range)。
+func (s *Server) serveCached(w http.ResponseWriter, r *http.Request, store storage.Storage, key, filename string) (string, bool) {
+	cfg := s.config.Get()
+
+	if p, ok := store.(storage.Presigner); ok && cfg.Storage.S3.Redirect {
+		if _, err := store.Stat(r.Context(), key); err != nil {
+			return "", false
+		}
+		u, err := p.PresignGet(r.Context(), key, filename, cfg.Storage.S3.PresignExpiry)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "生成预签名 URL 失败", "err", err)
+			return "", false
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+		return sourceRedirect, true
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	obj, info, err := store.Open(r.Context(), key)
 	if err != nil {
-		return err
-	}
-	if token != "" {
-		req.Header.Set("Authorization", "token "+token)
+		if !errors.Is(err, storage.ErrNotExist) {
+			slog.ErrorContext(r.Context(), "读取缓存失败", "key", key, "err", err)
+		}
+		return "", false
 	}
+	defer obj.Close()
+
+	// 发送给客户端的时间 (慢客户端或网络)，与按需下载的 cache.Fetch 区分
+	_, span := tracing.Start(r.Context(), "download.send",
+		attribute.String("cache.key", key),
+		attribute.Int64("cache.size", info.Size),
+	)
+	defer span.End()
+
+	w.Header().Set("Content-Disposition", storage.ContentDisposition(filename))
+	http.ServeContent(w, r, filename, info.ModTime, obj)
+	return sourceCache, true
+}
 
-	client := &http.Client{Timeout: 10 * time.Minute}
-	resp, err := client.Do(req)
+func jsonResponse(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// cachedJSONResponse 输出可缓存的 JSON，支持 If-None-Match 条件请求
+//
+// 强 ETag 由版本号和内容哈希组成，内容不变时客户端和 CDN 收到 304。
+// 不设置 Last-Modified：撤回或固定版本后发布时间会倒退，撤回状态也会改变响应内容，只能按 ETag 判断。
+// 带 install_id 的请求按客户端区分，只允许客户端自身缓存。
+func (s *Server) cachedJSONResponse(w http.ResponseWriter, r *http.Request, info *version.Info, data any) {
+	body, err := json.Marshal(data)
 	if err != nil {
-		return err
+		httpError(w, http.StatusInternalServerError, "序列化响应失败")
+		return
 	}
-	defer resp.Body.Close()
+	body = append(body, '\n')
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("下载失败: %d", resp.StatusCode)
+	sum := sha256.Sum256(body)
+	etag := fmt.Sprintf(`"%s-%s"`, etagSafe(info.Version), hex.EncodeToString(sum[:8]))
+	scope := "public"
+	if r.URL.Query().Get("install_id") != "" {
+		scope = "private"
 	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int(s.config.Get().CacheMaxAge().Seconds())))
 
-	tmpPath := cachePath + ".tmp"
-	f, err := os.Create(tmpPath)
-	if err != nil {
-		return err
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
-
-	// 使用固定 32KB buffer 避免内存膨胀
-	buf := make([]byte, 32*1024)
-	_, err = io.CopyBuffer(f, resp.Body, buf)
-	f.Close()
-	if err != nil {
-		os.Remove(tmpPath)
-		return err
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
 	}
+}
 
-	return os.Rename(tmpPath, cachePath)
+// etagMatch 按弱比较判断 If-None-Match 是否包含 etag (压缩中间件会把 ETag 改为弱 ETag)
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
 
-func jsonResponse(w http.ResponseWriter, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+// etagSafe 替换 ETag 中不允许的字符
+func etagSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= 0x20 || r >= 0x7f || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
 }
 
 func httpError(w http.ResponseWriter, code int, message string) {
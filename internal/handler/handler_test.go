@@ -1,52 +1,55 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"update-server/internal/config"
+	"update-server/internal/version"
 )
 
-// 初始化测试配置
-func setupTestConfig(t *testing.T) (string, func()) {
-	tmpDir, err := os.MkdirTemp("", "update-server-test")
-	if err != nil {
-		t.Fatal(err)
+// newTestServer 创建隔离的测试实例 (独立的配置、缓存目录和版本信息)，modify 可调整默认配置
+func newTestServer(t *testing.T, modify func(c *config.Config)) *Server {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Server.BaseURL = "http://localhost:8080"
+	cfg.Release.Repo = "test/repo"
+	cfg.Cache.Dir = t.TempDir()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "orange.db")
+	if modify != nil {
+		modify(cfg)
 	}
 
-	configContent := `
-server:
-  port: 8080
-  host: "127.0.0.1"
-  base_url: "http://localhost:8080"
-github:
-  repo: "test/repo"
-cache:
-  dir: "` + filepath.Join(tmpDir, "cache") + `"
-`
-	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+	s, err := New(config.NewStatic(cfg))
+	if err != nil {
 		t.Fatal(err)
 	}
-
-	os.Setenv("CONFIG_PATH", configPath)
-	config.Load()
-
-	return tmpDir, func() {
-		os.RemoveAll(tmpDir)
-		os.Unsetenv("CONFIG_PATH")
-	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+		s.Close()
+	})
+	return s
 }
 
 func TestRoot(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
 	req := httptest.NewRequest("GET", "/", nil)
 	w := httptest.NewRecorder()
 
-	Root(w, req)
+	s.Root(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("期望状态码 200, 得到 %d", w.Code)
@@ -57,16 +60,19 @@ func TestRoot(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if resp["app"] != "update-server" {
-		t.Errorf("期望 app=update-server, 得到 %v", resp["app"])
+	if resp["app"] != "orange-service" {
+		t.Errorf("期望 app=orange-service, 得到 %v", resp["app"])
 	}
 }
 
 func TestRoot_NotFound(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
 	req := httptest.NewRequest("GET", "/invalid", nil)
 	w := httptest.NewRecorder()
 
-	Root(w, req)
+	s.Root(w, req)
 
 	if w.Code != http.StatusNotFound {
 		t.Errorf("期望状态码 404, 得到 %d", w.Code)
@@ -74,10 +80,13 @@ func TestRoot_NotFound(t *testing.T) {
 }
 
 func TestCheckUpdate_MissingVersion(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
 	req := httptest.NewRequest("GET", "/api/v1/check-update", nil)
 	w := httptest.NewRecorder()
 
-	CheckUpdate(w, req)
+	s.CheckUpdate(w, req)
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("期望状态码 400, 得到 %d", w.Code)
@@ -85,10 +94,13 @@ func TestCheckUpdate_MissingVersion(t *testing.T) {
 }
 
 func TestCheckUpdate_NoVersionInfo(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
 	req := httptest.NewRequest("GET", "/api/v1/check-update?version=1.0.0", nil)
 	w := httptest.NewRecorder()
 
-	CheckUpdate(w, req)
+	s.CheckUpdate(w, req)
 
 	// 没有版本信息时应返回 503
 	if w.Code != http.StatusServiceUnavailable {
@@ -97,19 +109,85 @@ func TestCheckUpdate_NoVersionInfo(t *testing.T) {
 }
 
 func TestVersion_NoVersionInfo(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
 	req := httptest.NewRequest("GET", "/api/v1/version", nil)
 	w := httptest.NewRecorder()
 
-	Version(w, req)
+	s.Version(w, req)
 
 	if w.Code != http.StatusServiceUnavailable {
 		t.Errorf("期望状态码 503, 得到 %d", w.Code)
 	}
 }
 
+func TestCheckUpdate_ConditionalRequest(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+	s.versions.Set(&version.Info{Version: "v1.2.0", PublishedAt: "2024-01-02T03:04:05Z"})
+
+	req := httptest.NewRequest("GET", "/api/v1/check-update?version=v1.0.0", nil)
+	w := httptest.NewRecorder()
+	s.CheckUpdate(w, req)
+
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `"v1.2.0-`) {
+		t.Fatalf("期望 200 和以版本号开头的 ETag, 得到 %d %q", w.Code, etag)
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Errorf("Cache-Control 错误: %q", w.Header().Get("Cache-Control"))
+	}
+	if w.Header().Get("Last-Modified") != "" {
+		t.Errorf("撤回后发布时间会倒退，不应设置 Last-Modified, 得到 %q", w.Header().Get("Last-Modified"))
+	}
+
+	// 内容未变化时返回 304
+	req = httptest.NewRequest("GET", "/api/v1/check-update?version=v1.0.0", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.CheckUpdate(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("期望 304 且无响应体, 得到 %d (%d 字节)", w.Code, w.Body.Len())
+	}
+
+	// 压缩中间件返回的弱 ETag 同样匹配；Range 不生效
+	req = httptest.NewRequest("GET", "/api/v1/check-update?version=v1.0.0", nil)
+	req.Header.Set("If-None-Match", "W/"+etag)
+	w = httptest.NewRecorder()
+	s.CheckUpdate(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("弱 ETag 期望 304, 得到 %d", w.Code)
+	}
+	req = httptest.NewRequest("GET", "/api/v1/check-update?version=v1.0.0", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	w = httptest.NewRecorder()
+	s.CheckUpdate(w, req)
+	if w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) {
+		t.Errorf("期望忽略 Range 返回完整 JSON, 得到 %d %q", w.Code, w.Body.String())
+	}
+
+	// 带安装 ID 的响应不允许共享缓存
+	req = httptest.NewRequest("GET", "/api/v1/check-update?version=v1.0.0&install_id=abc", nil)
+	w = httptest.NewRecorder()
+	s.CheckUpdate(w, req)
+	if w.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Errorf("带 install_id 时期望 private, 得到 %q", w.Header().Get("Cache-Control"))
+	}
+
+	// 不同客户端版本的响应内容不同，ETag 也不同
+	req = httptest.NewRequest("GET", "/api/v1/check-update?version=v1.2.0", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.CheckUpdate(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("期望 200 和新的 ETag, 得到 %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
 func TestDownload_InvalidPath(t *testing.T) {
-	_, cleanup := setupTestConfig(t)
-	defer cleanup()
+	t.Parallel()
+	s := newTestServer(t, nil)
 
 	tests := []struct {
 		name string
@@ -127,7 +205,7 @@ func TestDownload_InvalidPath(t *testing.T) {
 			req := httptest.NewRequest("GET", tt.path, nil)
 			w := httptest.NewRecorder()
 
-			Download(w, req)
+			s.Download(w, req)
 
 			if w.Code != tt.code {
 				t.Errorf("期望状态码 %d, 得到 %d", tt.code, w.Code)
@@ -137,13 +215,13 @@ func TestDownload_InvalidPath(t *testing.T) {
 }
 
 func TestDownload_VersionNotFound(t *testing.T) {
-	_, cleanup := setupTestConfig(t)
-	defer cleanup()
+	t.Parallel()
+	s := newTestServer(t, nil)
 
 	req := httptest.NewRequest("GET", "/api/v1/download/v999.0.0/test.zip", nil)
 	w := httptest.NewRecorder()
 
-	Download(w, req)
+	s.Download(w, req)
 
 	// 版本不存在时返回 404
 	if w.Code != http.StatusNotFound {
@@ -152,13 +230,11 @@ func TestDownload_VersionNotFound(t *testing.T) {
 }
 
 func TestDownload_FromCache(t *testing.T) {
-	_, cleanup := setupTestConfig(t)
-	defer cleanup()
-
-	cfg := config.Get()
+	t.Parallel()
+	s := newTestServer(t, nil)
 
 	// 创建缓存文件
-	cacheDir := filepath.Join(cfg.CacheDir, "v1.0.0")
+	cacheDir := filepath.Join(s.config.Get().Cache.Dir, "v1.0.0")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		t.Fatal(err)
 	}
@@ -172,7 +248,7 @@ func TestDownload_FromCache(t *testing.T) {
 	req := httptest.NewRequest("GET", "/api/v1/download/v1.0.0/test.zip", nil)
 	w := httptest.NewRecorder()
 
-	Download(w, req)
+	s.Download(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("期望状态码 200, 得到 %d", w.Code)
@@ -183,6 +259,37 @@ func TestDownload_FromCache(t *testing.T) {
 	}
 }
 
+func TestDownload_LocalRelease(t *testing.T) {
+	t.Parallel()
+
+	// 离线部署：版本来自本地目录，按需下载无需访问 GitHub
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "v1.2.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "v1.2.0", "release.yaml"), []byte("notes: 离线版本\n"), 0644)
+	os.WriteFile(filepath.Join(root, "v1.2.0", "app.zip"), []byte("offline"), 0644)
+
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+	})
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if info := s.versions.Get(); info.Version != "v1.2.0" || info.ReleaseNotes != "离线版本" {
+		t.Errorf("期望版本 v1.2.0, 得到 %+v", info)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/download/v1.2.0/app.zip", nil)
+	w := httptest.NewRecorder()
+	s.Download(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "offline" {
+		t.Errorf("期望 200 offline, 得到 %d %q", w.Code, w.Body.String())
+	}
+}
+
 // 测试版本比较逻辑
 func TestVersionComparison(t *testing.T) {
 	tests := []struct {
@@ -258,3 +365,91 @@ func TestHTTPError(t *testing.T) {
 		t.Error("错误消息不正确")
 	}
 }
+
+func TestConfigReload_StorageFailureKeepsConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	os.WriteFile(blocker, []byte("x"), 0644)
+
+	path := filepath.Join(dir, "config.yaml")
+	write := func(cacheDir string) {
+		content := "release:\n  repo: test/repo\ncache:\n  dir: " + cacheDir + "\ndatabase:\n  path: " + filepath.Join(dir, "orange.db") + "\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "cache"))
+	cfg, err := config.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	store := s.cache.Storage()
+
+	// 缓存目录无法创建时放弃重载，配置和存储都保持不变
+	write(filepath.Join(blocker, "cache"))
+	if err := cfg.Reload(); err == nil {
+		t.Error("存储初始化失败时重载应失败")
+	}
+	if cfg.Get().Cache.Dir != filepath.Join(dir, "cache") || s.cache.Storage() != store {
+		t.Errorf("重载失败后不应替换配置或存储, 得到 %s", cfg.Get().Cache.Dir)
+	}
+
+	write(filepath.Join(dir, "cache2"))
+	if err := cfg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s.cache.Storage() == store {
+		t.Error("重载成功后应使用新的存储")
+	}
+}
+
+func TestDatabase_OnlyWhenNeeded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "orange.db")
+	path := filepath.Join(dir, "config.yaml")
+	write := func(analytics bool) {
+		content := fmt.Sprintf("release:\n  repo: test/repo\ncache:\n  dir: %s\ndatabase:\n  path: %s\nanalytics:\n  enabled: %t\ntelemetry:\n  enabled: false\n",
+			filepath.Join(dir, "cache"), dbPath, analytics)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(false)
+	cfg, err := config.NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Errorf("没有启用使用数据库的功能时不应创建数据库文件, 得到 %v", err)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v1/analytics/downloads", nil)
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未启用下载统计期望 404, 得到 %d", w.Code)
+	}
+
+	// 运行中启用下载统计需要重启
+	write(true)
+	if err := cfg.Reload(); err == nil {
+		t.Error("没有打开数据库时启用下载统计应拒绝重载")
+	}
+	if cfg.Get().Analytics.On() {
+		t.Error("重载失败后不应替换配置")
+	}
+}
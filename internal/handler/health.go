@@ -0,0 +1,293 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"update-server/internal/cache"
+	"update-server/internal/version"
+)
+
+const (
+	// domainsFreshness domains.json 在该时间内获取成功视为新鲜，超过后 readyz 会重新获取
+	domainsFreshness = 5 * time.Minute
+	// domainsRetryInterval 获取失败后的重试间隔，期间 readyz 直接返回上次的错误
+	domainsRetryInterval = 30 * time.Second
+	// probeInterval 缓存可写检查结果的有效期，避免负载均衡频繁探测时反复写入存储
+	probeInterval = 30 * time.Second
+	// probeKey 检查缓存可写时写入并立即删除的对象，放在根目录下，删除后不留下空目录
+	probeKey = ".readyz-probe"
+)
+
+// healthState readyz 和 status 使用的状态
+type healthState struct {
+	mu sync.Mutex
+
+	domainsAt    time.Time // 最近一次获取 domains.json 的时间
+	domainsOK    time.Time // 最近一次获取成功的时间
+	domainsError string
+
+	probeAt      time.Time
+	probeErr     error
+	probeRunning bool // 探测进行中，其他请求返回上次的结果
+
+	// domainsCheck 串行化 readyz 触发的获取，避免并发探测同时请求 GitHub
+	domainsCheck sync.Mutex
+}
+
+func (h *healthState) recordDomains(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.domainsAt = time.Now()
+	if err != nil {
+		h.domainsError = err.Error()
+		return
+	}
+	h.domainsOK, h.domainsError = h.domainsAt, ""
+}
+
+// DomainsStatus domains.json 的获取状态
+type DomainsStatus struct {
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+func (h *healthState) domains() DomainsStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return DomainsStatus{LastAttempt: h.domainsAt, LastSuccess: h.domainsOK, LastError: h.domainsError}
+}
+
+// HealthResponse 健康检查响应
+type HealthResponse struct {
+	Status string            `json:"status" example:"ok"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Healthz 存活检查
+// @Summary 存活检查
+// @Description 进程正常运行即返回 200
+// @Tags system
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Router /healthz [get]
+func (s *Server) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	jsonResponse(w, HealthResponse{Status: "ok"})
+}
+
+// Readyz 就绪检查
+// @Summary 就绪检查
+// @Description 版本信息已加载、缓存可写且 domains.json 最近获取成功时返回 200，否则返回 503 及未通过的检查项
+// @Tags system
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /readyz [get]
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{
+		"version": "ok",
+		"cache":   "ok",
+		"domains": "ok",
+	}
+	ready := true
+	fail := func(name string, err error) {
+		checks[name] = err.Error()
+		ready = false
+	}
+
+	if s.versions.Get() == nil {
+		fail("version", fmt.Errorf("版本信息未加载"))
+	}
+	if err := s.probeCache(r.Context()); err != nil {
+		fail("cache", err)
+	}
+	if err := s.checkDomains(r.Context()); err != nil {
+		fail("domains", err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	resp := HealthResponse{Status: "ok", Checks: checks}
+	if !ready {
+		resp.Status = "unavailable"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	jsonResponse(w, resp)
+}
+
+// probeCache 检查缓存存储可写 (写入并删除一个探测对象)，结果缓存 probeInterval
+//
+// 存储 I/O 不持有 health.mu，S3 较慢时不阻塞 status 等读取状态的请求。
+func (s *Server) probeCache(ctx context.Context) error {
+	h := &s.health
+	h.mu.Lock()
+	if h.probeRunning || time.Since(h.probeAt) < probeInterval {
+		err := h.probeErr
+		h.mu.Unlock()
+		return err
+	}
+	h.probeRunning = true
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	store := s.cache.Storage()
+	err := store.Put(ctx, probeKey, strings.NewReader("ok"), 2)
+	// 写入失败时也尝试删除，不留下探测对象
+	if derr := store.Delete(ctx, probeKey); err == nil {
+		err = derr
+	}
+	if err != nil {
+		err = fmt.Errorf("缓存不可写: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.probeAt, h.probeErr, h.probeRunning = time.Now(), err, false
+	return err
+}
+
+// checkDomains 未配置 domains 仓库时跳过；最近获取成功则通过，否则重新获取一次
+//
+// 获取失败后 domainsRetryInterval 内不再重试，直接返回上次的错误。
+func (s *Server) checkDomains(ctx context.Context) error {
+	if s.config.Get().Domains.Repo == "" {
+		return nil
+	}
+	s.health.domainsCheck.Lock()
+	defer s.health.domainsCheck.Unlock()
+
+	st := s.health.domains()
+	if time.Since(st.LastSuccess) < domainsFreshness {
+		return nil
+	}
+	if st.LastError != "" && time.Since(st.LastAttempt) < domainsRetryInterval {
+		return fmt.Errorf("获取 domains.json 失败: %s", st.LastError)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err := s.fetchDomains(ctx); err != nil {
+		return fmt.Errorf("获取 domains.json 失败: %w", err)
+	}
+	return nil
+}
+
+// StatusResponse 服务状态
+type StatusResponse struct {
+	App        string                 `json:"app" example:"orange-service"`
+	AppVersion string                 `json:"app_version" example:"1.0.0"`
+	Release    *ReleaseStatus         `json:"release"`
+	Refresh    version.RefreshStatus  `json:"refresh"`
+	Sync       cache.SyncStatus       `json:"sync"`
+	Domains    DomainsStatus          `json:"domains"`
+	Cache      CacheStatus            `json:"cache"`
+	Upstreams  []cache.UpstreamStatus `json:"upstreams"`
+	RateLimits []RateLimitStatus      `json:"rate_limits"`
+}
+
+// ReleaseStatus 当前提供的版本
+type ReleaseStatus struct {
+	Tag         string    `json:"tag" example:"v1.2.0"`
+	PublishedAt string    `json:"published_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Assets      int       `json:"assets"`
+}
+
+// CacheStatus 缓存内容
+type CacheStatus struct {
+	Objects int          `json:"objects"`
+	Bytes   int64        `json:"bytes"`
+	Files   []CachedFile `json:"files"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// CachedFile 缓存中的文件
+type CachedFile struct {
+	Key     string    `json:"key" example:"v1.2.0/app-windows-amd64.exe"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Status 服务状态
+// @Summary 服务状态
+// @Description 返回当前版本、最近的刷新/同步结果、domains 获取状态、缓存内容和来源 API 速率限制
+// @Tags system
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} StatusResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/status [get]
+func (s *Server) Status(w http.ResponseWriter, r *http.Request) {
+	if !requireToken(w, r, s.config.Get().Server.StatusToken, "server.status_token") {
+		return
+	}
+
+	resp := StatusResponse{
+		App:        "orange-service",
+		AppVersion: version.AppVersion,
+		Refresh:    s.versions.Status(),
+		Sync:       s.cache.SyncStatus(),
+		Domains:    s.health.domains(),
+		Cache:      s.cacheStatus(r.Context()),
+		Upstreams:  s.cache.Upstreams(),
+		RateLimits: s.metrics.rateLimitStatus(),
+	}
+	if info := s.versions.Get(); info != nil {
+		resp.Release = &ReleaseStatus{
+			Tag:         info.Version,
+			PublishedAt: info.PublishedAt,
+			UpdatedAt:   info.UpdatedAt,
+			Assets:      len(info.Assets),
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	jsonResponse(w, resp)
+}
+
+func (s *Server) cacheStatus(ctx context.Context) CacheStatus {
+	objects, err := s.cache.Storage().List(ctx, "")
+	if err != nil {
+		return CacheStatus{Error: err.Error()}
+	}
+	status := CacheStatus{Files: make([]CachedFile, 0, len(objects))}
+	for _, obj := range objects {
+		status.Objects++
+		status.Bytes += obj.Size
+		status.Files = append(status.Files, CachedFile{Key: obj.Key, Size: obj.Size, ModTime: obj.ModTime})
+	}
+	sort.Slice(status.Files, func(i, j int) bool { return status.Files[i].Key < status.Files[j].Key })
+	return status
+}
+
+// requireToken 校验接口的 Bearer token，未通过时写入错误响应并返回 false
+//
+// token 未配置 (setting 为对应的配置项) 时接口视为关闭，返回 404。
+func requireToken(w http.ResponseWriter, r *http.Request, token, setting string) bool {
+	if token == "" {
+		httpError(w, http.StatusNotFound, "未配置 "+setting)
+		return false
+	}
+	if !bearerTokenMatches(r, token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="orange-service"`)
+		httpError(w, http.StatusUnauthorized, "认证失败")
+		return false
+	}
+	return true
+}
+
+// bearerTokenMatches 校验 Authorization: Bearer <token> (常量时间比较)
+func bearerTokenMatches(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
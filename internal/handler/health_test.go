@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"update-server/internal/config"
+)
+
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, nil)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("期望状态码 200, 实际: %d", w.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	t.Parallel()
+
+	// domains 仓库: 第一次请求成功，之后返回 500
+	var domainsCalls atomic.Int32
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if domainsCalls.Add(1) > 1 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"content": base64.StdEncoding.EncodeToString([]byte(`{"panels":[]}`)),
+		})
+	}))
+	defer gh.Close()
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "v1.2.0"), 0755)
+	os.WriteFile(filepath.Join(root, "v1.2.0", "app.zip"), []byte("offline"), 0644)
+
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+		c.Domains.Repo = "test/domains"
+		c.Domains.BaseURL = gh.URL
+	})
+
+	readyz := func() (int, HealthResponse) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		var resp HealthResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+
+	// 版本信息未加载
+	code, resp := readyz()
+	if code != http.StatusServiceUnavailable || resp.Checks["version"] == "ok" {
+		t.Errorf("版本信息未加载时应返回 503: %d %+v", code, resp)
+	}
+	if resp.Checks["cache"] != "ok" || resp.Checks["domains"] != "ok" {
+		t.Errorf("缓存和 domains 检查应通过: %+v", resp)
+	}
+
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := readyz(); code != http.StatusOK {
+		t.Errorf("期望状态码 200, 实际: %d %+v", code, resp)
+	}
+	// domains.json 仍然新鲜，不重新获取
+	if n := domainsCalls.Load(); n != 1 {
+		t.Errorf("domains.json 应只获取 1 次, 实际: %d", n)
+	}
+	// 探测对象已删除，不留下目录
+	if objects, _ := s.cache.Storage().List(context.Background(), ""); len(objects) != 0 {
+		t.Errorf("探测对象未删除: %+v", objects)
+	}
+	if entries, _ := os.ReadDir(s.config.Get().Cache.Dir); len(entries) != 0 {
+		t.Errorf("缓存目录中不应留下探测文件或目录: %v", entries)
+	}
+
+	// 过期后重新获取失败
+	s.health.mu.Lock()
+	s.health.domainsOK = s.health.domainsOK.Add(-domainsFreshness)
+	s.health.mu.Unlock()
+	if code, resp := readyz(); code != http.StatusServiceUnavailable || resp.Checks["domains"] == "ok" {
+		t.Errorf("domains.json 获取失败时应返回 503: %d %+v", code, resp)
+	}
+	// 重试间隔内返回上次的错误，不再请求 GitHub
+	if code, resp := readyz(); code != http.StatusServiceUnavailable || resp.Checks["domains"] == "ok" {
+		t.Errorf("重试间隔内应返回上次的错误: %d %+v", code, resp)
+	}
+	if n := domainsCalls.Load(); n != 2 {
+		t.Errorf("重试间隔内不应重新获取 domains.json, 实际共获取 %d 次", n)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "v1.2.0"), 0755)
+	os.WriteFile(filepath.Join(root, "v1.2.0", "app.zip"), []byte("offline"), 0644)
+
+	// 未配置 token 时关闭
+	s := newTestServer(t, nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/status", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("未配置 token 时期望 404, 实际: %d", w.Code)
+	}
+
+	s = newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+		c.Server.StatusToken = "secret"
+	})
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.syncCache(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest("GET", "/api/v1/status", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q 期望 401, 实际: %d", auth, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200, 实际: %d %s", w.Code, w.Body.String())
+	}
+
+	var status StatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Release == nil || status.Release.Tag != "v1.2.0" || status.Release.Assets != 1 {
+		t.Errorf("当前版本错误: %+v", status.Release)
+	}
+	if status.Refresh.LastSuccess.IsZero() || status.Refresh.LastError != "" {
+		t.Errorf("刷新状态错误: %+v", status.Refresh)
+	}
+	if status.Sync.Tag != "v1.2.0" || status.Sync.Running || status.Sync.FinishedAt.IsZero() || status.Sync.Error != "" {
+		t.Errorf("同步状态错误: %+v", status.Sync)
+	}
+	if status.Cache.Objects != 1 || status.Cache.Bytes != 7 || status.Cache.Files[0].Key != "v1.2.0/app.zip" {
+		t.Errorf("缓存内容错误: %+v", status.Cache)
+	}
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"update-server/internal/analytics"
+	"update-server/internal/config"
+)
+
+// recordCheckin 把带 install_id 的检查更新记录为活跃安装
+//
+// 所有字段都是可选的匿名信息，没有 install_id 时不记录 (无法去重)。
+func (s *Server) recordCheckin(r *http.Request, clientVersion string) {
+	if !s.config.Get().Telemetry.On() {
+		return
+	}
+	q := r.URL.Query()
+	id := q.Get("install_id")
+	if len(id) < 8 || len(id) > 128 {
+		return
+	}
+	s.installs.Record(analytics.Checkin{
+		Day:       s.today().Format(time.DateOnly),
+		InstallID: id,
+		Version:   telemetryField(clientVersion),
+		Platform:  telemetryField(q.Get("platform")),
+		Arch:      telemetryField(q.Get("arch")),
+		OSVersion: telemetryField(q.Get("os_version")),
+		Brand:     telemetryField(q.Get("brand")),
+		Channel:   telemetryField(q.Get("channel")),
+	})
+}
+
+// telemetryField 只保留可打印 ASCII，最长 64 个字符
+func telemetryField(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(v))
+	if len(v) > 64 {
+		v = v[:64]
+	}
+	return v
+}
+
+// today analytics.timezone 中今天的零点
+func (s *Server) today() time.Time {
+	loc, err := s.config.Get().Analytics.Location()
+	if err != nil {
+		loc = time.Local
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
+
+// ActiveInstallsResponse 活跃安装统计
+type ActiveInstallsResponse struct {
+	Period   string             `json:"period" example:"day"`
+	From     string             `json:"from" example:"2024-01-01"`
+	To       string             `json:"to" example:"2024-01-30"`
+	Timezone string             `json:"timezone" example:"Asia/Shanghai"`
+	Rows     []analytics.Active `json:"rows"`
+}
+
+// ActiveInstalls 活跃安装数
+// @Summary 按版本统计日活/月活安装数
+// @Description 根据带 install_id 的检查更新请求，按天 (DAU) 或按月 (MAU) 统计各版本的活跃安装数
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param period query string false "day (默认) 或 month" Enums(day, month)
+// @Param from query string false "开始日期 (默认按天为 29 天前，按月为 11 个月前的月初)" example(2024-01-01)
+// @Param to query string false "结束日期 (包含，默认今天)" example(2024-01-30)
+// @Param brand query string false "品牌"
+// @Param platform query string false "平台"
+// @Param channel query string false "更新渠道"
+// @Success 200 {object} ActiveInstallsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/installs/active [get]
+func (s *Server) ActiveInstalls(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.adminAuth(w, r, config.ScopeRead); !ok {
+		return
+	}
+	rr, err := s.parseReportRange(r)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	period := q.Get("period")
+	switch period {
+	case "", analytics.PeriodDay:
+		period = analytics.PeriodDay
+	case analytics.PeriodMonth:
+		if q.Get("from") == "" {
+			to, _ := time.Parse(time.DateOnly, rr.to)
+			rr.from = time.Date(to.Year(), to.Month()-11, 1, 0, 0, 0, 0, time.UTC).Format(time.DateOnly)
+		}
+	default:
+		httpError(w, http.StatusBadRequest, "period 只支持 day 或 month")
+		return
+	}
+
+	rows, err := s.installs.Active(analytics.InstallQuery{
+		From:     rr.from,
+		To:       rr.to,
+		Brand:    q.Get("brand"),
+		Platform: q.Get("platform"),
+		Channel:  q.Get("channel"),
+	}, period)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "读取活跃安装记录失败", "err", err)
+		httpError(w, http.StatusInternalServerError, "读取活跃安装记录失败")
+		return
+	}
+	jsonResponse(w, ActiveInstallsResponse{
+		Period:   period,
+		From:     rr.from,
+		To:       rr.to,
+		Timezone: rr.loc.String(),
+		Rows:     rows,
+	})
+}
+
+// AdoptionResponse 版本采用曲线
+type AdoptionResponse struct {
+	Version string                    `json:"version" example:"v1.2.0"`
+	From    string                    `json:"from" example:"2024-01-01"`
+	To      string                    `json:"to" example:"2024-01-30"`
+	Days    []analytics.AdoptionPoint `json:"days"`
+}
+
+// VersionAdoption 版本采用曲线
+// @Summary 版本发布后的采用曲线
+// @Description 从版本第一次出现在活跃安装中的那天 (或 from) 起，每天使用该版本的活跃安装数和占比
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param version query string false "版本号 (默认最新版本)" example(v1.2.0)
+// @Param from query string false "开始日期 (默认该版本第一次出现的日期)" example(2024-01-01)
+// @Param days query int false "天数 (默认 30，最多 366，不超过今天)"
+// @Param brand query string false "品牌"
+// @Param platform query string false "平台"
+// @Param channel query string false "更新渠道"
+// @Success 200 {object} AdoptionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/admin/installs/adoption [get]
+func (s *Server) VersionAdoption(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.adminAuth(w, r, config.ScopeRead); !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	ver := q.Get("version")
+	if ver == "" {
+		info := s.versions.Get()
+		if info == nil {
+			httpError(w, http.StatusServiceUnavailable, "版本信息暂不可用")
+			return
+		}
+		ver = info.Version
+	}
+
+	days := 30
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			httpError(w, http.StatusBadRequest, "days 应为 1-366")
+			return
+		}
+		days = n
+	}
+
+	today := s.today()
+	from := today
+	if v := q.Get("from"); v != "" {
+		t, err := time.ParseInLocation(time.DateOnly, v, today.Location())
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Sprintf("from 格式错误，应为 YYYY-MM-DD: %s", v))
+			return
+		}
+		from = t
+	} else {
+		first, err := s.installs.FirstSeen(ver)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "读取活跃安装记录失败", "err", err)
+			httpError(w, http.StatusInternalServerError, "读取活跃安装记录失败")
+			return
+		}
+		if first != "" {
+			from, _ = time.ParseInLocation(time.DateOnly, first, today.Location())
+		}
+	}
+	to := from.AddDate(0, 0, days-1)
+	if to.After(today) {
+		to = today
+	}
+	if from.After(to) {
+		httpError(w, http.StatusBadRequest, "from 不能晚于今天")
+		return
+	}
+
+	iq := analytics.InstallQuery{
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Brand:    q.Get("brand"),
+		Platform: q.Get("platform"),
+		Channel:  q.Get("channel"),
+	}
+	points, err := s.installs.Adoption(ver, iq)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "读取活跃安装记录失败", "err", err)
+		httpError(w, http.StatusInternalServerError, "读取活跃安装记录失败")
+		return
+	}
+	jsonResponse(w, AdoptionResponse{
+		Version: ver,
+		From:    iq.From,
+		To:      iq.To,
+		Days:    points,
+	})
+}
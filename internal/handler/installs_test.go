@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"update-server/internal/config"
+)
+
+func TestActiveInstalls(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "v1.2.0"), 0755)
+	os.WriteFile(filepath.Join(root, "v1.2.0", "app.zip"), []byte("offline"), 0644)
+
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+		c.Admin.Token = "admin"
+		c.Analytics.Timezone = "UTC"
+	})
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"version=v1.1.0&install_id=aaaaaaaa-1&platform=windows&brand=orange",
+		"version=v1.2.0&install_id=bbbbbbbb-2&platform=android&os_version=14",
+		"version=v1.2.0&install_id=bbbbbbbb-2&platform=android&os_version=14",
+		// 没有 install_id 或格式不合法时不计入
+		"version=v1.0.0",
+		"version=v1.0.0&install_id=short",
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/check-update?"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: 期望 200, 得到 %d", query, w.Code)
+		}
+	}
+	s.installs.Close()
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/api/v1/admin/installs/active", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("错误 token 期望 401, 得到 %d", w.Code)
+	}
+	if w := get("/api/v1/admin/installs/active?period=week", "admin"); w.Code != http.StatusBadRequest {
+		t.Errorf("未知周期期望 400, 得到 %d", w.Code)
+	}
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	w := get("/api/v1/admin/installs/active?period=month", "admin")
+	var active ActiveInstallsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &active); err != nil {
+		t.Fatal(err)
+	}
+	if len(active.Rows) != 1 || active.Rows[0].Period != today[:7] || active.Rows[0].Total != 2 ||
+		active.Rows[0].Versions["v1.1.0"] != 1 || active.Rows[0].Versions["v1.2.0"] != 1 {
+		t.Errorf("月活不匹配: %+v", active)
+	}
+
+	// 默认最新版本，从第一次出现的日期开始
+	w = get("/api/v1/admin/installs/adoption", "admin")
+	var adoption AdoptionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &adoption); err != nil {
+		t.Fatal(err)
+	}
+	if adoption.Version != "v1.2.0" || adoption.From != today || len(adoption.Days) != 1 ||
+		adoption.Days[0].Installs != 1 || adoption.Days[0].Total != 2 || adoption.Days[0].Share != 0.5 {
+		t.Errorf("采用曲线不匹配: %+v", adoption)
+	}
+}
+
+func TestTelemetryField(t *testing.T) {
+	if got := telemetryField(" Windows 11\n23H2 "); got != "Windows 1123H2" {
+		t.Errorf("得到 %q", got)
+	}
+	long := telemetryField(string(make([]byte, 100)) + "abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz")
+	if len(long) != 64 {
+		t.Errorf("期望截断为 64 个字符, 得到 %d", len(long))
+	}
+}
@@ -0,0 +1,318 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"update-server/internal/metrics"
+	"update-server/internal/storage"
+	"update-server/internal/version"
+)
+
+// 下载来源 (orange_downloads_total 的 source 标签)
+const (
+	sourceCache    = "cache"    // 缓存命中
+	sourceUpstream = "upstream" // 缓存未命中，先从来源下载
+	sourceRedirect = "redirect" // 302 到对象存储预签名 URL
+)
+
+// serverMetrics 服务指标，由 /metrics 以 Prometheus 文本格式输出
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests         *metrics.Counter
+	requestDuration  *metrics.Histogram
+	checkUpdates     *metrics.Counter
+	downloads        *metrics.Counter
+	downloadBytes    *metrics.Counter
+	upstreamRequests *metrics.Counter
+	rateLimit        *metrics.Gauge
+	webhooks         *metrics.Counter
+	syncDuration     *metrics.Histogram
+
+	rateMu     sync.Mutex
+	rateLimits map[string]*RateLimitStatus // host -> 最近一次 API 响应的状态
+
+	cacheMu      sync.Mutex
+	cacheChecked time.Time
+	cacheObjects int
+	cacheBytes   int64
+
+	// cacheTags 最近一次统计时缓存中的版本，作为 version 标签的已知取值
+	cacheTags       atomic.Pointer[map[string]bool]
+	cacheRefreshing atomic.Bool
+}
+
+func newServerMetrics(s *Server) *serverMetrics {
+	r := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: r,
+		requests: r.Counter("orange_http_requests_total",
+			"HTTP 请求数", "route", "method", "code"),
+		requestDuration: r.Histogram("orange_http_request_duration_seconds",
+			"HTTP 请求耗时 (下载为完整传输时间)", metrics.DefBuckets, "route"),
+		checkUpdates: r.Counter("orange_check_update_total",
+			"检查更新请求数 (按客户端版本、平台、渠道)", "version", "platform", "channel"),
+		downloads: r.Counter("orange_downloads_total",
+			"下载次数 (source: cache 缓存命中 / upstream 先从来源下载 / redirect 跳转对象存储)", "asset", "source"),
+		downloadBytes: r.Counter("orange_download_bytes_total",
+			"下载传输的字节数", "asset", "source"),
+		upstreamRequests: r.Counter("orange_upstream_api_requests_total",
+			"GitHub/GitLab/Gitea API 请求数 (code 为 HTTP 状态码或 error)", "host", "code"),
+		rateLimit: r.Gauge("orange_upstream_rate_limit_remaining",
+			"来源 API 剩余的速率限制次数", "host"),
+		webhooks: r.Counter("orange_webhook_events_total",
+			"webhook 事件数 (按处理结果)", "result"),
+		syncDuration: r.Histogram("orange_cache_sync_duration_seconds",
+			"缓存同步耗时", []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}, "trigger", "result"),
+	}
+	r.GaugeFunc("orange_cache_objects", "缓存中的文件数", func() float64 {
+		objects, _ := m.cacheSize(s.cache.Storage())
+		return float64(objects)
+	})
+	r.GaugeFunc("orange_cache_size_bytes", "缓存占用的字节数", func() float64 {
+		_, size := m.cacheSize(s.cache.Storage())
+		return float64(size)
+	})
+	return m
+}
+
+// cacheSize 统计缓存大小，结果缓存 1 分钟 (S3 列举对象较慢)
+func (m *serverMetrics) cacheSize(store storage.Storage) (int, int64) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
+	if time.Since(m.cacheChecked) < time.Minute {
+		return m.cacheObjects, m.cacheBytes
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return m.cacheObjects, m.cacheBytes
+	}
+
+	m.cacheObjects, m.cacheBytes = len(objects), 0
+	tags := make(map[string]bool)
+	for _, obj := range objects {
+		m.cacheBytes += obj.Size
+		if tag, _, ok := strings.Cut(obj.Key, "/"); ok {
+			tags[tag] = true
+		}
+	}
+	m.cacheTags.Store(&tags)
+	m.cacheChecked = time.Now()
+	return m.cacheObjects, m.cacheBytes
+}
+
+// RateLimitStatus 来源 API 的速率限制和最近一次请求结果
+type RateLimitStatus struct {
+	Host       string    `json:"host"`
+	Remaining  *int64    `json:"remaining,omitempty"`
+	Limit      *int64    `json:"limit,omitempty"`
+	Reset      time.Time `json:"reset,omitempty"`
+	LastStatus int       `json:"last_status,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// observeUpstream 记录来源 API 请求结果和响应头中的剩余速率限制
+func (m *serverMetrics) observeUpstream(req *http.Request, resp *http.Response, err error) {
+	host := req.URL.Host
+
+	m.rateMu.Lock()
+	defer m.rateMu.Unlock()
+	if m.rateLimits == nil {
+		m.rateLimits = make(map[string]*RateLimitStatus)
+	}
+	st := m.rateLimits[host]
+	if st == nil {
+		st = &RateLimitStatus{Host: host}
+		m.rateLimits[host] = st
+	}
+	st.UpdatedAt = time.Now()
+
+	if err != nil {
+		m.upstreamRequests.Inc(host, "error")
+		st.LastError = err.Error()
+		return
+	}
+	m.upstreamRequests.Inc(host, strconv.Itoa(resp.StatusCode))
+	st.LastStatus, st.LastError = resp.StatusCode, ""
+
+	// GitHub/Gitea 为 X-RateLimit-*，GitLab 为 RateLimit-*
+	header := func(name string) (int64, bool) {
+		for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+			if v, err := strconv.ParseInt(resp.Header.Get(prefix+name), 10, 64); err == nil {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+	if v, ok := header("Remaining"); ok {
+		st.Remaining = &v
+		m.rateLimit.Set(float64(v), host)
+	}
+	if v, ok := header("Limit"); ok {
+		st.Limit = &v
+	}
+	if v, ok := header("Reset"); ok {
+		st.Reset = time.Unix(v, 0)
+	}
+}
+
+// rateLimitStatus 各来源 API 主机的状态，按主机名排序
+func (m *serverMetrics) rateLimitStatus() []RateLimitStatus {
+	m.rateMu.Lock()
+	defer m.rateMu.Unlock()
+	out := make([]RateLimitStatus, 0, len(m.rateLimits))
+	for _, st := range m.rateLimits {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+// observeSync 记录一次缓存同步的耗时和结果
+func (m *serverMetrics) observeSync(trigger string, start time.Time, err error) {
+	result := "ok"
+	switch {
+	case errors.Is(err, context.Canceled):
+		result = "canceled"
+	case err != nil:
+		result = "error"
+	}
+	m.syncDuration.Observe(time.Since(start).Seconds(), trigger, result)
+}
+
+// 检查更新指标中 platform、channel 标签的已知取值，其余记为 other
+var (
+	knownPlatforms = []string{"android", "windows", "macos", "linux", "ios"}
+	knownChannels  = []string{"stable", "beta", "alpha", "nightly", "dev"}
+)
+
+// enumLabel 把客户端上报的值映射到已知取值，避免标签基数无限增长；空值记为 unknown
+func enumLabel(s string, known []string) string {
+	if s == "" {
+		return "unknown"
+	}
+	for _, k := range known {
+		if strings.EqualFold(s, k) {
+			return k
+		}
+	}
+	return "other"
+}
+
+// versionLabel 把客户端版本号映射到已知的 Release tag，未知版本记为 other
+//
+// 已知版本为当前版本、固定或撤回的版本和缓存中的版本；缓存统计在后台刷新，不阻塞请求。
+func (s *Server) versionLabel(clientVersion string, info *version.Info) string {
+	if clientVersion == "" {
+		return "unknown"
+	}
+	tags := []string{info.Version, s.pins.Pinned()}
+	for _, y := range s.pins.Yanks() {
+		tags = append(tags, y.Version)
+	}
+	if cached := s.metrics.cacheTags.Load(); cached != nil {
+		for tag := range *cached {
+			tags = append(tags, tag)
+		}
+	}
+	s.refreshCacheStats()
+
+	want := strings.TrimPrefix(clientVersion, "v")
+	for _, tag := range tags {
+		if tag != "" && strings.TrimPrefix(tag, "v") == want {
+			return tag
+		}
+	}
+	return "other"
+}
+
+// refreshCacheStats 缓存统计过期时在后台重新统计
+func (s *Server) refreshCacheStats() {
+	m := s.metrics
+	m.cacheMu.Lock()
+	fresh := time.Since(m.cacheChecked) < time.Minute
+	m.cacheMu.Unlock()
+	if fresh || !m.cacheRefreshing.CompareAndSwap(false, true) {
+		return
+	}
+	s.tasks.Go("cache-stats", func(context.Context) {
+		defer m.cacheRefreshing.Store(false)
+		m.cacheSize(s.cache.Storage())
+	})
+}
+
+// ResponseRecorder 记录响应状态码和写出的字节数
+//
+// 路由指标、下载统计和访问日志 (cmd/server) 共用；放在压缩中间件外层时记录的是压缩后的字节数。
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// NewResponseRecorder 包装 w，记录写出的状态码和字节数
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w}
+}
+
+func (r *ResponseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *ResponseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom 保留底层连接的 sendfile 优化 (http.ServeContent 发送文件时使用)
+func (r *ResponseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := io.Copy(r.ResponseWriter, src)
+	r.bytes += n
+	return n, err
+}
+
+func (r *ResponseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// StatusCode 响应状态码，未写出任何内容时为 200
+func (r *ResponseRecorder) StatusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// BytesWritten 已写出的响应体字节数
+func (r *ResponseRecorder) BytesWritten() int64 {
+	return r.bytes
+}
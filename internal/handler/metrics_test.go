@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"update-server/internal/config"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "v1.2.0"), 0755)
+	os.WriteFile(filepath.Join(root, "v1.2.0", "app.zip"), []byte("offline"), 0644)
+
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+	})
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	// 第一次从来源下载，第二次命中缓存
+	get("/api/v1/download/v1.2.0/app.zip")
+	get("/api/v1/download/v1.2.0/app.zip")
+	get("/api/v1/check-update?version=1.2.0&platform=Windows")
+	// 未知的版本、平台和渠道合并为 other，标签基数有上限
+	get("/api/v1/check-update?version=v0.0.1-random&platform=plan9&channel=" + strings.Repeat("渠", 20))
+
+	// 来源 API 响应中的速率限制
+	req := httptest.NewRequest("GET", "https://api.github.com/repos/test/repo/releases", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Ratelimit-Remaining": {"4999"}}}
+	s.metrics.observeUpstream(req, resp, nil)
+
+	w := get("/metrics")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type 错误: %s", ct)
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`orange_http_requests_total{route="/api/v1/download/",method="GET",code="200"} 2`,
+		`orange_http_request_duration_seconds_count{route="/api/v1/download/"} 2`,
+		`orange_check_update_total{version="v1.2.0",platform="windows",channel="unknown"} 1`,
+		`orange_check_update_total{version="other",platform="other",channel="other"} 1`,
+		`orange_downloads_total{asset="app.zip",source="upstream"} 1`,
+		`orange_downloads_total{asset="app.zip",source="cache"} 1`,
+		`orange_download_bytes_total{asset="app.zip",source="cache"} 7`,
+		`orange_upstream_api_requests_total{host="api.github.com",code="200"} 1`,
+		`orange_upstream_rate_limit_remaining{host="api.github.com"} 4999`,
+		`orange_cache_objects 1`,
+		`orange_cache_size_bytes 7`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("缺少指标: %s", line)
+		}
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+}
@@ -1,14 +1,19 @@
 package handler
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
-	"time"
 
-	"update-server/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+
+	"update-server/internal/github"
+	"update-server/internal/tracing"
 )
 
 // Domains 获取域名列表
@@ -20,40 +25,90 @@ import (
 // @Failure 500 {object} ErrorResponse
 // @Failure 502 {object} ErrorResponse
 // @Router /api/v1/redirect/domains [get]
-func Domains(w http.ResponseWriter, r *http.Request) {
-	cfg := config.Get()
+func (s *Server) Domains(w http.ResponseWriter, r *http.Request) {
+	content, err := s.fetchDomains(r.Context())
+	if err != nil {
+		var de *domainsError
+		if errors.As(err, &de) {
+			httpError(w, de.status, de.message)
+		} else {
+			httpError(w, http.StatusBadGateway, "获取域名配置失败")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+// domainsError 获取 domains.json 失败，status/message 为返回给客户端的错误
+type domainsError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *domainsError) Error() string {
+	if e.err != nil {
+		return e.message + ": " + e.err.Error()
+	}
+	return e.message
+}
+
+func (e *domainsError) Unwrap() error {
+	return e.err
+}
+
+// fetchDomains 从 GitHub 获取 domains.json 的内容，并记录结果 (用于 readyz 和 status)
+func (s *Server) fetchDomains(ctx context.Context) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "domains.fetch", attribute.String("domains.repo", s.config.Get().Domains.Repo))
+	content, err := s.doFetchDomains(ctx)
+	tracing.End(span, err)
+	if ctx.Err() == nil {
+		s.health.recordDomains(err)
+	}
+	return content, err
+}
+
+func (s *Server) doFetchDomains(ctx context.Context) ([]byte, error) {
+	cfg := s.config.Get()
 
 	if cfg.Domains.Repo == "" {
-		httpError(w, http.StatusInternalServerError, "domains repo 未配置")
-		return
+		return nil, &domainsError{status: http.StatusInternalServerError, message: "domains repo 未配置"}
 	}
 
 	// 构造 GitHub API URL
-	apiURL := "https://api.github.com/repos/" + cfg.Domains.Repo + "/contents/domains.json"
-	req, err := http.NewRequest("GET", apiURL, nil)
+	apiURL := github.APIURL(cfg.Domains.BaseURL) + "/repos/" + cfg.Domains.Repo + "/contents/domains.json"
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
-		httpError(w, http.StatusInternalServerError, "创建请求失败")
-		return
+		return nil, &domainsError{status: http.StatusInternalServerError, message: "创建请求失败", err: err}
 	}
 
-	if cfg.Domains.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.Domains.Token)
+	clients, err := s.outbound.Get(cfg.HTTP)
+	if err != nil {
+		return nil, &domainsError{status: http.StatusInternalServerError, message: "创建 HTTP 客户端失败", err: err}
+	}
+
+	token, err := s.domainsAuth.Token(ctx, cfg.Domains, clients.API)
+	if err != nil {
+		slog.ErrorContext(ctx, "获取 domains 仓库令牌失败", "err", err)
+		return nil, &domainsError{status: http.StatusBadGateway, message: "GitHub 认证失败", err: err}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := clients.API.Do(req)
 	if err != nil {
-		httpError(w, http.StatusBadGateway, "无法连接到 GitHub")
-		return
+		return nil, &domainsError{status: http.StatusBadGateway, message: "无法连接到 GitHub", err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		httpError(w, resp.StatusCode, "GitHub 返回错误: "+string(body))
-		return
+		return nil, &domainsError{status: resp.StatusCode, message: "GitHub 返回错误: " + string(body)}
 	}
 
 	// GitHub API 返回的是 base64 编码的内容
@@ -63,58 +118,21 @@ func Domains(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		httpError(w, http.StatusInternalServerError, "解析响应失败")
-		return
+		return nil, &domainsError{status: http.StatusInternalServerError, message: "解析响应失败", err: err}
 	}
 
 	// 解码 base64 内容 (GitHub 返回的 base64 包含换行符，需要先去掉)
 	cleanContent := strings.ReplaceAll(apiResp.Content, "\n", "")
 	content, err := base64.StdEncoding.DecodeString(cleanContent)
 	if err != nil {
-		httpError(w, http.StatusInternalServerError, "解码内容失败: "+err.Error())
-		return
+		return nil, &domainsError{status: http.StatusInternalServerError, message: "解码内容失败: " + err.Error()}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(content)
+	return content, nil
 }
 
 // fetchDomainsJSON 获取并解析 domains.json
-func fetchDomainsJSON() (map[string]interface{}, error) {
-	cfg := config.Get()
-
-	apiURL := "https://api.github.com/repos/" + cfg.Domains.Repo + "/contents/domains.json"
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if cfg.Domains.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.Domains.Token)
-	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, err
-	}
-
-	var apiResp struct {
-		Content string `json:"content"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, err
-	}
-
-	cleanContent := strings.ReplaceAll(apiResp.Content, "\n", "")
-	content, err := base64.StdEncoding.DecodeString(cleanContent)
+func (s *Server) fetchDomainsJSON(ctx context.Context) (map[string]interface{}, error) {
+	content, err := s.fetchDomains(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +154,7 @@ func fetchDomainsJSON() (map[string]interface{}, error) {
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/redirect/{brand} [get]
-func RedirectBrand(w http.ResponseWriter, r *http.Request) {
+func (s *Server) RedirectBrand(w http.ResponseWriter, r *http.Request) {
 	// 从 URL 路径提取品牌名
 	path := strings.TrimPrefix(r.URL.Path, "/api/v1/redirect/")
 	brand := strings.Split(path, "/")[0]
@@ -146,7 +164,7 @@ func RedirectBrand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := fetchDomainsJSON()
+	data, err := s.fetchDomainsJSON(r.Context())
 	if err != nil {
 		httpError(w, http.StatusBadGateway, "获取域名配置失败")
 		return
@@ -1,32 +1,47 @@
 package handler
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 
 	"update-server/internal/config"
 )
 
 func TestDomains(t *testing.T) {
-	// 设置配置文件路径
-	os.Setenv("CONFIG_PATH", "../../config.yaml")
-	config.Load()
+	t.Parallel()
+
+	// 模拟 GitHub contents API，返回 base64 编码 (带换行) 的 domains.json
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/test/domains/contents/domains.json" {
+			http.NotFound(w, r)
+			return
+		}
+		content := base64.StdEncoding.EncodeToString([]byte(`{"panelType":"xboard","panels":[]}`))
+		json.NewEncoder(w).Encode(map[string]string{
+			"content":  content[:8] + "\n" + content[8:],
+			"encoding": "base64",
+		})
+	}))
+	defer gh.Close()
+
+	s := newTestServer(t, func(c *config.Config) {
+		c.Domains.Repo = "test/domains"
+		c.Domains.BaseURL = gh.URL
+	})
 
 	req := httptest.NewRequest("GET", "/api/v1/redirect/domains", nil)
 	w := httptest.NewRecorder()
 
-	Domains(w, req)
+	s.Domains(w, req)
 
 	resp := w.Result()
 
 	// 检查状态码
 	if resp.StatusCode != http.StatusOK {
-		t.Errorf("期望状态码 200, 实际: %d", resp.StatusCode)
-		t.Logf("响应内容: %s", w.Body.String())
-		return
+		t.Fatalf("期望状态码 200, 实际: %d %s", resp.StatusCode, w.Body.String())
 	}
 
 	// 检查 Content-Type
@@ -38,8 +53,7 @@ func TestDomains(t *testing.T) {
 	// 检查返回的 JSON 是否有效
 	var result map[string]interface{}
 	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
-		t.Errorf("返回的不是有效 JSON: %v", err)
-		return
+		t.Fatalf("返回的不是有效 JSON: %v", err)
 	}
 
 	// 检查必要字段
@@ -49,21 +63,19 @@ func TestDomains(t *testing.T) {
 	if _, ok := result["panels"]; !ok {
 		t.Error("缺少 panels 字段")
 	}
-
-	t.Logf("成功获取 domains.json，包含 %d 个顶级字段", len(result))
 }
 
 func TestDomainsNoConfig(t *testing.T) {
+	t.Parallel()
 	// 测试未配置 repo 的情况
-	cfg := config.Get()
-	originalRepo := cfg.Domains.Repo
-	cfg.Domains.Repo = ""
-	defer func() { cfg.Domains.Repo = originalRepo }()
+	s := newTestServer(t, func(c *config.Config) {
+		c.Domains.Repo = ""
+	})
 
 	req := httptest.NewRequest("GET", "/api/v1/redirect/domains", nil)
 	w := httptest.NewRecorder()
 
-	Domains(w, req)
+	s.Domains(w, req)
 
 	if w.Result().StatusCode != http.StatusInternalServerError {
 		t.Errorf("期望状态码 500, 实际: %d", w.Result().StatusCode)
@@ -0,0 +1,373 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"go.opentelemetry.io/otel/trace"
+
+	"update-server/internal/admin"
+	"update-server/internal/analytics"
+	"update-server/internal/background"
+	"update-server/internal/cache"
+	"update-server/internal/config"
+	"update-server/internal/database"
+	"update-server/internal/github"
+	"update-server/internal/httpclient"
+	"update-server/internal/logging"
+	"update-server/internal/release"
+	"update-server/internal/storage"
+	"update-server/internal/tracing"
+	"update-server/internal/version"
+)
+
+// Server 一个完整的服务实例
+//
+// 持有配置、Release 来源、缓存和版本信息，并在自己的 mux 上注册路由。
+// 同一进程内可以创建多个互不影响的实例 (嵌入或测试)。
+type Server struct {
+	config   *config.Store
+	releases release.Source
+	cache    *cache.Cache
+	versions *version.Store
+	tasks    *background.Group
+	mux      *http.ServeMux
+	metrics  *serverMetrics
+	health   healthState
+
+	db        *bolt.DB            // 本地数据库 (下载统计等)，没有启用使用数据库的功能时为 nil
+	downloads *analytics.Store    // 下载记录
+	installs  *analytics.Installs // 活跃安装
+
+	pins         *admin.Versions                 // 运维固定或撤回的版本
+	adminSyncing atomic.Bool                     // 管理接口触发的同步进行中 (见 AdminSync)
+	labelMu      sync.Mutex                      // 保护 labelRead
+	labelRead    map[string]time.Time            // 按需读取过 release 撤回标记的版本 (见 yanked)
+	auditLog     *admin.Audit                    // 管理操作审计日志
+	webhookLog   deliveryLog                     // 最近的 webhook 请求
+	geoip        atomic.Pointer[analytics.GeoIP] // 按 IP 判断国家，未配置时为 nil
+
+	outbound    httpclient.Pool    // domains 仓库等出站请求的 HTTP 客户端
+	domainsAuth github.Credentials // domains 仓库的访问令牌 (PAT 或 GitHub App)
+
+	// webhook 防重复处理
+	webhookMu         sync.Mutex
+	lastProcessedKey  string
+	lastProcessedTime time.Time
+	webhookCancel     context.CancelFunc // 进行中的 webhook 同步，新的 release 到达时取消旧的
+
+	// 本地目录来源的监听，配置变更时重启
+	releaseWatchMu     sync.Mutex
+	releaseWatchCancel context.CancelFunc
+
+	// 重载前检查时创建的新存储后端，由 onConfigReload 启用 (两者在 config.Store.Reload 中串行调用)
+	pendingStore storage.Storage
+}
+
+// New 创建服务实例并注册路由
+func New(cfg *config.Store) (*Server, error) {
+	s := &Server{
+		config:    cfg,
+		tasks:     background.New(),
+		mux:       http.NewServeMux(),
+		labelRead: make(map[string]time.Time),
+	}
+	s.metrics = newServerMetrics(s)
+	s.outbound.Observer = s.metrics.observeUpstream
+
+	if needsDatabase(cfg.Get()) {
+		db, err := database.Open(cfg.Get().Database.Path)
+		if err != nil {
+			return nil, err
+		}
+		s.db = db
+	}
+	if err := s.openStores(); err != nil {
+		s.closeDB()
+		return nil, err
+	}
+	s.loadGeoIP(cfg.Get().Analytics.GeoIPDB)
+
+	// 固定或撤回版本后，版本信息和缓存同步都使用按策略选出的版本
+	s.releases = release.WithPolicy(release.New(cfg, s.metrics.observeUpstream), s.pins)
+	c, err := cache.New(cfg, s.releases)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.cache = c
+	s.versions = version.NewStore(cfg, s.releases)
+
+	s.routes()
+	cfg.OnCheck(s.checkReload)
+	cfg.OnReload(s.onConfigReload)
+
+	return s, nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("/", s.Root)
+	s.mux.HandleFunc("/api/v1/check-update", s.CheckUpdate)
+	s.mux.HandleFunc("/api/v1/version", s.Version)
+	s.mux.HandleFunc("/api/v1/resources", s.Resources)
+	s.mux.HandleFunc("/api/v1/resources/", s.Resources) // 兼容 /api/v1/resources/{brand}/{inviteCode}
+	s.mux.HandleFunc("/api/v1/download/", s.Download)
+	s.mux.HandleFunc("/api/v1/webhook", s.Webhook)
+	s.mux.HandleFunc("/api/v1/redirect/domains", s.Domains)
+	s.mux.HandleFunc("/api/v1/redirect/", s.RedirectBrand)
+	s.mux.Handle("/metrics", s.metrics.registry)
+	s.mux.HandleFunc("/healthz", s.Healthz)
+	s.mux.HandleFunc("/readyz", s.Readyz)
+	s.mux.HandleFunc("/api/v1/status", s.Status)
+	s.mux.HandleFunc("/api/v1/analytics/downloads", s.DownloadReport)
+	s.mux.HandleFunc("/api/v1/analytics/downloads/events", s.DownloadEvents)
+	s.mux.HandleFunc("/api/v1/admin/installs/active", s.ActiveInstalls)
+	s.mux.HandleFunc("/api/v1/admin/installs/adoption", s.VersionAdoption)
+	s.mux.HandleFunc("/api/v1/admin/refresh", s.AdminRefresh)
+	s.mux.HandleFunc("/api/v1/admin/sync", s.AdminSync)
+	s.mux.HandleFunc("/api/v1/admin/cache", s.AdminCache)
+	s.mux.HandleFunc("/api/v1/admin/cache/verify", s.AdminVerifyCache)
+	s.mux.HandleFunc("/api/v1/admin/versions", s.AdminVersions)
+	s.mux.HandleFunc("/api/v1/admin/versions/pin", s.AdminPin)
+	s.mux.HandleFunc("/api/v1/admin/versions/yank", s.AdminYank)
+	s.mux.HandleFunc("/api/v1/admin/webhooks", s.AdminWebhooks)
+	s.mux.HandleFunc("/api/v1/admin/audit", s.AdminAudit)
+}
+
+// Mux 返回路由，用于额外注册路由 (如 Swagger)
+func (s *Server) Mux() *http.ServeMux {
+	return s.mux
+}
+
+// ServeHTTP 分发请求，按路由记录请求数和耗时，并为请求创建 server span
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	_, route := s.mux.Handler(r)
+	if route == "" {
+		route = "unmatched"
+	}
+
+	if req := logging.FromContext(r.Context()); req != nil {
+		req.Route = route
+	}
+
+	ctx, span := tracing.StartServer(r, route)
+	r = r.WithContext(ctx)
+
+	rec := &ResponseRecorder{ResponseWriter: w}
+	s.mux.ServeHTTP(rec, r)
+
+	tracing.EndServer(span, rec.StatusCode(), rec.bytes)
+	s.metrics.requests.Inc(route, r.Method, strconv.Itoa(rec.StatusCode()))
+	s.metrics.requestDuration.Observe(time.Since(start).Seconds(), route)
+}
+
+// syncCache 同步缓存并记录耗时，trigger 为触发原因
+//
+// 不是由请求触发 (没有请求 ID) 时生成一个 ID，便于关联同一次同步的日志。
+func (s *Server) syncCache(ctx context.Context, trigger string) error {
+	if logging.RequestID(ctx) == "" {
+		ctx = logging.WithRequestID(ctx, "")
+	}
+	slog.InfoContext(ctx, "开始同步缓存", "trigger", trigger)
+	start := time.Now()
+	err := s.cache.Sync(ctx)
+	s.metrics.observeSync(trigger, start, err)
+	return err
+}
+
+// taskContext 由请求触发的后台任务的 context：随服务关闭取消，
+// 日志沿用本次请求的 ID，span 挂在本次请求的 trace 下
+func (s *Server) taskContext(r *http.Request) context.Context {
+	ctx := logging.WithRequestID(s.tasks.Context(), logging.RequestID(r.Context()))
+	return trace.ContextWithSpanContext(ctx, trace.SpanContextFromContext(r.Context()))
+}
+
+// Start 获取版本信息并启动后台任务 (启动同步、定时刷新、配置监听)
+func (s *Server) Start() {
+	cfg := s.config.Get()
+	ctx := s.tasks.Context()
+
+	if local, ok := s.cache.Storage().(*storage.Local); ok {
+		local.RemoveTemp()
+	}
+
+	// 启动时获取版本信息
+	if err := s.versions.Refresh(ctx); err != nil {
+		slog.WarnContext(ctx, "初始化版本信息失败", "err", err)
+	}
+
+	// 启动时同步缓存
+	if cfg.SyncOnStartup() {
+		s.tasks.Go("startup-sync", func(ctx context.Context) {
+			ctx = logging.WithRequestID(ctx, "")
+			if err := s.syncCache(ctx, "startup"); err != nil {
+				slog.WarnContext(ctx, "同步缓存失败", "err", err)
+			}
+		})
+	}
+
+	// 定时刷新版本信息
+	s.tasks.Go("auto-refresh", s.versions.StartAutoRefresh)
+
+	// 本地目录来源：出现新版本目录时刷新
+	s.watchReleases()
+
+	// 清理过期的下载和活跃安装记录
+	if s.db != nil {
+		s.tasks.Go("analytics-prune", s.pruneAnalytics)
+	}
+
+	// 配置热重载
+	if err := s.config.Watch(ctx); err != nil {
+		slog.WarnContext(ctx, "监听配置文件失败", "err", err)
+	}
+}
+
+// Shutdown 取消后台任务并等待退出
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.tasks.Shutdown(ctx)
+}
+
+// needsDatabase 下载统计、活跃安装和管理接口使用本地数据库；都没有启用时不创建数据库文件，
+// 已有的数据库文件仍然打开 (之前固定或撤回的版本继续生效)
+func needsDatabase(cfg *config.Config) bool {
+	if cfg.Analytics.On() || cfg.Telemetry.On() || cfg.Admin.Enabled() {
+		return true
+	}
+	_, err := os.Stat(cfg.Database.Path)
+	return err == nil
+}
+
+// openStores 在本地数据库中创建统计、版本策略和审计日志的存储，没有打开数据库时只创建版本策略
+func (s *Server) openStores() error {
+	var err error
+	if s.pins, err = admin.NewVersions(s.db); err != nil {
+		return err
+	}
+	if s.db == nil {
+		return nil
+	}
+	if s.auditLog, err = admin.NewAudit(s.db); err != nil {
+		return err
+	}
+	if s.downloads, err = analytics.New(s.db); err != nil {
+		return err
+	}
+	if s.installs, err = analytics.NewInstalls(s.db); err != nil {
+		s.downloads.Close()
+		return err
+	}
+	return nil
+}
+
+// Close 写入剩余的统计记录并关闭数据库，在 HTTP 服务和后台任务停止后调用
+func (s *Server) Close() error {
+	if s.downloads != nil {
+		s.downloads.Close()
+		s.installs.Close()
+	}
+	return s.closeDB()
+}
+
+func (s *Server) closeDB() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// onConfigReload 配置重载后刷新依赖配置的状态
+func (s *Server) onConfigReload(old, cfg *config.Config) {
+	if s.pendingStore != nil {
+		s.cache.SetStorage(s.pendingStore)
+		s.pendingStore = nil
+	}
+
+	if old.Analytics.GeoIPDB != cfg.Analytics.GeoIPDB {
+		s.loadGeoIP(cfg.Analytics.GeoIPDB)
+	}
+
+	if old.Release.Provider != cfg.Release.Provider || old.Release.Dir != cfg.Release.Dir {
+		s.watchReleases()
+	}
+
+	if old.Release != cfg.Release || old.Server.BaseURL != cfg.Server.BaseURL {
+		s.tasks.Go("config-reload", func(ctx context.Context) {
+			ctx = logging.WithRequestID(ctx, "")
+			if err := s.versions.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "刷新版本信息失败", "err", err)
+				return
+			}
+			if old.Release.Repo != cfg.Release.Repo || old.Release.Dir != cfg.Release.Dir {
+				if err := s.syncCache(ctx, "config-reload"); err != nil {
+					slog.ErrorContext(ctx, "同步缓存失败", "err", err)
+				}
+			}
+		})
+	}
+}
+
+// checkReload 存储配置变更时先创建新的存储后端，失败则放弃重载，避免配置已替换而缓存仍使用旧存储
+func (s *Server) checkReload(old, cfg *config.Config) error {
+	s.pendingStore = nil
+	if s.db == nil && needsDatabase(cfg) {
+		return errors.New("启用下载统计、活跃安装或管理接口需要打开本地数据库，请重启服务")
+	}
+	if old.Storage == cfg.Storage && old.Cache.Dir == cfg.Cache.Dir {
+		return nil
+	}
+	store, err := storage.New(cfg)
+	if err != nil {
+		return fmt.Errorf("初始化缓存存储失败: %w", err)
+	}
+	s.pendingStore = store
+	return nil
+}
+
+// watchReleases 按当前配置 (重新) 启动本地 release 目录监听
+//
+// 目录中出现新版本或文件变化时刷新版本信息并同步缓存，离线部署无需 webhook。
+func (s *Server) watchReleases() {
+	s.releaseWatchMu.Lock()
+	defer s.releaseWatchMu.Unlock()
+
+	if s.releaseWatchCancel != nil {
+		s.releaseWatchCancel()
+		s.releaseWatchCancel = nil
+	}
+
+	cfg := s.config.Get()
+	if cfg.Release.Provider != config.ProviderLocal {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.tasks.Context())
+	onChange := func() {
+		s.tasks.Go("local-release", func(context.Context) {
+			ctx := logging.WithRequestID(ctx, "")
+			if err := s.versions.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "刷新版本信息失败", "err", err)
+				return
+			}
+			if err := s.syncCache(ctx, "local-release"); err != nil {
+				slog.ErrorContext(ctx, "同步缓存失败", "err", err)
+			}
+		})
+	}
+	if err := release.NewLocal(cfg.Release.Dir).Watch(ctx, onChange); err != nil {
+		slog.Warn("监听本地 release 目录失败", "err", err)
+		cancel()
+		return
+	}
+	s.releaseWatchCancel = cancel
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"update-server/internal/config"
+	"update-server/internal/tracing"
+)
+
+// 不并行：替换了全局 TracerProvider
+func TestTracing_Download(t *testing.T) {
+	oldTP, oldProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(oldTP)
+		otel.SetTextMapPropagator(oldProp)
+	}()
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Install(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "v1.2.0"), 0755)
+	os.WriteFile(filepath.Join(root, "v1.2.0", "app.zip"), []byte("offline"), 0644)
+
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderLocal
+		c.Release.Dir = root
+	})
+	if err := s.versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	exporter.Reset()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/download/v1.2.0/app.zip", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200, 实际: %d", w.Code)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	server, ok := spans["GET /api/v1/download/"]
+	if !ok {
+		t.Fatalf("缺少 server span: %v", spans)
+	}
+	// 按需下载和发送都属于同一个请求的 trace
+	parents := map[string]string{
+		"cache.Fetch":       "GET /api/v1/download/",
+		"cache.download":    "cache.Fetch",
+		"cache.put":         "cache.download",
+		"release.OpenAsset": "cache.put",
+		"download.send":     "GET /api/v1/download/",
+	}
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("缺少 span: %s", name)
+			continue
+		}
+		if span.SpanContext.TraceID() != server.SpanContext.TraceID() {
+			t.Errorf("%s 不在请求的 trace 中", name)
+		}
+		if span.Parent.SpanID() != spans[parent].SpanContext.SpanID() {
+			t.Errorf("%s 的父 span 应为 %s", name, parent)
+		}
+	}
+}
@@ -1,29 +1,24 @@
 package handler
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
-	"update-server/internal/cache"
 	"update-server/internal/config"
-	"update-server/internal/version"
-)
-
-// 防重复处理
-var (
-	lastProcessedTag  string
-	lastProcessedTime time.Time
-	webhookMutex      sync.Mutex
+	"update-server/internal/logging"
 )
 
+// webhookPayload GitHub / Gitea release 事件
 type webhookPayload struct {
 	Action  string `json:"action"`
 	Release struct {
@@ -31,6 +26,12 @@ type webhookPayload struct {
 	} `json:"release"`
 }
 
+// gitlabWebhookPayload GitLab Release Hook 事件
+type gitlabWebhookPayload struct {
+	Action string `json:"action"`
+	Tag    string `json:"tag"`
+}
+
 // WebhookResponse webhook 响应
 type WebhookResponse struct {
 	Status  string `json:"status" example:"ok"`
@@ -38,9 +39,12 @@ type WebhookResponse struct {
 	Reason  string `json:"reason,omitempty" example:"not a release event"`
 }
 
-// Webhook 处理 GitHub webhook 回调
-// @Summary GitHub Webhook 回调
-// @Description 接收 GitHub release 事件，自动更新版本信息和缓存
+// maxWebhookBody webhook 请求体上限，release 事件包含发布说明和文件列表，通常远小于该值
+const maxWebhookBody = 5 << 20
+
+// Webhook 处理 GitHub / GitLab / Gitea webhook 回调
+// @Summary Release Webhook 回调
+// @Description 接收 release 发布和编辑事件 (GitHub、GitLab、Gitea/Forgejo，按 release.provider)，自动更新版本信息和缓存
 // @Tags webhook
 // @Accept json
 // @Produce json
@@ -50,71 +54,123 @@ type WebhookResponse struct {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 405 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
 // @Router /api/v1/webhook [post]
-func Webhook(w http.ResponseWriter, r *http.Request) {
+func (s *Server) Webhook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		httpError(w, http.StatusMethodNotAllowed, "只支持 POST")
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
 	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			httpError(w, http.StatusRequestEntityTooLarge, "请求体过大")
+			return
+		}
 		httpError(w, http.StatusBadRequest, "读取请求体失败")
 		return
 	}
 
+	cfg := s.config.Get()
+	provider := cfg.Release.Provider
+
+	// 记录最近的请求，供管理接口查看
+	d := &WebhookDelivery{
+		ID:         deliveryID(provider, r.Header),
+		ReceivedAt: time.Now(),
+		Event:      eventName(provider, r.Header),
+		RequestID:  logging.RequestID(r.Context()),
+	}
+	defer s.webhookLog.add(d)
+
 	// 验证签名 (如果配置了 secret)
-	cfg := config.Get()
-	if cfg.Release.WebhookSecret != "" {
-		signature := r.Header.Get("X-Hub-Signature-256")
-		if !verifySignature(body, signature, cfg.Release.WebhookSecret) {
-			httpError(w, http.StatusUnauthorized, "签名验证失败")
-			return
-		}
+	if cfg.Release.WebhookSecret != "" && !verifyWebhook(provider, r.Header, body, cfg.Release.WebhookSecret) {
+		s.webhookResult(d, "invalid_signature")
+		httpError(w, http.StatusUnauthorized, "签名验证失败")
+		return
 	}
 
 	// 检查事件类型
-	event := r.Header.Get("X-GitHub-Event")
-	if event != "release" {
+	if !isReleaseEvent(provider, r.Header) {
+		s.webhookResult(d, "ignored")
 		jsonResponse(w, map[string]string{"status": "ignored", "reason": "not a release event"})
 		return
 	}
 
 	var payload webhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
+	if provider == config.ProviderGitLab {
+		var p gitlabWebhookPayload
+		if err := json.Unmarshal(body, &p); err != nil {
+			s.webhookResult(d, "bad_payload")
+			httpError(w, http.StatusBadRequest, "解析 payload 失败")
+			return
+		}
+		// GitLab 创建 release 即发布
+		payload.Action = p.Action
+		switch p.Action {
+		case "create":
+			payload.Action = "published"
+		case "update":
+			payload.Action = "edited"
+		}
+		payload.Release.TagName = p.Tag
+	} else if err := json.Unmarshal(body, &payload); err != nil {
+		s.webhookResult(d, "bad_payload")
 		httpError(w, http.StatusBadRequest, "解析 payload 失败")
 		return
 	}
 
-	// 只处理 published 事件
-	if payload.Action != "published" {
+	d.Action, d.Tag = payload.Action, payload.Release.TagName
+
+	// 只处理发布和编辑 (可能添加或移除了撤回标记 yank.label) 事件
+	if payload.Action != "published" && payload.Action != "edited" {
+		s.webhookResult(d, "ignored")
 		jsonResponse(w, map[string]string{"status": "ignored", "reason": "action is " + payload.Action})
 		return
 	}
 
-	// 防重复处理：同一 tag 在 60 秒内不重复处理
-	webhookMutex.Lock()
-	if payload.Release.TagName == lastProcessedTag && time.Since(lastProcessedTime) < 60*time.Second {
-		webhookMutex.Unlock()
-		log.Printf("跳过重复 webhook: %s (%.0f秒内已处理)", payload.Release.TagName, time.Since(lastProcessedTime).Seconds())
+	// 防重复处理：同一 tag 的同一事件在 60 秒内不重复处理
+	key := payload.Action + " " + payload.Release.TagName
+	s.webhookMu.Lock()
+	if elapsed := time.Since(s.lastProcessedTime); key == s.lastProcessedKey && elapsed < 60*time.Second {
+		s.webhookMu.Unlock()
+		s.webhookResult(d, "duplicate")
+		slog.InfoContext(r.Context(), "跳过重复 webhook", "tag", payload.Release.TagName, "elapsed", elapsed.Round(time.Second))
 		jsonResponse(w, map[string]string{"status": "skipped", "reason": "duplicate request"})
 		return
 	}
-	lastProcessedTag = payload.Release.TagName
-	lastProcessedTime = time.Now()
-	webhookMutex.Unlock()
+	s.lastProcessedKey = key
+	s.lastProcessedTime = time.Now()
+	// 新的 release 到达时取消上一次尚未完成的同步
+	if s.webhookCancel != nil {
+		s.webhookCancel()
+	}
+	taskCtx, cancel := context.WithCancel(s.taskContext(r))
+	s.webhookCancel = cancel
+	s.webhookMu.Unlock()
 
-	log.Printf("收到 release webhook: %s (action: %s)", payload.Release.TagName, payload.Action)
+	slog.InfoContext(r.Context(), "收到 release webhook", "tag", payload.Release.TagName, "action", payload.Action)
+	s.webhookResult(d, "accepted")
 
 	// 异步更新版本信息和缓存
-	go func() {
-		if err := version.Refresh(); err != nil {
-			log.Printf("刷新版本信息失败: %v", err)
+	s.tasks.Go("webhook", func(context.Context) {
+		defer cancel()
+
+		refreshCtx, cancelRefresh := context.WithTimeout(taskCtx, cfg.HTTP.APITimeout)
+		refreshErr := s.versions.Refresh(refreshCtx)
+		cancelRefresh()
+		if refreshErr != nil {
+			slog.ErrorContext(taskCtx, "刷新版本信息失败", "err", refreshErr)
 		}
-		if err := cache.Sync(); err != nil {
-			log.Printf("同步缓存失败: %v", err)
+
+		syncErr := s.syncCache(taskCtx, "webhook")
+		if syncErr != nil {
+			slog.ErrorContext(taskCtx, "同步缓存失败", "err", syncErr)
 		}
-	}()
+		s.webhookLog.finish(d, errors.Join(refreshErr, syncErr))
+	})
 
 	jsonResponse(w, map[string]string{
 		"status":  "ok",
@@ -122,6 +178,116 @@ func Webhook(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// WebhookDelivery 一次 webhook 请求及其处理结果
+type WebhookDelivery struct {
+	ID         string     `json:"id,omitempty"` // X-GitHub-Delivery / X-Gitea-Delivery / X-Gitlab-Event-UUID
+	ReceivedAt time.Time  `json:"received_at"`
+	Event      string     `json:"event,omitempty" example:"release"`
+	Action     string     `json:"action,omitempty" example:"published"`
+	Tag        string     `json:"tag,omitempty" example:"v1.2.0"`
+	Result     string     `json:"result" example:"accepted"` // accepted / ignored / duplicate / invalid_signature / bad_payload
+	RequestID  string     `json:"request_id,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // 后台刷新和同步完成的时间 (仅 accepted)
+	Error      string     `json:"error,omitempty"`       // 后台刷新或同步的错误
+}
+
+// webhookResult 记录 webhook 处理结果 (指标和最近请求)
+func (s *Server) webhookResult(d *WebhookDelivery, result string) {
+	d.Result = result
+	s.metrics.webhooks.Inc(result)
+}
+
+// deliveryLog 最近的 webhook 请求 (只保存在内存中)
+type deliveryLog struct {
+	mu         sync.Mutex
+	deliveries []*WebhookDelivery
+}
+
+// 保留的 webhook 请求数
+const maxDeliveries = 50
+
+func (l *deliveryLog) add(d *WebhookDelivery) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deliveries = append(l.deliveries, d)
+	if len(l.deliveries) > maxDeliveries {
+		l.deliveries = l.deliveries[len(l.deliveries)-maxDeliveries:]
+	}
+}
+
+// finish 记录后台刷新和同步的结果
+func (l *deliveryLog) finish(d *WebhookDelivery, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	d.FinishedAt = &now
+	if err != nil {
+		d.Error = err.Error()
+	}
+}
+
+// list 返回最近的请求，新的在前
+func (l *deliveryLog) list() []WebhookDelivery {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]WebhookDelivery, 0, len(l.deliveries))
+	for i := len(l.deliveries) - 1; i >= 0; i-- {
+		out = append(out, *l.deliveries[i])
+	}
+	return out
+}
+
+// deliveryID 来源为每次 webhook 投递生成的 ID
+func deliveryID(provider string, header http.Header) string {
+	switch provider {
+	case config.ProviderGitLab:
+		return header.Get("X-Gitlab-Event-UUID")
+	case config.ProviderGitea:
+		return header.Get("X-Gitea-Delivery")
+	default:
+		return header.Get("X-GitHub-Delivery")
+	}
+}
+
+// eventName webhook 事件类型
+func eventName(provider string, header http.Header) string {
+	switch provider {
+	case config.ProviderGitLab:
+		return header.Get("X-Gitlab-Event")
+	case config.ProviderGitea:
+		return header.Get("X-Gitea-Event")
+	default:
+		return header.Get("X-GitHub-Event")
+	}
+}
+
+// verifyWebhook 按来源校验 webhook 请求
+//
+// GitHub 为 X-Hub-Signature-256 (sha256= 前缀)，Gitea/Forgejo 为
+// X-Gitea-Signature (十六进制 HMAC)，GitLab 为原样回传的 X-Gitlab-Token。
+func verifyWebhook(provider string, header http.Header, body []byte, secret string) bool {
+	switch provider {
+	case config.ProviderGitLab:
+		return hmac.Equal([]byte(header.Get("X-Gitlab-Token")), []byte(secret))
+	case config.ProviderGitea:
+		return verifySignature(body, "sha256="+header.Get("X-Gitea-Signature"), secret)
+	default:
+		return verifySignature(body, header.Get("X-Hub-Signature-256"), secret)
+	}
+}
+
+// isReleaseEvent 判断是否为 release 事件
+func isReleaseEvent(provider string, header http.Header) bool {
+	switch provider {
+	case config.ProviderGitLab:
+		return header.Get("X-Gitlab-Event") == "Release Hook"
+	case config.ProviderGitea:
+		return header.Get("X-Gitea-Event") == "release"
+	default:
+		return header.Get("X-GitHub-Event") == "release"
+	}
+}
+
 func verifySignature(payload []byte, signature, secret string) bool {
 	if !strings.HasPrefix(signature, "sha256=") {
 		return false
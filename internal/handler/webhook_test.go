@@ -8,58 +8,49 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
 	"testing"
 
 	"update-server/internal/config"
 )
 
-func setupWebhookTestConfig(t *testing.T, secret string) func() {
-	tmpDir, err := os.MkdirTemp("", "webhook-test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	configContent := `
-server:
-  port: 8080
-  host: "127.0.0.1"
-  base_url: "http://localhost:8080"
-github:
-  repo: "test/repo"
-  webhook_secret: "` + secret + `"
-cache:
-  dir: "` + filepath.Join(tmpDir, "cache") + `"
-`
-	configPath := filepath.Join(tmpDir, "config.yaml")
-	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
-		t.Fatal(err)
-	}
-
-	os.Setenv("CONFIG_PATH", configPath)
-	config.Load()
-
-	return func() {
-		os.RemoveAll(tmpDir)
-		os.Unsetenv("CONFIG_PATH")
-	}
+func newWebhookTestServer(t *testing.T, secret string) *Server {
+	return newTestServer(t, func(c *config.Config) {
+		c.Release.WebhookSecret = secret
+	})
 }
 
 func TestWebhook_MethodNotAllowed(t *testing.T) {
+	t.Parallel()
+	s := newWebhookTestServer(t, "")
+
 	req := httptest.NewRequest("GET", "/api/v1/webhook", nil)
 	w := httptest.NewRecorder()
 
-	Webhook(w, req)
+	s.Webhook(w, req)
 
 	if w.Code != http.StatusMethodNotAllowed {
 		t.Errorf("期望状态码 405, 得到 %d", w.Code)
 	}
 }
 
+func TestWebhook_BodyTooLarge(t *testing.T) {
+	t.Parallel()
+	s := newWebhookTestServer(t, "")
+
+	req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(make([]byte, maxWebhookBody+1)))
+	req.Header.Set("X-GitHub-Event", "release")
+	w := httptest.NewRecorder()
+
+	s.Webhook(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("期望状态码 413, 得到 %d", w.Code)
+	}
+}
+
 func TestWebhook_InvalidSignature(t *testing.T) {
-	cleanup := setupWebhookTestConfig(t, "test-secret")
-	defer cleanup()
+	t.Parallel()
+	s := newWebhookTestServer(t, "test-secret")
 
 	payload := []byte(`{"action":"published"}`)
 	req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(payload))
@@ -67,7 +58,7 @@ func TestWebhook_InvalidSignature(t *testing.T) {
 	req.Header.Set("X-GitHub-Event", "release")
 	w := httptest.NewRecorder()
 
-	Webhook(w, req)
+	s.Webhook(w, req)
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("期望状态码 401, 得到 %d", w.Code)
@@ -76,8 +67,8 @@ func TestWebhook_InvalidSignature(t *testing.T) {
 
 func TestWebhook_ValidSignature(t *testing.T) {
 	secret := "test-secret"
-	cleanup := setupWebhookTestConfig(t, secret)
-	defer cleanup()
+	t.Parallel()
+	s := newWebhookTestServer(t, secret)
 
 	payload := []byte(`{"action":"published","release":{"tag_name":"v1.0.0"}}`)
 
@@ -91,7 +82,7 @@ func TestWebhook_ValidSignature(t *testing.T) {
 	req.Header.Set("X-GitHub-Event", "release")
 	w := httptest.NewRecorder()
 
-	Webhook(w, req)
+	s.Webhook(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("期望状态码 200, 得到 %d", w.Code)
@@ -108,15 +99,15 @@ func TestWebhook_ValidSignature(t *testing.T) {
 }
 
 func TestWebhook_IgnoreNonReleaseEvent(t *testing.T) {
-	cleanup := setupWebhookTestConfig(t, "")
-	defer cleanup()
+	t.Parallel()
+	s := newWebhookTestServer(t, "")
 
 	payload := []byte(`{}`)
 	req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-GitHub-Event", "push")
 	w := httptest.NewRecorder()
 
-	Webhook(w, req)
+	s.Webhook(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("期望状态码 200, 得到 %d", w.Code)
@@ -130,15 +121,15 @@ func TestWebhook_IgnoreNonReleaseEvent(t *testing.T) {
 }
 
 func TestWebhook_IgnoreNonPublishedAction(t *testing.T) {
-	cleanup := setupWebhookTestConfig(t, "")
-	defer cleanup()
+	t.Parallel()
+	s := newWebhookTestServer(t, "")
 
 	payload := []byte(`{"action":"created"}`)
 	req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(payload))
 	req.Header.Set("X-GitHub-Event", "release")
 	w := httptest.NewRecorder()
 
-	Webhook(w, req)
+	s.Webhook(w, req)
 
 	if w.Code != http.StatusOK {
 		t.Errorf("期望状态码 200, 得到 %d", w.Code)
@@ -151,6 +142,32 @@ func TestWebhook_IgnoreNonPublishedAction(t *testing.T) {
 	}
 }
 
+func TestWebhook_EditedAfterPublished(t *testing.T) {
+	t.Parallel()
+	s := newWebhookTestServer(t, "")
+
+	// 发布后立即编辑 (如添加撤回标记) 不视为重复请求
+	for _, tt := range []struct{ action, status string }{
+		{"published", "ok"},
+		{"edited", "ok"},
+		{"edited", "skipped"},
+	} {
+		payload := []byte(`{"action":"` + tt.action + `","release":{"tag_name":"v1.0.0"}}`)
+		req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "release")
+		w := httptest.NewRecorder()
+		s.Webhook(w, req)
+		var resp map[string]string
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp["status"] != tt.status {
+			t.Errorf("%s: 期望 status=%s, 得到 %v", tt.action, tt.status, resp)
+		}
+	}
+	if d := s.webhookLog.list(); d[0].Result != "duplicate" || d[1].Result != "accepted" || d[2].Result != "accepted" {
+		t.Errorf("期望依次为 accepted, accepted, duplicate, 得到 %+v", d)
+	}
+}
+
 func TestVerifySignature(t *testing.T) {
 	secret := "my-secret"
 	payload := []byte("test payload")
@@ -179,3 +196,35 @@ func TestVerifySignature(t *testing.T) {
 		})
 	}
 }
+
+func TestWebhook_GitLab(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, func(c *config.Config) {
+		c.Release.Provider = config.ProviderGitLab
+		c.Release.WebhookSecret = "test-secret"
+	})
+
+	payload := []byte(`{"object_kind":"release","action":"create","tag":"v1.0.0"}`)
+
+	// token 错误
+	req := httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Gitlab-Event", "Release Hook")
+	req.Header.Set("X-Gitlab-Token", "wrong")
+	w := httptest.NewRecorder()
+	s.Webhook(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("期望状态码 401, 得到 %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/webhook", bytes.NewReader(payload))
+	req.Header.Set("X-Gitlab-Event", "Release Hook")
+	req.Header.Set("X-Gitlab-Token", "test-secret")
+	w = httptest.NewRecorder()
+	s.Webhook(w, req)
+
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["status"] != "ok" || resp["version"] != "v1.0.0" {
+		t.Errorf("期望 status=ok version=v1.0.0, 得到 %v", resp)
+	}
+}
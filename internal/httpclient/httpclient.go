@@ -0,0 +1,207 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"update-server/internal/config"
+	"update-server/internal/tracing"
+)
+
+// Clients 出站 HTTP 客户端
+//
+// API 与 Download 共用同一个 transport (代理、CA、DNS 覆盖和连接池)，只是超时不同。
+type Clients struct {
+	API      *http.Client // API 请求，超时为 api_timeout
+	Download *http.Client // 大文件下载，超时为 download_timeout
+
+	transport *http.Transport
+}
+
+// Observer 每个 API 请求完成后调用 (resp 与 err 之一非空)，用于统计请求结果和剩余速率限制
+type Observer func(req *http.Request, resp *http.Response, err error)
+
+// New 按 http 配置创建出站客户端
+func New(cfg config.HTTP) (*Clients, error) {
+	return newClients(cfg, nil)
+}
+
+func newClients(cfg config.HTTP, observe Observer) (*Clients, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var api http.RoundTripper = transport
+	if observe != nil {
+		api = observed{base: transport, observe: observe}
+	}
+	return &Clients{
+		API: &http.Client{
+			Timeout:   cfg.APITimeout,
+			Transport: tracing.Transport(api),
+		},
+		Download: &http.Client{
+			Timeout:       cfg.DownloadTimeout,
+			Transport:     tracing.Transport(transport),
+			CheckRedirect: dropAuthOnRedirect,
+		},
+		transport: transport,
+	}, nil
+}
+
+type observed struct {
+	base    http.RoundTripper
+	observe Observer
+}
+
+func (o observed) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := o.base.RoundTrip(req)
+	o.observe(req, resp, err)
+	return resp, err
+}
+
+// CloseIdleConnections 关闭空闲连接 (配置变更换用新客户端后释放旧连接)
+func (c *Clients) CloseIdleConnections() {
+	c.transport.CloseIdleConnections()
+}
+
+// NewTransport 按 http 配置创建 transport
+func NewTransport(cfg config.HTTP) (*http.Transport, error) {
+	resolve, err := cfg.ResolveMap()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := proxyFunc(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlsConfig(cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dial := dialer.DialContext
+	if len(resolve) > 0 {
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if host, port, err := net.SplitHostPort(addr); err == nil {
+				if ip, ok := resolve[strings.ToLower(host)]; ok {
+					addr = net.JoinHostPort(ip, port)
+				}
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		// 大文件下载时减少系统调用次数
+		ReadBufferSize:  128 << 10,
+		WriteBufferSize: 32 << 10,
+	}, nil
+}
+
+// proxyFunc 配置了 proxy 时使用配置 (no_proxy 指定绕过的主机)，否则使用环境变量
+func proxyFunc(cfg config.HTTP) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	if _, err := url.Parse(cfg.Proxy); err != nil {
+		return nil, errors.New("http.proxy 格式错误")
+	}
+	fn := (&httpproxy.Config{
+		HTTPProxy:  cfg.Proxy,
+		HTTPSProxy: cfg.Proxy,
+		NoProxy:    cfg.NoProxy,
+	}).ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}, nil
+}
+
+// tlsConfig 在系统证书之外信任 caFile 中的 CA
+func tlsConfig(caFile string) (*tls.Config, error) {
+	if caFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取 http.ca_file 失败: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("http.ca_file 中没有有效的证书: %s", caFile)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// dropAuthOnRedirect 跳转到其他主机 (如签名的对象存储地址) 时去掉认证头
+//
+// 标准库只处理 Authorization 且按域名后缀判断，这里对 PRIVATE-TOKEN 等自定义头
+// 同样生效；签名 URL 收到多余的认证头时也会直接拒绝请求。
+func dropAuthOnRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("重定向次数过多")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		req.Header.Del("Authorization")
+		req.Header.Del("PRIVATE-TOKEN")
+	}
+	return nil
+}
+
+// Pool 按 http 配置缓存客户端
+//
+// 配置不变时复用同一组客户端以复用连接；热重载修改 http 配置后换用新客户端，
+// 并关闭旧客户端的空闲连接。零值可直接使用。
+type Pool struct {
+	Observer Observer // 可选，观察 API 客户端的请求
+
+	mu      sync.Mutex
+	cfg     config.HTTP
+	clients *Clients
+}
+
+// Get 返回 cfg 对应的客户端
+func (p *Pool) Get(cfg config.HTTP) (*Clients, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.clients != nil && p.cfg == cfg {
+		return p.clients, nil
+	}
+	clients, err := newClients(cfg, p.Observer)
+	if err != nil {
+		return nil, err
+	}
+	if p.clients != nil {
+		p.clients.CloseIdleConnections()
+	}
+	p.cfg, p.clients = cfg, clients
+	return clients, nil
+}
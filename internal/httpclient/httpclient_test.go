@@ -0,0 +1,124 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"update-server/internal/config"
+)
+
+func testConfig() config.HTTP {
+	return config.Default().HTTP
+}
+
+func get(t *testing.T, c *http.Client, url string) string {
+	t.Helper()
+	resp, err := c.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestProxyAndBypass(t *testing.T) {
+	// 代理：收到绝对 URL 形式的请求
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("via proxy " + r.URL.Host))
+	}))
+	defer proxy.Close()
+
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+	_, port, _ := net.SplitHostPort(direct.Listener.Addr().String())
+
+	cfg := testConfig()
+	cfg.Proxy = proxy.URL
+	cfg.NoProxy = ".internal.test"
+	cfg.Resolve = "mirror.internal.test=127.0.0.1"
+
+	clients, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := get(t, clients.API, "http://github.example/x"); got != "via proxy github.example" {
+		t.Errorf("期望经过代理, 得到 %q", got)
+	}
+	// no_proxy 中的主机直连，resolve 将其解析到本地测试服务
+	if got := get(t, clients.Download, "http://mirror.internal.test:"+port+"/x"); got != "direct" {
+		t.Errorf("期望绕过代理直连, 得到 %q", got)
+	}
+}
+
+func TestCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// 未信任自签名证书时应失败
+	clients, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clients.API.Get(srv.URL); err == nil {
+		t.Fatal("未配置 ca_file 时应拒绝自签名证书")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig()
+	cfg.CAFile = caFile
+	clients, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(t, clients.API, srv.URL); got != "ok" {
+		t.Errorf("期望 ok, 得到 %q", got)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.Resolve = "github.com"
+	if _, err := New(cfg); err == nil {
+		t.Error("resolve 格式错误应返回错误")
+	}
+
+	cfg = testConfig()
+	cfg.CAFile = "/nonexistent/ca.pem"
+	if _, err := New(cfg); err == nil {
+		t.Error("ca_file 不存在应返回错误")
+	}
+}
+
+func TestPool_ReuseUntilConfigChanges(t *testing.T) {
+	var p Pool
+	cfg := testConfig()
+
+	a, _ := p.Get(cfg)
+	b, _ := p.Get(cfg)
+	if a != b {
+		t.Error("配置不变时应复用客户端")
+	}
+
+	cfg.MaxIdleConnsPerHost = 20
+	c, _ := p.Get(cfg)
+	if c == a {
+		t.Error("配置变化后应创建新客户端")
+	}
+}
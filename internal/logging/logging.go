@@ -0,0 +1,162 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"update-server/internal/config"
+)
+
+// level 当前日志级别，配置热重载时更新
+var level slog.LevelVar
+
+// Setup 按配置安装默认 logger (slog.Default 和标准库 log 都输出到 w)
+//
+// 日志中自动带上 context 里的请求 ID，调用方使用 slog.InfoContext 等带 context 的方法即可。
+func Setup(w io.Writer, cfg config.Log) {
+	SetLevel(cfg)
+
+	opts := &slog.HandlerOptions{Level: &level}
+	var h slog.Handler
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+}
+
+// SetLevel 更新日志级别 (配置已校验，无效值时保持不变)
+func SetLevel(cfg config.Log) {
+	if l, err := cfg.SlogLevel(); err == nil {
+		level.Set(l)
+	}
+}
+
+// Request 一次请求的日志上下文
+//
+// 由 HTTP 中间件创建并放入请求 context；Route 由路由分发后补充。
+type Request struct {
+	ID       string
+	ClientIP string
+	Route    string
+}
+
+type requestKey struct{}
+
+// NewContext 返回携带请求信息的 context
+func NewContext(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// FromContext 返回 context 中的请求信息，没有时返回 nil
+func FromContext(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
+
+// RequestID 返回 context 中的请求 ID
+func RequestID(ctx context.Context) string {
+	if req := FromContext(ctx); req != nil {
+		return req.ID
+	}
+	return ""
+}
+
+// WithRequestID 返回携带请求 ID 的 context，用于把请求 ID 带到后台任务
+// (如 webhook 触发的同步)；id 为空时生成新的 ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		id = NewID()
+	}
+	return NewContext(ctx, &Request{ID: id})
+}
+
+// NewID 生成随机请求 ID (32 位十六进制)
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ClientIP 返回请求的客户端 IP
+//
+// 直连地址属于可信代理 (或不是 TCP 连接，如 unix socket) 时，从 X-Forwarded-For
+// 末尾向前跳过可信代理，取第一个不可信的地址；否则使用直连地址。
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err == nil && !isTrusted(addr, trusted) {
+		return addr.Unmap().String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !isTrusted(hop, trusted) || i == 0 {
+			return hop.Unmap().String()
+		}
+	}
+	if err == nil {
+		return addr.Unmap().String()
+	}
+	return host
+}
+
+// FromTrustedProxy 请求是否直接来自受信任的反向代理 (或 unix socket)，只有这时才能信任代理添加的请求头
+func FromTrustedProxy(r *http.Request, trusted []netip.Prefix) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err != nil || isTrusted(addr, trusted)
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler 为每条日志添加 context 中的 request_id 和 trace_id
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"update-server/internal/config"
+)
+
+func TestSetup(t *testing.T) {
+	old := slog.Default()
+	defer slog.SetDefault(old)
+
+	var buf bytes.Buffer
+	Setup(&buf, config.Log{Level: "warn", Format: "json"})
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	slog.InfoContext(ctx, "不输出")
+	slog.WarnContext(ctx, "同步失败", "tag", "v1.0.0")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("应只输出一条 JSON 日志: %v\n%s", err, buf.String())
+	}
+	if entry["msg"] != "同步失败" || entry["request_id"] != "abc123" || entry["tag"] != "v1.0.0" {
+		t.Errorf("日志内容错误: %v", entry)
+	}
+
+	// 热重载降低级别
+	buf.Reset()
+	SetLevel(config.Log{Level: "debug"})
+	slog.DebugContext(context.Background(), "调试")
+	if buf.Len() == 0 {
+		t.Error("修改级别后应输出 debug 日志")
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "")
+	if id := RequestID(ctx); len(id) != 32 {
+		t.Errorf("应生成 32 位 ID: %q", id)
+	}
+	if RequestID(context.Background()) != "" {
+		t.Error("没有请求信息时应返回空")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32"), netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"直连", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"不可信来源忽略 XFF", "203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"可信代理", "127.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"跳过多级可信代理", "127.0.0.1:1234", "198.51.100.1, 203.0.113.9, 10.0.0.2", "203.0.113.9"},
+		{"全部可信取最左", "127.0.0.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"XFF 格式错误", "127.0.0.1:1234", "garbage", "127.0.0.1"},
+		{"unix socket", "@", "198.51.100.1", "198.51.100.1"},
+		{"IPv4 映射地址", "[::ffff:203.0.113.5]:1234", "", "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Errorf("期望 %s, 得到 %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFromTrustedProxy(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	for remote, want := range map[string]bool{
+		"10.1.2.3:1234":       true,
+		"203.0.113.5:1234":    false,
+		"[::ffff:10.0.0.1]:1": true,
+		"@":                   true,
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		if got := FromTrustedProxy(r, trusted); got != want {
+			t.Errorf("%s: 期望 %v, 得到 %v", remote, want, got)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func output(t *testing.T, r *Registry) string {
+	t.Helper()
+	var sb strings.Builder
+	if _, err := r.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	return sb.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_requests_total", "请求数", "route", "code")
+	c.Inc("/a", "200")
+	c.Add(2, "/a", "200")
+	c.Inc("/b\"x", "500")
+	g := r.Gauge("test_remaining", "剩余")
+	g.Set(42)
+	r.GaugeFunc("test_size_bytes", "大小", func() float64 { return 1.5 })
+
+	want := `# HELP test_requests_total 请求数
+# TYPE test_requests_total counter
+test_requests_total{route="/a",code="200"} 3
+test_requests_total{route="/b\"x",code="500"} 1
+# HELP test_remaining 剩余
+# TYPE test_remaining gauge
+test_remaining 42
+# HELP test_size_bytes 大小
+# TYPE test_size_bytes gauge
+test_size_bytes 1.5
+`
+	if got := output(t, r); got != want {
+		t.Errorf("输出不一致:\n%s\n期望:\n%s", got, want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.Histogram("test_duration_seconds", "耗时", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+
+	want := `# HELP test_duration_seconds 耗时
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 1
+test_duration_seconds_bucket{route="/a",le="1"} 2
+test_duration_seconds_bucket{route="/a",le="+Inf"} 3
+test_duration_seconds_sum{route="/a"} 5.55
+test_duration_seconds_count{route="/a"} 3
+`
+	if got := output(t, r); got != want {
+		t.Errorf("输出不一致:\n%s\n期望:\n%s", got, want)
+	}
+}
+
+func TestMaxSeries(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_versions_total", "版本", "version")
+	for i := 0; i < MaxSeries+10; i++ {
+		c.Inc(strconv.Itoa(i))
+	}
+
+	out := output(t, r)
+	if n := strings.Count(out, "\ntest_versions_total{"); n != MaxSeries+1 {
+		t.Errorf("期望 %d 个序列, 得到 %d", MaxSeries+1, n)
+	}
+	if !strings.Contains(out, `test_versions_total{version="other"} 10`) {
+		t.Error("超出上限的取值应计入 other")
+	}
+}
@@ -0,0 +1,102 @@
+package release
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"update-server/internal/config"
+	"update-server/internal/httpclient"
+)
+
+// Gitea 自建 Gitea 或 Forgejo 上的 Release
+type Gitea struct {
+	api      string // https://{host}/api/v1/repos/{owner}/{repo}
+	token    string
+	client   *http.Client
+	download *http.Client
+}
+
+func NewGitea(repo config.GitHubRepo, clients *httpclient.Clients) *Gitea {
+	return &Gitea{
+		api:      strings.TrimRight(repo.BaseURL, "/") + "/api/v1/repos/" + repo.Repo,
+		token:    repo.Token,
+		client:   clients.API,
+		download: clients.Download,
+	}
+}
+
+type giteaRelease struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	Body        string `json:"body"`
+	PublishedAt string `json:"published_at"`
+	Draft       bool   `json:"draft"`
+	Prerelease  bool   `json:"prerelease"`
+	Assets      []struct {
+		ID                 int64  `json:"id"`
+		Name               string `json:"name"`
+		Size               int64  `json:"size"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (g *Gitea) header() http.Header {
+	header := http.Header{}
+	if g.token != "" {
+		header.Set("Authorization", "token "+g.token)
+	}
+	return header
+}
+
+func (g *Gitea) ListReleases(ctx context.Context) ([]Release, error) {
+	var releases []giteaRelease
+	if err := getJSON(ctx, g.client, g.api+"/releases?draft=false&limit=50", g.header(), &releases); err != nil {
+		return nil, err
+	}
+	out := make([]Release, 0, len(releases))
+	for i := range releases {
+		if releases[i].Draft {
+			continue
+		}
+		out = append(out, *releases[i].convert())
+	}
+	return out, nil
+}
+
+func (g *Gitea) LatestRelease(ctx context.Context) (*Release, error) {
+	var r giteaRelease
+	if err := getJSON(ctx, g.client, g.api+"/releases/latest", g.header(), &r); err != nil {
+		return nil, err
+	}
+	return r.convert(), nil
+}
+
+func (g *Gitea) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	var r giteaRelease
+	if err := getJSON(ctx, g.client, g.api+"/releases/tags/"+url.PathEscape(tag), g.header(), &r); err != nil {
+		return nil, err
+	}
+	return r.convert(), nil
+}
+
+func (g *Gitea) OpenAsset(ctx context.Context, asset Asset) (io.ReadCloser, int64, error) {
+	return openURL(ctx, g.download, asset.URL, g.header())
+}
+
+func (r *giteaRelease) convert() *Release {
+	out := &Release{
+		Tag:         r.TagName,
+		Name:        r.Name,
+		Notes:       r.Body,
+		PublishedAt: r.PublishedAt,
+		Prerelease:  r.Prerelease,
+		Assets:      make([]Asset, 0, len(r.Assets)),
+	}
+	for _, a := range r.Assets {
+		out.Assets = append(out.Assets, Asset{ID: a.ID, Name: a.Name, Size: a.Size, URL: a.BrowserDownloadURL})
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"update-server/internal/config"
+	"update-server/internal/github"
+	"update-server/internal/httpclient"
+)
+
+// GitHub github.com 或 GitHub Enterprise 上的 Release
+type GitHub struct {
+	repo     string
+	auth     github.TokenSource
+	api      *github.Client
+	download *http.Client
+}
+
+// NewGitHub 创建 GitHub 来源，配置了 GitHub App 时使用安装令牌认证
+func NewGitHub(repo config.GitHubRepo, clients *httpclient.Clients) (*GitHub, error) {
+	auth, err := github.NewTokenSource(repo, clients.API)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHub{
+		repo:     repo.Repo,
+		auth:     auth,
+		api:      github.NewClient(repo.BaseURL, auth, clients.API),
+		download: clients.Download,
+	}, nil
+}
+
+func (g *GitHub) ListReleases(ctx context.Context) ([]Release, error) {
+	releases, err := g.api.FetchReleases(ctx, g.repo)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Release, 0, len(releases))
+	for i := range releases {
+		if releases[i].Draft {
+			continue
+		}
+		out = append(out, *fromGitHub(&releases[i]))
+	}
+	return out, nil
+}
+
+func (g *GitHub) LatestRelease(ctx context.Context) (*Release, error) {
+	r, err := g.api.FetchLatestRelease(ctx, g.repo)
+	if err != nil {
+		return nil, err
+	}
+	return fromGitHub(r), nil
+}
+
+func (g *GitHub) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	r, err := g.api.FetchRelease(ctx, g.repo, tag)
+	if err != nil {
+		if errors.Is(err, github.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return fromGitHub(r), nil
+}
+
+// OpenAsset 有 asset ID 时走 REST asset 接口 (私有仓库的 browser_download_url 无法用 token 下载)
+func (g *GitHub) OpenAsset(ctx context.Context, asset Asset) (io.ReadCloser, int64, error) {
+	if asset.ID != 0 {
+		body, size, err := g.api.OpenAsset(ctx, g.download, g.repo, asset.ID)
+		if errors.Is(err, github.ErrNotFound) {
+			return nil, 0, ErrNotFound
+		}
+		return body, size, err
+	}
+
+	token, err := g.auth.Token(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "token "+token)
+	}
+	return openURL(ctx, g.download, asset.URL, header)
+}
+
+func fromGitHub(r *github.Release) *Release {
+	out := &Release{
+		Tag:         r.TagName,
+		Name:        r.Name,
+		Notes:       r.Body,
+		PublishedAt: r.PublishedAt,
+		Prerelease:  r.Prerelease,
+		Assets:      make([]Asset, 0, len(r.Assets)),
+	}
+	for _, a := range r.Assets {
+		var sum string
+		if d, ok := strings.CutPrefix(a.Digest, "sha256:"); ok {
+			sum = d
+		}
+		out.Assets = append(out.Assets, Asset{
+			ID:     a.ID,
+			Name:   a.Name,
+			Size:   a.Size,
+			URL:    a.BrowserDownloadURL,
+			SHA256: sum,
+		})
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package release
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"update-server/internal/config"
+	"update-server/internal/httpclient"
+)
+
+// DefaultGitLabURL gitlab.com 地址
+const DefaultGitLabURL = "https://gitlab.com"
+
+// GitLab gitlab.com 或自建 GitLab 上的 Release
+//
+// GitLab 的 Release 文件是链接 (assets.links)，不提供文件大小。
+type GitLab struct {
+	api      string // https://{host}/api/v4/projects/{id}
+	token    string
+	client   *http.Client
+	download *http.Client
+}
+
+func NewGitLab(repo config.GitHubRepo, clients *httpclient.Clients) *GitLab {
+	base := strings.TrimRight(repo.BaseURL, "/")
+	if base == "" {
+		base = DefaultGitLabURL
+	}
+	return &GitLab{
+		api:      base + "/api/v4/projects/" + url.PathEscape(repo.Repo),
+		token:    repo.Token,
+		client:   clients.API,
+		download: clients.Download,
+	}
+}
+
+type gitlabRelease struct {
+	TagName     string `json:"tag_name"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ReleasedAt  string `json:"released_at"`
+	Upcoming    bool   `json:"upcoming_release"`
+	Assets      struct {
+		Links []struct {
+			ID             int64  `json:"id"`
+			Name           string `json:"name"`
+			URL            string `json:"url"`
+			DirectAssetURL string `json:"direct_asset_url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+func (g *GitLab) header() http.Header {
+	header := http.Header{}
+	if g.token != "" {
+		header.Set("PRIVATE-TOKEN", g.token)
+	}
+	return header
+}
+
+func (g *GitLab) ListReleases(ctx context.Context) ([]Release, error) {
+	var releases []gitlabRelease
+	if err := getJSON(ctx, g.client, g.api+"/releases?per_page=100", g.header(), &releases); err != nil {
+		return nil, err
+	}
+	out := make([]Release, 0, len(releases))
+	for i := range releases {
+		if releases[i].Upcoming {
+			continue
+		}
+		out = append(out, *releases[i].convert())
+	}
+	return out, nil
+}
+
+func (g *GitLab) LatestRelease(ctx context.Context) (*Release, error) {
+	var r gitlabRelease
+	if err := getJSON(ctx, g.client, g.api+"/releases/permalink/latest", g.header(), &r); err != nil {
+		return nil, err
+	}
+	return r.convert(), nil
+}
+
+func (g *GitLab) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	var r gitlabRelease
+	if err := getJSON(ctx, g.client, g.api+"/releases/"+url.PathEscape(tag), g.header(), &r); err != nil {
+		return nil, err
+	}
+	return r.convert(), nil
+}
+
+func (g *GitLab) OpenAsset(ctx context.Context, asset Asset) (io.ReadCloser, int64, error) {
+	return openURL(ctx, g.download, asset.URL, g.header())
+}
+
+func (r *gitlabRelease) convert() *Release {
+	out := &Release{
+		Tag:         r.TagName,
+		Name:        r.Name,
+		Notes:       r.Description,
+		PublishedAt: r.ReleasedAt,
+		Assets:      make([]Asset, 0, len(r.Assets.Links)),
+	}
+	for _, l := range r.Assets.Links {
+		u := l.DirectAssetURL
+		if u == "" {
+			u = l.URL
+		}
+		out.Assets = append(out.Assets, Asset{ID: l.ID, Name: l.Name, URL: u})
+	}
+	return out
+}
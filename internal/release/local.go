@@ -0,0 +1,252 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// MetadataFile 本地版本目录中的版本说明文件
+const MetadataFile = "release.yaml"
+
+// watchDebounce 目录变化合并等待时间
+var watchDebounce = 2 * time.Second
+
+// Local 本地目录中的 Release，用于无法访问外网的离线部署
+//
+// 目录结构为 <root>/<tag>/，其中放置文件和可选的 release.yaml:
+//
+//	name: "Orange 1.2.0"
+//	notes: |
+//	  更新说明
+//	date: 2024-01-02T15:04:05Z   # 或 2024-01-02，缺省时使用目录修改时间
+//	draft: false                 # 为 true 时不对外提供
+//	yanked: false                # 为 true 时撤回，不再作为最新版本
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// localMetadata release.yaml 内容
+type localMetadata struct {
+	Name   string `yaml:"name"`
+	Notes  string `yaml:"notes"`
+	Date   string `yaml:"date"`
+	Draft  bool   `yaml:"draft"`
+	Yanked bool   `yaml:"yanked"`
+}
+
+func (l *Local) ListReleases(ctx context.Context) ([]Release, error) {
+	entries, err := os.ReadDir(l.root)
+	if err != nil {
+		return nil, err
+	}
+
+	type dated struct {
+		release *Release
+		date    time.Time
+	}
+	var list []dated
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		r, date, draft, err := l.read(e.Name())
+		if err != nil {
+			slog.Warn("读取本地版本失败", "tag", e.Name(), "err", err)
+			continue
+		}
+		if draft {
+			continue
+		}
+		list = append(list, dated{r, date})
+	}
+
+	// 按发布时间倒序，时间相同时按 tag 倒序
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].date.Equal(list[j].date) {
+			return list[i].date.After(list[j].date)
+		}
+		return list[i].release.Tag > list[j].release.Tag
+	})
+
+	out := make([]Release, 0, len(list))
+	for _, d := range list {
+		out = append(out, *d.release)
+	}
+	return out, nil
+}
+
+func (l *Local) LatestRelease(ctx context.Context) (*Release, error) {
+	releases, err := l.ListReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, ErrNotFound
+	}
+	return &releases[0], nil
+}
+
+func (l *Local) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	if !validTag(tag) {
+		return nil, ErrNotFound
+	}
+	r, _, draft, err := l.read(tag)
+	if errors.Is(err, fs.ErrNotExist) || draft {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+func (l *Local) OpenAsset(ctx context.Context, asset Asset) (io.ReadCloser, int64, error) {
+	f, err := os.Open(asset.URL)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, ErrNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// read 读取一个版本目录
+func (l *Local) read(tag string) (*Release, time.Time, bool, error) {
+	dir := filepath.Join(l.root, tag)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if !info.IsDir() {
+		return nil, time.Time{}, false, fs.ErrNotExist
+	}
+
+	var meta localMetadata
+	if data, err := os.ReadFile(filepath.Join(dir, MetadataFile)); err == nil {
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, time.Time{}, false, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, false, err
+	}
+
+	date := info.ModTime()
+	if meta.Date != "" {
+		if date, err = parseDate(meta.Date); err != nil {
+			return nil, time.Time{}, false, err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	r := &Release{
+		Tag:         tag,
+		Name:        meta.Name,
+		Notes:       meta.Notes,
+		PublishedAt: date.UTC().Format(time.RFC3339),
+		Yanked:      meta.Yanked,
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || name == MetadataFile || strings.HasPrefix(name, ".") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		r.Assets = append(r.Assets, Asset{
+			Name: name,
+			Size: fi.Size(),
+			URL:  filepath.Join(dir, name),
+		})
+	}
+	return r, date, meta.Draft, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+func validTag(tag string) bool {
+	return tag != "" && tag != "." && !strings.Contains(tag, "..") && !strings.ContainsAny(tag, `/\`)
+}
+
+// Watch 监听目录变化 (新增版本目录、目录内文件或 release.yaml 变化)，ctx 取消时停止
+//
+// 事件合并 watchDebounce 后再回调，避免文件尚在复制时就开始同步。
+func (l *Local) Watch(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(l.root); err != nil {
+		watcher.Close()
+		return err
+	}
+	entries, _ := os.ReadDir(l.root)
+	for _, e := range entries {
+		if e.IsDir() {
+			watcher.Add(filepath.Join(l.root, e.Name()))
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// 新建的版本目录需要加入监听，才能感知其中后续写入的文件
+				if ev.Op&fsnotify.Create != 0 && filepath.Dir(ev.Name) == filepath.Clean(l.root) {
+					if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+						watcher.Add(ev.Name)
+					}
+				}
+				if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+					debounce = time.After(watchDebounce)
+				}
+			case <-debounce:
+				debounce = nil
+				onChange()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("监听本地 release 目录失败", "err", err)
+			}
+		}
+	}()
+
+	slog.Info("监听本地 release 目录变化", "dir", l.root)
+	return nil
+}
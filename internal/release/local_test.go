@@ -0,0 +1,102 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRelease(t *testing.T, root, tag, meta string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, tag)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if meta != "" {
+		if err := os.WriteFile(filepath.Join(dir, MetadataFile), []byte(meta), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLocal_Releases(t *testing.T) {
+	root := t.TempDir()
+	writeRelease(t, root, "v1.0.0", "notes: old\ndate: 2024-01-01\n", map[string]string{"app.zip": "v1"})
+	writeRelease(t, root, "v1.1.0", "name: 新版\nnotes: new\ndate: 2024-02-01T08:00:00Z\n", map[string]string{"app.zip": "v1.1", ".hidden": "x"})
+	writeRelease(t, root, "v2.0.0", "date: 2024-03-01\ndraft: true\n", map[string]string{"app.zip": "v2"})
+
+	src := NewLocal(root)
+	ctx := context.Background()
+
+	latest, err := src.LatestRelease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Tag != "v1.1.0" || latest.Notes != "new" || latest.PublishedAt != "2024-02-01T08:00:00Z" {
+		t.Errorf("期望最新版本 v1.1.0 (跳过草稿), 得到 %+v", latest)
+	}
+	if len(latest.Assets) != 1 || latest.Assets[0].Size != 4 {
+		t.Errorf("期望 1 个文件 (忽略 release.yaml 和隐藏文件), 得到 %+v", latest.Assets)
+	}
+
+	list, err := src.ListReleases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Tag != "v1.0.0" {
+		t.Errorf("期望按时间倒序的 2 个版本, 得到 %+v", list)
+	}
+
+	for _, tag := range []string{"v2.0.0", "v9.9.9", "../x"} {
+		if _, err := src.GetRelease(ctx, tag); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: 期望 ErrNotFound, 得到 %v", tag, err)
+		}
+	}
+
+	body, size, err := src.OpenAsset(ctx, latest.Assets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	if string(data) != "v1.1" || size != 4 {
+		t.Errorf("期望内容 v1.1, 得到 %q (%d)", data, size)
+	}
+}
+
+func TestLocal_Empty(t *testing.T) {
+	if _, err := NewLocal(t.TempDir()).LatestRelease(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("空目录期望 ErrNotFound, 得到 %v", err)
+	}
+}
+
+func TestLocal_Watch(t *testing.T) {
+	watchDebounce = 50 * time.Millisecond
+	defer func() { watchDebounce = 2 * time.Second }()
+
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 10)
+	if err := NewLocal(root).Watch(ctx, func() { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	writeRelease(t, root, "v1.0.0", "", map[string]string{"app.zip": "v1"})
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("新增版本目录后应触发回调")
+	}
+}
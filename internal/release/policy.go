@@ -0,0 +1,86 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ErrAllYanked 没有可提供的版本：所有正式版本都已撤回
+var ErrAllYanked = fmt.Errorf("%w: 所有版本都已撤回", ErrNotFound)
+
+// Policy 运维对提供哪个版本的干预
+type Policy interface {
+	// Pinned 固定提供的版本，为空时提供最新版本
+	Pinned() string
+	// Yanked 版本是否已撤回 (运维撤回或 release 标记)，撤回的版本不再作为最新版本
+	Yanked(tag string) bool
+	// Labeled 记录 release 是否标记为撤回 (Release.Yanked)
+	Labeled(tag string, yanked bool)
+}
+
+// WithPolicy 按 policy 决定 LatestRelease 返回的版本，其余方法直接调用 src
+//
+// 版本信息刷新和缓存同步都通过 LatestRelease 获取当前版本，因此固定或撤回版本后两者保持一致。
+func WithPolicy(src Source, policy Policy) Source {
+	return &policySource{Source: src, policy: policy}
+}
+
+type policySource struct {
+	Source
+	policy Policy
+}
+
+// LatestRelease 优先返回固定的版本；最新版本已撤回时回退到发布时间最近的未撤回正式版本
+func (p *policySource) LatestRelease(ctx context.Context) (*Release, error) {
+	if tag := p.policy.Pinned(); tag != "" && !p.policy.Yanked(tag) {
+		rel, err := p.Source.GetRelease(ctx, tag)
+		if err != nil || !p.yanked(rel) {
+			return rel, err
+		}
+	}
+
+	latest, err := p.Source.LatestRelease(ctx)
+	if err != nil || !p.yanked(latest) {
+		return latest, err
+	}
+
+	releases, err := p.Source.ListReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 不依赖来源返回的顺序，按发布时间从新到旧选择 (无法解析的时间排在最后)
+	sort.SliceStable(releases, func(i, j int) bool {
+		return publishedAt(&releases[i]).After(publishedAt(&releases[j]))
+	})
+	for i := range releases {
+		r := &releases[i]
+		if !r.Prerelease && !p.yanked(r) {
+			slog.InfoContext(ctx, "最新版本已撤回，使用之前的版本", "yanked", latest.Tag, "tag", r.Tag)
+			return r, nil
+		}
+	}
+	return nil, ErrAllYanked
+}
+
+// GetRelease 读取指定版本，同时把 release 上的撤回标记同步给 policy
+func (p *policySource) GetRelease(ctx context.Context, tag string) (*Release, error) {
+	rel, err := p.Source.GetRelease(ctx, tag)
+	if err == nil {
+		p.policy.Labeled(rel.Tag, rel.Yanked)
+	}
+	return rel, err
+}
+
+func publishedAt(r *Release) time.Time {
+	t, _ := time.Parse(time.RFC3339, r.PublishedAt)
+	return t
+}
+
+// yanked 版本是否已撤回，同时把 release 上的撤回标记同步给 policy (检查更新时据此提示降级)
+func (p *policySource) yanked(r *Release) bool {
+	p.policy.Labeled(r.Tag, r.Yanked)
+	return p.policy.Yanked(r.Tag)
+}
@@ -0,0 +1,88 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type testPolicy struct {
+	pinned  string
+	yanked  map[string]bool
+	labeled map[string]bool
+}
+
+func (p *testPolicy) Pinned() string         { return p.pinned }
+func (p *testPolicy) Yanked(tag string) bool { return p.yanked[tag] || p.labeled[tag] }
+
+func (p *testPolicy) Labeled(tag string, yanked bool) {
+	if p.labeled == nil {
+		p.labeled = make(map[string]bool)
+	}
+	p.labeled[tag] = yanked
+}
+
+// unorderedSource 按发布时间正序返回版本列表，与来源 API 的约定相反
+type unorderedSource struct {
+	Source
+}
+
+func (u unorderedSource) ListReleases(ctx context.Context) ([]Release, error) {
+	releases, err := u.Source.ListReleases(ctx)
+	slices.Reverse(releases)
+	return releases, err
+}
+
+func TestWithPolicy(t *testing.T) {
+	root := t.TempDir()
+	writeRelease(t, root, "v1.0.0", "date: 2024-01-01\n", map[string]string{"app.zip": "v1"})
+	writeRelease(t, root, "v1.1.0", "date: 2024-02-01\n", map[string]string{"app.zip": "v1.1"})
+	writeRelease(t, root, "v1.2.0", "date: 2024-03-01\n", map[string]string{"app.zip": "v1.2"})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		policy testPolicy
+		want   string
+	}{
+		{"默认最新版本", testPolicy{}, "v1.2.0"},
+		{"固定版本", testPolicy{pinned: "v1.0.0"}, "v1.0.0"},
+		{"撤回最新版本时回退", testPolicy{yanked: map[string]bool{"v1.2.0": true}}, "v1.1.0"},
+		{"跳过连续撤回的版本", testPolicy{yanked: map[string]bool{"v1.2.0": true, "v1.1.0": true}}, "v1.0.0"},
+		{"固定的版本已撤回时忽略固定", testPolicy{pinned: "v1.0.0", yanked: map[string]bool{"v1.0.0": true}}, "v1.2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rel, err := WithPolicy(NewLocal(root), &tt.policy).LatestRelease(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rel.Tag != tt.want {
+				t.Errorf("期望 %s, 得到 %s", tt.want, rel.Tag)
+			}
+		})
+	}
+
+	// 回退时按发布时间选择，不依赖列表顺序
+	yanked := testPolicy{yanked: map[string]bool{"v1.2.0": true}}
+	if rel, err := WithPolicy(unorderedSource{NewLocal(root)}, &yanked).LatestRelease(ctx); err != nil || rel.Tag != "v1.1.0" {
+		t.Errorf("列表顺序不同时期望回退到 v1.1.0, 得到 %v %v", rel, err)
+	}
+
+	all := testPolicy{yanked: map[string]bool{"v1.0.0": true, "v1.1.0": true, "v1.2.0": true}}
+	if _, err := WithPolicy(NewLocal(root), &all).LatestRelease(ctx); !errors.Is(err, ErrAllYanked) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("全部撤回时期望 ErrAllYanked, 得到 %v", err)
+	}
+
+	// release.yaml 中标记撤回
+	writeRelease(t, root, "v1.3.0", "date: 2024-04-01\nyanked: true\n", map[string]string{"app.zip": "v1.3"})
+	var policy testPolicy
+	rel, err := WithPolicy(NewLocal(root), &policy).LatestRelease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Tag != "v1.2.0" || !policy.labeled["v1.3.0"] || policy.labeled["v1.2.0"] {
+		t.Errorf("期望跳过标记撤回的 v1.3.0 并记录标记, 得到 %s %v", rel.Tag, policy.labeled)
+	}
+}
@@ -0,0 +1,255 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"update-server/internal/config"
+	"update-server/internal/httpclient"
+	"update-server/internal/tracing"
+)
+
+// ErrNotFound 版本或文件不存在
+var ErrNotFound = errors.New("release 不存在")
+
+// Asset 版本附带的文件
+type Asset struct {
+	ID   int64  // 来源内部 ID (可能为 0)
+	Name string // 文件名
+	Size int64  // 文件大小，未知时为 0
+	URL  string // 来源下载地址
+
+	SHA256 string // 来源提供的 SHA-256 (十六进制)，未知时为空
+}
+
+// Release 一个发布版本
+type Release struct {
+	Tag         string
+	Name        string
+	Notes       string
+	PublishedAt string
+	Prerelease  bool // 预发布版本，不作为最新版本
+	Yanked      bool // release 标记为撤回 (yank.label)，不作为最新版本
+	Assets      []Asset
+}
+
+// Asset 按文件名查找
+func (r *Release) Asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Source Release 来源 (GitHub / GitLab / Gitea / 本地目录)
+type Source interface {
+	// ListReleases 列出版本，按发布时间倒序
+	ListReleases(ctx context.Context) ([]Release, error)
+	// LatestRelease 返回最新的正式版本
+	LatestRelease(ctx context.Context) (*Release, error)
+	// GetRelease 按 tag 获取版本，不存在时返回 ErrNotFound
+	GetRelease(ctx context.Context, tag string) (*Release, error)
+	// OpenAsset 打开文件用于下载，返回内容和大小 (未知时为 -1)
+	OpenAsset(ctx context.Context, asset Asset) (io.ReadCloser, int64, error)
+}
+
+// New 根据 release 配置创建来源
+//
+// 每次调用都按当前配置选择实现，热重载修改 provider/repo 后立即生效；
+// 配置不变时复用同一个实现 (保留 GitHub App 安装令牌等状态)。
+// observe 可为 nil，非空时观察每个 API 请求 (用于指标)。
+func New(cfg *config.Store, observe httpclient.Observer) Source {
+	return &configured{cfg: cfg, pool: httpclient.Pool{Observer: observe}}
+}
+
+// FromConfig 按一份配置创建具体的来源实现，clients 为出站 HTTP 客户端
+func FromConfig(cfg *config.Config, clients *httpclient.Clients) (Source, error) {
+	switch cfg.Release.Provider {
+	case "", config.ProviderGitHub:
+		return NewGitHub(cfg.Release, clients)
+	case config.ProviderGitLab:
+		return NewGitLab(cfg.Release, clients), nil
+	case config.ProviderGitea:
+		return NewGitea(cfg.Release, clients), nil
+	case config.ProviderLocal:
+		return NewLocal(cfg.Release.Dir), nil
+	default:
+		return nil, fmt.Errorf("未知的 release.provider: %s", cfg.Release.Provider)
+	}
+}
+
+type configured struct {
+	cfg  *config.Store
+	pool httpclient.Pool
+
+	mu      sync.Mutex
+	release config.GitHubRepo
+	clients *httpclient.Clients
+	src     Source
+}
+
+func (c *configured) source() (Source, error) {
+	cfg := c.cfg.Get()
+	clients, err := c.pool.Get(cfg.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.src != nil && c.release == cfg.Release && c.clients == clients {
+		return c.src, nil
+	}
+	src, err := FromConfig(cfg, clients)
+	if err != nil {
+		return nil, err
+	}
+	c.release, c.clients, c.src = cfg.Release, clients, src
+	return src, nil
+}
+
+func (c *configured) ListReleases(ctx context.Context) (releases []Release, err error) {
+	ctx, span := c.startSpan(ctx, "release.ListReleases")
+	defer func() { tracing.End(span, err) }()
+
+	s, err := c.source()
+	if err != nil {
+		return nil, err
+	}
+	releases, err = s.ListReleases(ctx)
+	for i := range releases {
+		c.markYanked(&releases[i])
+	}
+	return releases, err
+}
+
+func (c *configured) LatestRelease(ctx context.Context) (rel *Release, err error) {
+	ctx, span := c.startSpan(ctx, "release.LatestRelease")
+	defer func() {
+		if rel != nil {
+			span.SetAttributes(attribute.String("release.tag", rel.Tag))
+		}
+		tracing.End(span, err)
+	}()
+
+	s, err := c.source()
+	if err != nil {
+		return nil, err
+	}
+	rel, err = s.LatestRelease(ctx)
+	c.markYanked(rel)
+	return rel, err
+}
+
+func (c *configured) GetRelease(ctx context.Context, tag string) (rel *Release, err error) {
+	ctx, span := c.startSpan(ctx, "release.GetRelease", attribute.String("release.tag", tag))
+	defer func() { tracing.End(span, err) }()
+
+	s, err := c.source()
+	if err != nil {
+		return nil, err
+	}
+	rel, err = s.GetRelease(ctx, tag)
+	c.markYanked(rel)
+	return rel, err
+}
+
+// markYanked 名称或说明中包含 yank.label 的版本标记为撤回
+func (c *configured) markYanked(r *Release) {
+	label := c.cfg.Get().Yank.Label
+	if r != nil && label != "" && (strings.Contains(r.Name, label) || strings.Contains(r.Notes, label)) {
+		r.Yanked = true
+	}
+}
+
+// OpenAsset 的 span 只覆盖建立连接和收到响应头，传输时间由出站请求的 span 记录
+func (c *configured) OpenAsset(ctx context.Context, asset Asset) (body io.ReadCloser, size int64, err error) {
+	ctx, span := c.startSpan(ctx, "release.OpenAsset", attribute.String("release.asset", asset.Name))
+	defer func() { tracing.End(span, err) }()
+
+	s, err := c.source()
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.OpenAsset(ctx, asset)
+}
+
+// startSpan 开始来源 API 调用的 span，记录 provider 和仓库
+func (c *configured) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	cfg := c.cfg.Get().Release
+	repo := cfg.Repo
+	if cfg.Provider == config.ProviderLocal {
+		repo = cfg.Dir
+	}
+	attrs = append(attrs,
+		attribute.String("release.provider", cfg.Provider),
+		attribute.String("release.repo", repo),
+	)
+	return tracing.Start(ctx, name, attrs...)
+}
+
+// openURL 下载文件，header 为来源需要的认证头
+func openURL(ctx context.Context, client *http.Client, url string, header http.Header) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// getJSON 请求来源 API 并解码 JSON，404 返回 ErrNotFound
+func getJSON(ctx context.Context, client *http.Client, url string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("API 错误: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
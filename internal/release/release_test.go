@@ -0,0 +1,197 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"update-server/internal/config"
+	"update-server/internal/httpclient"
+)
+
+// fakeAPI 按路径返回固定 JSON，并记录认证头
+func fakeAPI(t *testing.T, routes map[string]string, authHeader string, gotAuth *string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*gotAuth = r.Header.Get(authHeader)
+		body, ok := routes[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testHTTP() *httpclient.Clients {
+	clients, err := httpclient.New(config.Default().HTTP)
+	if err != nil {
+		panic(err)
+	}
+	return clients
+}
+
+func TestGitHub_Enterprise(t *testing.T) {
+	var auth string
+	srv := fakeAPI(t, map[string]string{
+		"/api/v3/repos/org/app/releases/latest": `{"tag_name":"v1.2.0","body":"notes","assets":[{"id":11,"name":"app.zip","size":7,"browser_download_url":"https://example.com/app.zip"}]}`,
+	}, "Authorization", &auth)
+
+	src, err := NewGitHub(config.GitHubRepo{BaseURL: srv.URL + "/api/v3", Repo: "org/app", Token: "tok"}, testHTTP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := src.LatestRelease(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Tag != "v1.2.0" || rel.Notes != "notes" {
+		t.Errorf("期望 v1.2.0/notes, 得到 %s/%s", rel.Tag, rel.Notes)
+	}
+	if a, ok := rel.Asset("app.zip"); !ok || a.Size != 7 || a.ID != 11 {
+		t.Errorf("期望 app.zip (id 11, 大小 7), 得到 %+v", a)
+	}
+	if auth != "token tok" {
+		t.Errorf("期望认证头 token tok, 得到 %q", auth)
+	}
+
+	if _, err := src.GetRelease(context.Background(), "v0.0.1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("期望 ErrNotFound, 得到 %v", err)
+	}
+}
+
+func TestGitHub_PrivateAsset(t *testing.T) {
+	// 存储主机：签名 URL 不应收到 token
+	var storageAuth string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = r.Header.Get("Authorization")
+		w.Write([]byte("private"))
+	}))
+	t.Cleanup(storage.Close)
+
+	var apiAuth, apiAccept string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/beta/releases/assets/42" {
+			http.NotFound(w, r)
+			return
+		}
+		apiAuth = r.Header.Get("Authorization")
+		apiAccept = r.Header.Get("Accept")
+		http.Redirect(w, r, storage.URL+"/signed?sig=abc", http.StatusFound)
+	}))
+	t.Cleanup(api.Close)
+
+	src, err := NewGitHub(config.GitHubRepo{BaseURL: api.URL, Repo: "org/beta", Token: "tok"}, testHTTP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _, err := src.OpenAsset(context.Background(), Asset{ID: 42, Name: "app.zip", URL: "https://github.com/org/beta/releases/download/v1/app.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+
+	if string(data) != "private" {
+		t.Errorf("期望内容 private, 得到 %q", data)
+	}
+	if apiAuth != "token tok" || apiAccept != "application/octet-stream" {
+		t.Errorf("API 请求头错误: Authorization=%q Accept=%q", apiAuth, apiAccept)
+	}
+	if storageAuth != "" {
+		t.Errorf("跳转到存储主机时不应转发 token, 得到 %q", storageAuth)
+	}
+
+	if _, _, err := src.OpenAsset(context.Background(), Asset{ID: 7}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("期望 ErrNotFound, 得到 %v", err)
+	}
+}
+
+func TestGitLab(t *testing.T) {
+	var auth string
+	srv := fakeAPI(t, map[string]string{
+		"/api/v4/projects/group%2Fapp/releases/permalink/latest": `{"tag_name":"v2.0.0","description":"gl","released_at":"2024-01-01T00:00:00Z","assets":{"links":[{"id":3,"name":"app.zip","url":"https://example.com/a","direct_asset_url":"https://example.com/direct"}]}}`,
+		"/api/v4/projects/group%2Fapp/releases":                  `[{"tag_name":"v2.0.0"},{"tag_name":"v2.1.0","upcoming_release":true}]`,
+	}, "PRIVATE-TOKEN", &auth)
+
+	src := NewGitLab(config.GitHubRepo{Provider: config.ProviderGitLab, BaseURL: srv.URL, Repo: "group/app", Token: "tok"}, testHTTP())
+	rel, err := src.LatestRelease(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Tag != "v2.0.0" || rel.Notes != "gl" {
+		t.Errorf("期望 v2.0.0/gl, 得到 %s/%s", rel.Tag, rel.Notes)
+	}
+	if a, ok := rel.Asset("app.zip"); !ok || a.URL != "https://example.com/direct" || a.ID != 3 {
+		t.Errorf("期望使用 direct_asset_url, 得到 %+v", a)
+	}
+	if auth != "tok" {
+		t.Errorf("期望 PRIVATE-TOKEN tok, 得到 %q", auth)
+	}
+
+	list, err := src.ListReleases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Errorf("期望跳过未发布版本后剩 1 个, 得到 %d", len(list))
+	}
+}
+
+func TestGitea(t *testing.T) {
+	var auth string
+	srv := fakeAPI(t, map[string]string{
+		"/api/v1/repos/org/app/releases/tags/v3.0.0": `{"tag_name":"v3.0.0","body":"gt","assets":[{"id":9,"name":"app.zip","size":5,"browser_download_url":"/file"}]}`,
+		"/file": "hello",
+	}, "Authorization", &auth)
+
+	src := NewGitea(config.GitHubRepo{Provider: config.ProviderGitea, BaseURL: srv.URL, Repo: "org/app", Token: "tok"}, testHTTP())
+	rel, err := src.GetRelease(context.Background(), "v3.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, ok := rel.Asset("app.zip")
+	if !ok || a.Size != 5 || a.ID != 9 {
+		t.Fatalf("期望 app.zip 大小 5, 得到 %+v", a)
+	}
+
+	a.URL = srv.URL + a.URL
+	body, size, err := src.OpenAsset(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	if string(data) != "hello" || size != 5 {
+		t.Errorf("期望内容 hello, 得到 %q (%d)", data, size)
+	}
+	if auth != "token tok" {
+		t.Errorf("期望认证头 token tok, 得到 %q", auth)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Release.Provider = "svn"
+	if _, err := FromConfig(cfg, testHTTP()); err == nil {
+		t.Error("未知 provider 应返回错误")
+	}
+
+	cfg.Release.Provider = config.ProviderGitLab
+	if _, ok := mustSource(t, cfg).(*GitLab); !ok {
+		t.Error("期望 GitLab 来源")
+	}
+}
+
+func mustSource(t *testing.T, cfg *config.Config) Source {
+	t.Helper()
+	s, err := FromConfig(cfg, testHTTP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
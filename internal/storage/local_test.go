@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"v1.0.0/app.zip", true},
+		{"v1.0.0/sub/app.zip", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../etc/passwd", false},
+		{"v1.0.0/../../etc/passwd", false},
+		{"v1.0.0//app.zip", false},
+		{"v1.0.0\\app.zip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := ValidKey(tt.key); got != tt.expected {
+				t.Errorf("ValidKey(%q): 期望 %v, 得到 %v", tt.key, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLocal_PutOpenDelete(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := Key("v1.0.0", "app.zip")
+	if _, err := store.Stat(ctx, key); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("期望 ErrNotExist, 得到 %v", err)
+	}
+
+	content := "test file content"
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入完成后不应残留临时文件
+	if entries, _ := os.ReadDir(filepath.Join(root, "v1.0.0")); len(entries) != 1 {
+		t.Errorf("临时文件未清理: %v", entries)
+	}
+
+	obj, info, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(obj)
+	obj.Close()
+
+	if string(data) != content {
+		t.Errorf("文件内容不匹配: %q", data)
+	}
+	if info.Size != int64(len(content)) {
+		t.Errorf("期望大小 %d, 得到 %d", len(content), info.Size)
+	}
+
+	objects, err := store.List(ctx, "v1.0.0/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != key {
+		t.Errorf("List 结果不正确: %+v", objects)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Open(ctx, key); !errors.Is(err, ErrNotExist) {
+		t.Errorf("删除后期望 ErrNotExist, 得到 %v", err)
+	}
+	// 重复删除不报错
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("重复删除不应报错: %v", err)
+	}
+}
+
+func TestLocal_RejectTraversal(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Put(context.Background(), "../escape", strings.NewReader("x"), 1)
+	if err == nil {
+		t.Error("期望拒绝路径穿越 key")
+	}
+}
+
+func TestLocal_RemoveTemp(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "v1.0.0")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, tempPrefix+"123"), []byte("partial"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.zip"), []byte("complete"), 0644)
+	// 名为 *.tmp 的 release 文件不是临时文件
+	os.WriteFile(filepath.Join(dir, "notes.tmp"), []byte("asset"), 0644)
+
+	objects, _ := store.List(context.Background(), "")
+	if len(objects) != 2 {
+		t.Errorf("List 应忽略临时文件, 得到 %+v", objects)
+	}
+
+	store.RemoveTemp()
+
+	if _, err := os.Stat(filepath.Join(dir, tempPrefix+"123")); !os.IsNotExist(err) {
+		t.Error("遗留的临时文件应被清理")
+	}
+	for _, name := range []string{"app.zip", "notes.tmp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s 不应被清理", name)
+		}
+	}
+}
+
+func TestLocal_ConcurrentPut(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := Key("v1.0.0", "app.zip")
+
+	// 同一 key 并发写入，结果应为其中一次完整的内容
+	contents := []string{strings.Repeat("a", 1<<20), strings.Repeat("b", 1<<20)}
+	var wg sync.WaitGroup
+	for _, c := range contents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.Put(ctx, key, strings.NewReader(c), int64(len(c))); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	obj, _, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(obj)
+	obj.Close()
+	if string(data) != contents[0] && string(data) != contents[1] {
+		t.Errorf("并发写入后内容损坏 (长度 %d)", len(data))
+	}
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"update-server/internal/config"
+)
+
+// S3 S3 兼容对象存储 (AWS S3 / MinIO / Cloudflare R2 等)
+//
+// 多个无状态实例可以共享同一个存储桶作为缓存。
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3 创建 S3 存储
+func NewS3(cfg config.S3Storage) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 存储需要配置 endpoint 和 bucket")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       !cfg.Insecure,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 s3 客户端失败: %w", err)
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3{client: client, bucket: cfg.Bucket, prefix: prefix}, nil
+}
+
+func (s *S3) objectName(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("非法 key: %q", key)
+	}
+	return s.prefix + key, nil
+}
+
+func isNotFound(err error) bool {
+	resp := minio.ToErrorResponse(err)
+	return resp.StatusCode == http.StatusNotFound || resp.Code == "NoSuchKey"
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (Object, *ObjectInfo, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	// GetObject 是惰性的，Stat 才会真正发起请求
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if isNotFound(err) {
+			return nil, nil, ErrNotExist
+		}
+		return nil, nil, err
+	}
+	return obj, &ObjectInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	name, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	// 大小未知时 minio-go 自动使用分片上传，上传完成前对象不可见
+	_, err = s.client.PutObject(ctx, s.bucket, name, r, size, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	return err
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	name, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	err = s.client.RemoveObject(ctx, s.bucket, name, minio.RemoveObjectOptions{})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:     strings.TrimPrefix(obj.Key, s.prefix),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+// PresignGet 生成预签名下载 URL，filename 用于 Content-Disposition
+func (s *S3) PresignGet(ctx context.Context, key, filename string, expiry time.Duration) (string, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	if filename != "" {
+		params.Set("response-content-disposition", ContentDisposition(filename))
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, name, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
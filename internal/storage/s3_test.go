@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"update-server/internal/config"
+)
+
+// 需要本地 MinIO，例如:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	mc mb local/orange-test
+//	MINIO_ENDPOINT=127.0.0.1:9000 MINIO_BUCKET=orange-test go test ./internal/storage -run S3
+func newTestS3(t *testing.T) *S3 {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("未设置 MINIO_ENDPOINT，跳过 S3 测试")
+	}
+
+	accessKey := os.Getenv("MINIO_ACCESS_KEY")
+	if accessKey == "" {
+		accessKey = "minioadmin"
+	}
+	secretKey := os.Getenv("MINIO_SECRET_KEY")
+	if secretKey == "" {
+		secretKey = "minioadmin"
+	}
+	bucket := os.Getenv("MINIO_BUCKET")
+	if bucket == "" {
+		bucket = "orange-test"
+	}
+
+	store, err := NewS3(config.S3Storage{
+		Endpoint:  endpoint,
+		Bucket:    bucket,
+		Prefix:    "test-" + time.Now().Format("20060102150405"),
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Insecure:  true,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3_PutOpenPresign(t *testing.T) {
+	store := newTestS3(t)
+	ctx := context.Background()
+
+	key := Key("v1.0.0", "app.zip")
+	content := "test file content"
+	if err := store.Put(ctx, key, strings.NewReader(content), -1); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Delete(ctx, key)
+
+	obj, info, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(obj)
+	obj.Close()
+	if string(data) != content || info.Size != int64(len(content)) {
+		t.Errorf("对象内容不匹配: %q (%d)", data, info.Size)
+	}
+
+	u, err := store.PresignGet(ctx, key, "app.zip", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("预签名 URL 期望 200, 得到 %d", resp.StatusCode)
+	}
+}
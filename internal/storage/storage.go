@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	"update-server/internal/config"
+)
+
+// ErrNotExist 对象不存在
+var ErrNotExist = errors.New("对象不存在")
+
+// ObjectInfo 缓存对象信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Object 可随机读取的缓存对象 (用于 http.ServeContent 支持 Range)
+type Object interface {
+	io.ReadSeekCloser
+}
+
+// Storage 缓存存储后端
+//
+// key 统一使用 "{tag}/{filename}" 形式，由调用方保证不含 ".."。
+type Storage interface {
+	// Stat 返回对象信息，不存在时返回 ErrNotExist
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Open 打开对象用于读取，不存在时返回 ErrNotExist
+	Open(ctx context.Context, key string) (Object, *ObjectInfo, error)
+	// Put 写入对象，写入完成前对读取方不可见；size 未知时传 -1
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// List 列出指定前缀下的对象
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// Presigner 支持生成预签名下载 URL 的后端
+type Presigner interface {
+	PresignGet(ctx context.Context, key, filename string, expiry time.Duration) (string, error)
+}
+
+// Key 拼接缓存对象 key
+func Key(tag, name string) string {
+	return tag + "/" + name
+}
+
+// ValidKey 检查 key 是否安全 (不允许路径穿越和绝对路径)
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// ContentDisposition 下载响应的 Content-Disposition，文件名含特殊或非 ASCII 字符时按 RFC 2231 编码
+func ContentDisposition(filename string) string {
+	if v := mime.FormatMediaType("attachment", map[string]string{"filename": filename}); v != "" {
+		return v
+	}
+	return "attachment"
+}
+
+// New 根据配置创建存储后端
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.Storage.Type {
+	case "", "local":
+		return NewLocal(cfg.Cache.Dir)
+	case "s3":
+		return NewS3(cfg.Storage.S3)
+	default:
+		return nil, fmt.Errorf("未知的存储类型: %s", cfg.Storage.Type)
+	}
+}
@@ -0,0 +1,20 @@
+package storage
+
+import "testing"
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"app.zip", "attachment; filename=app.zip"},
+		{"my app.zip", `attachment; filename="my app.zip"`},
+		{`a";b.zip`, `attachment; filename="a\";b.zip"`},
+		{"橙子.zip", "attachment; filename*=utf-8''%E6%A9%99%E5%AD%90.zip"},
+	}
+	for _, tt := range tests {
+		if got := ContentDisposition(tt.filename); got != tt.want {
+			t.Errorf("ContentDisposition(%q) = %q, 期望 %q", tt.filename, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"update-server/internal/config"
+)
+
+// install 安装内存导出的 provider，测试结束后恢复
+func install(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	oldTP, oldProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	exporter := tracetest.NewInMemoryExporter()
+	Install(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldTP)
+		otel.SetTextMapPropagator(oldProp)
+	})
+	return exporter
+}
+
+func attr(span tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTransport(t *testing.T) {
+	exporter := install(t)
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer srv.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/file?X-Amz-Signature=secret", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Error("响应体读完之前 span 不应结束")
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("期望 2 个 span, 得到 %d", len(spans))
+	}
+	client0 := spans[0]
+	if client0.Name != "HTTP GET" || client0.SpanKind != trace.SpanKindClient {
+		t.Errorf("span 错误: %s %v", client0.Name, client0.SpanKind)
+	}
+	if client0.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("出站请求的 span 应属于调用方的 span")
+	}
+	if got := attr(client0, "url.full").AsString(); strings.Contains(got, "secret") {
+		t.Errorf("url.full 不应包含查询参数: %s", got)
+	}
+	if got := attr(client0, "http.response.body.size").AsInt64(); got != 1000 {
+		t.Errorf("body size 错误: %d", got)
+	}
+	if !strings.Contains(traceparent, client0.SpanContext.TraceID().String()) {
+		t.Errorf("应注入 traceparent: %q", traceparent)
+	}
+}
+
+func TestStartServer(t *testing.T) {
+	exporter := install(t)
+
+	// 上游传入的 traceparent
+	upstream, span := Start(context.Background(), "upstream")
+	r := httptest.NewRequest("GET", "/api/v1/version", nil)
+	otel.GetTextMapPropagator().Inject(upstream, propagation.HeaderCarrier(r.Header))
+	span.End()
+
+	_, server := StartServer(r, "/api/v1/version")
+	EndServer(server, http.StatusBadGateway, 10)
+
+	got := exporter.GetSpans()[1]
+	if got.Name != "GET /api/v1/version" || got.Parent.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("server span 错误: %s parent=%v", got.Name, got.Parent)
+	}
+	if got.Status.Code.String() != "Error" {
+		t.Errorf("5xx 应标记为错误: %v", got.Status)
+	}
+}
+
+func TestSetup_Disabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.Tracing{}, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetup_OTLP(t *testing.T) {
+	oldTP, oldProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(oldTP)
+		otel.SetTextMapPropagator(oldProp)
+	}()
+
+	// 模拟 OTLP/HTTP collector
+	received := make(chan *http.Request, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case received <- r:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	shutdown, err := Setup(context.Background(), config.Tracing{
+		Enabled:     true,
+		Endpoint:    collector.URL,
+		Headers:     "X-Tenant=orange",
+		ServiceName: "orange-service",
+	}, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := Start(context.Background(), "test")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-received:
+		if r.URL.Path != "/v1/traces" || r.Header.Get("X-Tenant") != "orange" {
+			t.Errorf("导出请求错误: %s %v", r.URL.Path, r.Header)
+		}
+	default:
+		t.Fatal("collector 未收到数据")
+	}
+}
+
+func TestExporterOptions(t *testing.T) {
+	if _, err := exporterOptions(config.Tracing{Endpoint: "http://localhost:4318", Headers: "Authorization=Bearer x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exporterOptions(config.Tracing{Headers: "bad"}); err == nil {
+		t.Error("headers 格式错误时应返回错误")
+	}
+}
@@ -1,13 +1,15 @@
 package version
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	"update-server/internal/config"
-	"update-server/internal/github"
+	"update-server/internal/release"
 )
 
 // 构建时注入的版本号
@@ -29,58 +31,121 @@ type Info struct {
 	PublishedAt  string    `json:"published_at"`
 	Assets       []Asset   `json:"assets"`
 	UpdatedAt    time.Time `json:"-"`
+
+	// Release 来源返回的原始版本，按需下载时用于查找来源文件
+	Release *release.Release `json:"-"`
 }
 
-var (
-	current *Info
+// RefreshStatus 最近一次刷新版本信息的结果
+type RefreshStatus struct {
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Store 版本信息存储
+type Store struct {
+	cfg      *config.Store
+	releases release.Source
+
 	mu      sync.RWMutex
-)
+	current *Info
+	status  RefreshStatus
+}
+
+func NewStore(cfg *config.Store, releases release.Source) *Store {
+	return &Store{cfg: cfg, releases: releases}
+}
 
-func Refresh() error {
-	release, err := github.FetchLatestRelease()
+func (s *Store) Refresh(ctx context.Context) error {
+	rel, err := s.releases.LatestRelease(ctx)
+	s.recordRefresh(err)
+	if errors.Is(err, release.ErrAllYanked) {
+		// 不再提供已撤回的版本：清空当前版本，检查更新等接口返回 503
+		s.Set(nil)
+		slog.WarnContext(ctx, "所有版本都已撤回，停止提供版本信息")
+	}
 	if err != nil {
 		return err
 	}
 
-	cfg := config.Get()
-
-	mu.Lock()
-	defer mu.Unlock()
+	cfg := s.cfg.Get()
 
-	assets := make([]Asset, 0, len(release.Assets))
-	for _, a := range release.Assets {
+	assets := make([]Asset, 0, len(rel.Assets))
+	for _, a := range rel.Assets {
 		assets = append(assets, Asset{
 			Name:        a.Name,
 			Size:        a.Size,
-			DownloadURL: fmt.Sprintf("%s/api/v1/download/%s/%s", cfg.Server.BaseURL, release.TagName, a.Name),
+			DownloadURL: fmt.Sprintf("%s/api/v1/download/%s/%s", cfg.Server.BaseURL, rel.Tag, a.Name),
 		})
 	}
 
-	current = &Info{
-		Version:      release.TagName,
-		ReleaseNotes: release.Body,
-		PublishedAt:  release.PublishedAt,
+	s.Set(&Info{
+		Version:      rel.Tag,
+		ReleaseNotes: rel.Notes,
+		PublishedAt:  rel.PublishedAt,
 		Assets:       assets,
 		UpdatedAt:    time.Now(),
-	}
+		Release:      rel,
+	})
 
-	log.Printf("版本信息已更新: %s", release.TagName)
+	slog.InfoContext(ctx, "版本信息已更新", "tag", rel.Tag)
 	return nil
 }
 
-func Get() *Info {
-	mu.RLock()
-	defer mu.RUnlock()
-	return current
+func (s *Store) Get() *Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
 }
 
-func StartAutoRefresh(interval time.Duration) {
-	go func() {
-		ticker := time.NewTicker(interval)
-		for range ticker.C {
-			if err := Refresh(); err != nil {
-				log.Printf("刷新版本信息失败: %v", err)
-			}
+func (s *Store) recordRefresh(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastAttempt = time.Now()
+	if err != nil {
+		s.status.LastError = err.Error()
+		return
+	}
+	s.status.LastSuccess, s.status.LastError = s.status.LastAttempt, ""
+}
+
+// Status 返回最近一次刷新的结果
+func (s *Store) Status() RefreshStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Set 直接设置当前版本信息
+func (s *Store) Set(info *Info) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = info
+}
+
+// StartAutoRefresh 按 cache.refresh_interval 定时刷新版本信息，ctx 取消时停止
+//
+// 每轮重新读取配置，热重载修改间隔后无需重启；间隔为 0 时暂停刷新。
+func (s *Store) StartAutoRefresh(ctx context.Context) {
+	for {
+		interval := s.cfg.Get().Cache.RefreshInterval
+		wait := interval
+		if wait <= 0 {
+			wait = time.Minute
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
 		}
-	}()
+
+		if interval <= 0 || s.cfg.Get().Cache.RefreshInterval <= 0 {
+			continue
+		}
+		if err := s.Refresh(ctx); err != nil {
+			slog.ErrorContext(ctx, "刷新版本信息失败", "err", err)
+		}
+	}
 }
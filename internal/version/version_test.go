@@ -5,12 +5,16 @@ import (
 )
 
 func TestGet_Initial(t *testing.T) {
-	// 初始状态应该返回 nil
-	// 注意：如果之前有其他测试设置了 current，这个测试可能会失败
-	// 这里主要测试并发安全性
-	info := Get()
-	// 不做断言，只确保不会 panic
-	_ = info
+	// 新建的 Store 初始状态应该返回 nil
+	s := NewStore(nil, nil)
+	if info := s.Get(); info != nil {
+		t.Errorf("期望 nil, 得到 %+v", info)
+	}
+
+	s.Set(&Info{Version: "v1.0.0"})
+	if info := s.Get(); info == nil || info.Version != "v1.0.0" {
+		t.Errorf("Set 后 Get 结果不正确: %+v", info)
+	}
 }
 
 func TestAssetStruct(t *testing.T) {